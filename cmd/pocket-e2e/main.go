@@ -0,0 +1,108 @@
+// Command pocket-e2e encrypts and decrypts end-to-end encrypted pocket message content.
+//
+//	pocket-e2e encrypt < note.txt                           prints the envelope and a new key
+//	POCKET_E2E_KEY=KEY pocket-e2e encrypt < note.txt        reuses an existing key
+//	POCKET_E2E_KEY=KEY pocket-e2e decrypt < envelope        prints the plaintext
+//
+// The key is read from POCKET_E2E_KEY rather than a flag, so it does not end
+// up in the shell history or the process list.
+//
+// Store the envelope as the message content with "encrypted": true and share
+// the link as https://host/api/v1/msg/RANDOM_ID#KEY. Only the content is
+// encrypted: the title is stored as plaintext and indexed by search, so keep
+// secrets out of it.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"pocket-message/pkg/e2e"
+	"strings"
+)
+
+// keyEnv names the environment variable with the base64url encoded key.
+const keyEnv = "POCKET_E2E_KEY"
+
+func main() {
+	if len(os.Args) != 2 {
+		usage()
+	}
+	key := os.Getenv(keyEnv)
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+
+	switch os.Args[1] {
+	case "encrypt":
+		err = encrypt(key, input)
+	case "decrypt":
+		if key == "" {
+			fail(fmt.Errorf("%s should be set to the key", keyEnv))
+		}
+		err = decrypt(key, input)
+	default:
+		usage()
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func encrypt(encodedKey string, plaintext []byte) error {
+	var key []byte
+	var err error
+	if encodedKey == "" {
+		key, err = e2e.GenerateKey()
+	} else {
+		key, err = e2e.DecodeKey(encodedKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	env, err := e2e.Seal(key, plaintext)
+	if err != nil {
+		return err
+	}
+	content, err := env.Marshal()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(content)
+	if encodedKey == "" {
+		fmt.Fprintf(os.Stderr, "key: %s\n", e2e.EncodeKey(key))
+	}
+	return nil
+}
+
+func decrypt(encodedKey string, content []byte) error {
+	key, err := e2e.DecodeKey(encodedKey)
+	if err != nil {
+		return err
+	}
+	env, err := e2e.Parse(strings.TrimSpace(string(content)))
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := e2e.Open(key, env)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(plaintext)
+	return err
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: [POCKET_E2E_KEY=KEY] pocket-e2e encrypt|decrypt < input")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,46 @@
+// Command pocket-reencrypt re-encrypts stored pocket messages and their
+// revisions under the active key of encryption.keys/encryption.key_id. Run it
+// after adding a new key and making it active; older keys can be removed once
+// it reports done.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"pocket-message/configs"
+	"pocket-message/database"
+	"pocket-message/repositories"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 100, "number of rows loaded per query")
+	cfg, err := configs.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kr, err := cfg.Keyring()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if kr == nil {
+		log.Fatal("encryption.keys is empty, nothing to re-encrypt with")
+	}
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = database.MigrateDB(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repo := repositories.GormSql{DB: db, Keyring: kr}
+	n, err := repo.ReencryptPocketMessages(*batchSize)
+	if err != nil {
+		log.Fatalf("re-encrypted %d rows before failing: %v", n, err)
+	}
+	log.Printf("re-encrypted %d rows with key %q", n, kr.ActiveID())
+}
@@ -1,17 +1,288 @@
+// Package configs holds the configuration of the API. It is loaded once at
+// startup from its defaults, a YAML or TOML file, the environment and
+// command-line flags, each source overriding the ones before it.
 package configs
 
-import "os"
-
-var (
-	APIPort     = SetEnv("APIPort", ":8080")
-	APIKey      = SetEnv("APIKey", "UwawPangkat2")
-	TokenSecret = "ApaIhLiatLiat"
+import (
+	"errors"
+	"pocket-message/pkg/jwtkeys"
+	"pocket-message/pkg/keyring"
+	"pocket-message/pkg/password"
+	"pocket-message/pkg/randomid"
+	"pocket-message/pkg/username"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func SetEnv(key, def string) string {
-	val, ok := os.LookupEnv(key)
-	if !ok {
-		return def
+// RedactedValue replaces the value of secrets when a configuration is printed.
+const RedactedValue = "REDACTED"
+
+type Config struct {
+	API        API
+	Database   Database
+	Auth       Auth
+	Encryption Encryption
+	Password   Password
+	Username   Username
+	RandomID   RandomID
+	Messages   Messages
+	Search     Search
+	Notifier   Notifier
+}
+
+type API struct {
+	Port string
+}
+
+type Database struct {
+	// Driver is "mysql", "postgres", "sqlite" or "memory". SQLite keeps the
+	// database in the file Name and ignores the other settings; memory keeps
+	// it in the process until it exits, for tests and demos.
+	Driver   string
+	Address  string
+	Name     string
+	User     string
+	Password string
+}
+
+type Auth struct {
+	// TokenSecret signs tokens with HS256 when JWTKeys is empty.
+	TokenSecret string
+	// JWTKeys is a comma separated "id:alg:value" list of token signing keys,
+	// alg being HS256, RS256 or EdDSA. The value is a base64 HS256 secret or
+	// "@path" to a file with the secret or PEM key. Tokens are signed with
+	// JWTKeyID and verified with any listed key.
+	JWTKeys  string
+	JWTKeyID string
+	// AccessTokenTTL is how long an access token stays valid; clients renew
+	// it with their refresh token, which can be used for RefreshTokenTTL.
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration
+	PasswordResetTokenTTL time.Duration
+}
+
+type Encryption struct {
+	// Keys is a comma separated "id:base64key" list of 32 byte
+	// key-encryption keys; leave empty to store messages in plain text.
+	Keys  string
+	KeyID string
+}
+
+type Password struct {
+	// Algorithm is "bcrypt" or "argon2id". Cost is the bcrypt cost or
+	// argon2id passes, 0 for the default. Changing either rehashes each
+	// password on its owner's next login.
+	Algorithm string
+	Cost      int
+}
+
+type Username struct {
+	// Usernames are stored lower case and must match Pattern after
+	// normalization. Reserved is a comma separated list of names nobody can
+	// sign up with; zero values select the defaults.
+	MinLength int
+	MaxLength int
+	Pattern   string
+	Reserved  string
+}
+
+type RandomID struct {
+	// Alphabet and Length shape the generated random ids messages are
+	// shared by; zero values select the defaults.
+	Alphabet string
+	Length   int
+}
+
+type Messages struct {
+	ReaperInterval time.Duration
+	// TrashRetention is how long deleted pocket messages can be restored
+	// before they are purged; 0 keeps them until they are deleted
+	// permanently.
+	TrashRetention time.Duration
+	// ViewIPSalt keys the hash of reader IP addresses stored with message
+	// views, so the addresses can not be recovered by hashing every IP.
+	ViewIPSalt string
+	// A link is locked for PassphraseLockDuration after
+	// PassphraseMaxAttempts wrong passphrases.
+	PassphraseMaxAttempts  int
+	PassphraseLockDuration time.Duration
+	// RandomIDMaxAttempts is how many generated random ids are tried before
+	// giving up on collisions.
+	RandomIDMaxAttempts int
+}
+
+type Search struct {
+	// Index is "mysql" to search messages with a FULLTEXT index or "memory"
+	// for an in-process index that is lost on restart. Key keys the hashes
+	// the indexed words are stored as.
+	Index string
+	Key   string
+}
+
+type Notifier struct {
+	// File receives password reset tokens; empty writes them to the log.
+	File string
+}
+
+// Default is the configuration the other sources override. It has no
+// credentials or secrets, so the database user and password,
+// auth.token_secret, messages.view_ip_salt and, with the mysql index,
+// search.key have to be set.
+func Default() Config {
+	return Config{
+		API:      API{Port: ":8080"},
+		Database: Database{Driver: "mysql"},
+		Auth: Auth{
+			AccessTokenTTL:        15 * time.Minute,
+			RefreshTokenTTL:       720 * time.Hour,
+			PasswordResetTokenTTL: 30 * time.Minute,
+		},
+		Password: Password{Algorithm: password.Bcrypt},
+		Username: Username{Reserved: username.DefaultReserved},
+		Messages: Messages{
+			ReaperInterval:         time.Minute,
+			TrashRetention:         720 * time.Hour,
+			PassphraseMaxAttempts:  5,
+			PassphraseLockDuration: 15 * time.Minute,
+			RandomIDMaxAttempts:    5,
+		},
+		Search: Search{Index: "mysql"},
+	}
+}
+
+// Validate reports every invalid setting at once, so a misconfigured server
+// fails at startup instead of on the first request that needs the setting.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if c.API.Port == "" {
+		check(errors.New("error, api.port should not be empty"))
+	}
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		if c.Database.User == "" {
+			check(errors.New("error, database.user should not be empty"))
+		}
+		if c.Database.Password == "" {
+			check(errors.New("error, database.password should not be empty"))
+		}
+	case "sqlite":
+		if c.Database.Name == "" {
+			check(errors.New("error, database.name should be the database file with sqlite"))
+		}
+	case "memory":
+	default:
+		check(errors.New("error, database.driver should be mysql, postgres, sqlite or memory"))
+	}
+	if c.Auth.JWTKeys == "" && c.Auth.TokenSecret == "" {
+		check(errors.New("error, auth.token_secret should not be empty without auth.jwt_keys"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL <= 0 || c.Auth.PasswordResetTokenTTL <= 0 {
+		check(errors.New("error, auth token ttls should be positive"))
+	}
+	_, err := c.KeySet()
+	check(err)
+	_, err = c.Keyring()
+	check(err)
+	_, err = c.Hasher()
+	check(err)
+	_, err = c.UsernamePolicy()
+	check(err)
+	_, err = c.RandomIDs()
+	check(err)
+	if c.Messages.ReaperInterval <= 0 {
+		check(errors.New("error, messages.reaper_interval should be positive"))
+	}
+	if c.Messages.TrashRetention < 0 {
+		check(errors.New("error, messages.trash_retention should not be negative"))
+	}
+	if c.Messages.PassphraseMaxAttempts <= 0 || c.Messages.PassphraseLockDuration <= 0 {
+		check(errors.New("error, messages.passphrase_max_attempts and messages.passphrase_lock_duration should be positive"))
+	}
+	if c.Messages.RandomIDMaxAttempts <= 0 {
+		check(errors.New("error, messages.random_id_max_attempts should be positive"))
+	}
+	if c.Messages.ViewIPSalt == "" {
+		check(errors.New("error, messages.view_ip_salt should not be empty"))
+	}
+	switch c.Search.Index {
+	case "mysql":
+		// The memory driver searches its messages with the memory index
+		// whatever the setting.
+		if c.Database.Driver == "memory" {
+			break
+		}
+		if c.Database.Driver != "mysql" {
+			check(errors.New("error, search.index mysql needs database.driver mysql, use memory"))
+		}
+		if c.Search.Key == "" {
+			check(errors.New("error, search.key should not be empty with search.index mysql"))
+		}
+	case "memory":
+	default:
+		check(errors.New("error, search.index should be mysql or memory"))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// KeySet returns the keys tokens are signed and verified with.
+func (c *Config) KeySet() (*jwtkeys.KeySet, error) {
+	ks, err := jwtkeys.Parse(c.Auth.JWTKeys, c.Auth.JWTKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if ks == nil {
+		ks = jwtkeys.FromSecret(c.Auth.TokenSecret)
+	}
+	return ks, nil
+}
+
+// Keyring returns the message encryption keys, nil when encryption is off.
+func (c *Config) Keyring() (*keyring.Keyring, error) {
+	return keyring.Parse(c.Encryption.Keys, c.Encryption.KeyID)
+}
+
+func (c *Config) Hasher() (password.Hasher, error) {
+	return password.NewHasher(c.Password.Algorithm, c.Password.Cost)
+}
+
+func (c *Config) UsernamePolicy() (username.Policy, error) {
+	return username.Parse(optional(c.Username.MinLength), optional(c.Username.MaxLength),
+		c.Username.Pattern, c.Username.Reserved)
+}
+
+func (c *Config) RandomIDs() (randomid.Generator, error) {
+	return randomid.Parse(c.RandomID.Alphabet, optional(c.RandomID.Length))
+}
+
+// Redacted returns a copy of the configuration safe to print or log, with
+// every secret that is set replaced by RedactedValue.
+func (c Config) Redacted() Config {
+	for _, s := range settings {
+		if !s.secret {
+			continue
+		}
+		if p := s.field(&c).(*string); *p != "" {
+			*p = RedactedValue
+		}
+	}
+	return c
+}
+
+// optional formats n for the parsers that take "" as their default.
+func optional(n int) string {
+	if n == 0 {
+		return ""
 	}
-	return val
+	return strconv.Itoa(n)
 }
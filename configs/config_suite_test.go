@@ -0,0 +1,269 @@
+package configs
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigSuite struct {
+	suite.Suite
+}
+
+func TestSuiteConfig(t *testing.T) {
+	suite.Run(t, new(ConfigSuite))
+}
+
+func (s *ConfigSuite) SetupSuite() {}
+
+func (s *ConfigSuite) TearDownSuite() {}
+
+// SetupTest sets the credentials and secrets that have no default.
+func (s *ConfigSuite) SetupTest() {
+	s.T().Setenv("DB_USER", "pocket")
+	s.T().Setenv("DB_PASSWORD", "sandi")
+	s.T().Setenv("TokenSecret", "rahasia")
+	s.T().Setenv("ViewIPSalt", "garam")
+	s.T().Setenv("SearchIndexKey", "kunci")
+}
+
+// withSecrets is cfg with the credentials and secrets SetupTest sets.
+func withSecrets(cfg Config) Config {
+	cfg.Database.User = "pocket"
+	cfg.Database.Password = "sandi"
+	cfg.Auth.TokenSecret = "rahasia"
+	cfg.Messages.ViewIPSalt = "garam"
+	cfg.Search.Key = "kunci"
+	return cfg
+}
+
+func (s *ConfigSuite) writeFile(name, content string) string {
+	path := filepath.Join(s.T().TempDir(), name)
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func load(args ...string) (*Config, error) {
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+// Load
+func (s *ConfigSuite) TestLoadDefault() {
+	cfg, err := load()
+	s.NoError(err)
+	s.Equal(withSecrets(Default()), *cfg)
+}
+func (s *ConfigSuite) TestLoadErrorSecrets() {
+	s.T().Setenv("DB_PASSWORD", "")
+	s.T().Setenv("TokenSecret", "")
+	s.T().Setenv("ViewIPSalt", "")
+	s.T().Setenv("SearchIndexKey", "")
+
+	_, err := load()
+	s.EqualError(err, "error, database.password should not be empty; error, auth.token_secret should not be empty without auth.jwt_keys; "+
+		"error, messages.view_ip_salt should not be empty; error, search.key should not be empty with search.index mysql")
+
+	_, err = load("-database-password", "sandi", "-auth-jwt-keys", "k1:HS256:cmFoYXNpYQ==", "-auth-jwt-key-id", "k1", "-messages-view-ip-salt", "garam", "-search-index", "memory")
+	s.NoError(err)
+}
+func (s *ConfigSuite) TestLoadPrecedence() {
+	path := s.writeFile("pocket.yaml", `
+api:
+  port: ":9000"
+database:
+  address: db:3306
+  name: from_file
+  user: file
+messages:
+  trash_retention: 24h
+password:
+  cost: 12
+`)
+	s.T().Setenv("DB_NAME", "from_env")
+	s.T().Setenv("DB_USER", "env")
+
+	cfg, err := load("-config", path, "-database-user", "flag")
+	s.NoError(err)
+	s.Equal(":9000", cfg.API.Port)
+	s.Equal("db:3306", cfg.Database.Address)
+	s.Equal("from_env", cfg.Database.Name)
+	s.Equal("flag", cfg.Database.User)
+	s.Equal(24*time.Hour, cfg.Messages.TrashRetention)
+	s.Equal(12, cfg.Password.Cost)
+	s.Equal(time.Minute, cfg.Messages.ReaperInterval)
+}
+func (s *ConfigSuite) TestLoadFileFromEnv() {
+	path := s.writeFile("pocket.toml", `
+# pocket-message
+[search]
+index = "memory" # no FULLTEXT index
+key = 'C:\kunci'
+
+[random_id]
+length = 1_2
+`)
+	s.T().Setenv(FileEnv, path)
+	s.Require().NoError(os.Unsetenv("SearchIndexKey"))
+
+	cfg, err := load()
+	s.NoError(err)
+	s.Equal("memory", cfg.Search.Index)
+	s.Equal(`C:\kunci`, cfg.Search.Key)
+	s.Equal(12, cfg.RandomID.Length)
+}
+func (s *ConfigSuite) TestLoadDurationSeconds() {
+	path := s.writeFile("pocket.yaml", `
+messages:
+  reaper_interval: 90
+  trash_retention: 0
+`)
+	cfg, err := load("-config", path)
+	s.NoError(err)
+	s.Equal(90*time.Second, cfg.Messages.ReaperInterval)
+	s.Equal(time.Duration(0), cfg.Messages.TrashRetention)
+
+	path = s.writeFile("pocket.toml", "[messages]\nreaper_interval = 90\n")
+	cfg, err = load("-config", path, "-messages-trash-retention", "3600")
+	s.NoError(err)
+	s.Equal(90*time.Second, cfg.Messages.ReaperInterval)
+	s.Equal(time.Hour, cfg.Messages.TrashRetention)
+}
+func (s *ConfigSuite) TestLoadMemory() {
+	s.T().Setenv("DB_DRIVER", "memory")
+	s.Require().NoError(os.Unsetenv("SearchIndexKey"))
+
+	// The memory driver always searches with the memory index.
+	cfg, err := load()
+	s.NoError(err)
+	s.Equal("memory", cfg.Database.Driver)
+}
+func (s *ConfigSuite) TestLoadSQLite() {
+	s.T().Setenv("DB_DRIVER", "sqlite")
+
+	cfg, err := load("-database-name", "pocket.db", "-database-user", "", "-search-index", "memory")
+	s.NoError(err)
+	s.Equal(Database{Driver: "sqlite", Name: "pocket.db", Password: "sandi"}, cfg.Database)
+}
+func (s *ConfigSuite) TestLoadError() {
+	testCase := []struct {
+		name        string
+		file        string
+		content     string
+		args        []string
+		expectError string
+	}{
+		{
+			name:        "load-error_unknown_setting",
+			file:        "pocket.yaml",
+			content:     "api:\n  prot: \":9000\"\n",
+			expectError: `pocket.yaml: unknown setting "api.prot"`,
+		},
+		{
+			name:        "load-error_duration",
+			args:        []string{"-messages-reaper-interval", "often"},
+			expectError: `error, flags: messages.reaper_interval: time: invalid duration "often"`,
+		},
+		{
+			name:        "load-error_nested",
+			file:        "pocket.yaml",
+			content:     "api:\n  port:\n    number: 9000\n",
+			expectError: "error, config file: api.port should be a single value",
+		},
+		{
+			name:        "load-error_toml_value",
+			file:        "pocket.toml",
+			content:     "[api]\nport = [9000]\n",
+			expectError: "error, config file: api.port should be a single value",
+		},
+		{
+			name:        "load-error_toml_outside_table",
+			file:        "pocket.toml",
+			content:     "port = \":9000\"\n",
+			expectError: "error, config file: port should be a section of settings",
+		},
+		{
+			name:        "load-error_toml_syntax",
+			file:        "pocket.toml",
+			content:     "[api]\nport = \":9000\n",
+			expectError: "error, config file: toml:",
+		},
+		{
+			name:        "load-error_extension",
+			file:        "pocket.json",
+			content:     "{}",
+			expectError: "should be .yaml, .yml or .toml",
+		},
+		{
+			name:        "load-error_driver",
+			args:        []string{"-database-driver", "oracle"},
+			expectError: "error, database.driver should be mysql, postgres, sqlite or memory",
+		},
+		{
+			name:        "load-error_sqlite_without_file",
+			args:        []string{"-database-driver", "sqlite", "-search-index", "memory"},
+			expectError: "error, database.name should be the database file with sqlite",
+		},
+		{
+			name:        "load-error_fulltext_without_mysql",
+			args:        []string{"-database-driver", "postgres"},
+			expectError: "error, search.index mysql needs database.driver mysql, use memory",
+		},
+		{
+			name: "load-error_validation",
+			args: []string{"-api-port", "", "-search-index", "elastic", "-password-algorithm", "md5", "-messages-trash-retention", "-1h"},
+			expectError: "error, api.port should not be empty; error, unknown password hash algorithm; " +
+				"error, messages.trash_retention should not be negative; error, search.index should be mysql or memory",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			args := v.args
+			if v.file != "" {
+				path := s.writeFile(v.file, v.content)
+				args = append([]string{"-config", path}, args...)
+			}
+
+			_, err := load(args...)
+			if s.Error(err) {
+				s.Contains(err.Error(), v.expectError)
+			}
+		})
+	}
+}
+
+// Redacted
+func (s *ConfigSuite) TestRedacted() {
+	cfg := withSecrets(Default())
+	cfg.Encryption.Keys = "k1:a2V5"
+	redacted := cfg.Redacted()
+
+	s.Equal(RedactedValue, redacted.Auth.TokenSecret)
+	s.Equal(RedactedValue, redacted.Database.Password)
+	s.Equal(RedactedValue, redacted.Encryption.Keys)
+	s.Equal("", redacted.Auth.JWTKeys)
+	s.Equal("pocket", redacted.Database.User)
+	// The original keeps its secrets.
+	s.Equal("k1:a2V5", cfg.Encryption.Keys)
+}
+
+// WriteYAML
+func (s *ConfigSuite) TestWriteYAMLLoadsBack() {
+	cfg := withSecrets(Default())
+	cfg.API.Port = ":9000"
+	cfg.Password.Cost = 12
+	cfg.Messages.TrashRetention = 0
+	cfg.Username.Pattern = `^[a-z]+: #$`
+
+	var buf bytes.Buffer
+	s.NoError(cfg.WriteYAML(&buf))
+	s.Contains(buf.String(), "api:\n  port: :9000\ndatabase:\n")
+
+	loaded, err := load("-config", s.writeFile("pocket.yaml", buf.String()))
+	s.NoError(err)
+	s.Equal(cfg, *loaded)
+}
@@ -0,0 +1,96 @@
+package configs
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// WriteYAML writes the configuration in the format of a configuration file.
+// Print the Redacted copy unless the secrets are meant to be shown.
+func (c Config) WriteYAML(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	for _, s := range settings {
+		section, name, _ := strings.Cut(s.key, ".")
+		node, ok := sections[section]
+		if !ok {
+			node = &yaml.Node{Kind: yaml.MappingNode}
+			sections[section] = node
+			root.Content = append(root.Content, scalar("!!str", section), node)
+		}
+
+		var value *yaml.Node
+		switch p := s.field(&c).(type) {
+		case *string:
+			value = scalar("!!str", *p)
+		case *int:
+			value = scalar("!!int", strconv.Itoa(*p))
+		case *time.Duration:
+			value = scalar("!!str", p.String())
+		}
+		node.Content = append(node.Content, scalar("!!str", name), value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err := enc.Encode(root)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func scalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// decodeYAML flattens the sections of a YAML file into setting keys.
+func decodeYAML(data []byte) (map[string]string, error) {
+	var doc map[string]interface{}
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("error, config file: %w", err)
+	}
+	return flatten(doc)
+}
+
+// decodeTOML flattens the tables of a TOML file into setting keys.
+func decodeTOML(data []byte) (map[string]string, error) {
+	var doc map[string]interface{}
+	err := toml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("error, config file: %w", err)
+	}
+	return flatten(doc)
+}
+
+// flatten turns the sections of a decoded file into setting keys,
+// "section.name".
+func flatten(doc map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string)
+	for section, entries := range doc {
+		if entries == nil {
+			continue
+		}
+		table, ok := entries.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error, config file: %s should be a section of settings", section)
+		}
+		for name, value := range table {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}, []map[string]interface{}:
+				return nil, fmt.Errorf("error, config file: %s.%s should be a single value", section, name)
+			case nil:
+				value = ""
+			}
+			values[section+"."+name] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
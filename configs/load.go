@@ -0,0 +1,191 @@
+package configs
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the environment variable with the path of the
+// configuration file, when the -config flag is not given.
+const FileEnv = "ConfigFile"
+
+// setting is one configuration value. key names it in files, "section.name",
+// and as the flag "section-name"; env keeps the variable names the server
+// has always read.
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	field  func(*Config) interface{}
+}
+
+var settings = []setting{
+	{"api.port", "APIPort", "address the API listens on", false, func(c *Config) interface{} { return &c.API.Port }},
+	{"database.driver", "DB_DRIVER", "database driver, mysql, postgres, sqlite or memory", false, func(c *Config) interface{} { return &c.Database.Driver }},
+	{"database.address", "DB_ADDRESS", "database host:port", false, func(c *Config) interface{} { return &c.Database.Address }},
+	{"database.name", "DB_NAME", "database name, the database file with sqlite", false, func(c *Config) interface{} { return &c.Database.Name }},
+	{"database.user", "DB_USER", "database user", false, func(c *Config) interface{} { return &c.Database.User }},
+	{"database.password", "DB_PASSWORD", "database password", true, func(c *Config) interface{} { return &c.Database.Password }},
+	{"auth.token_secret", "TokenSecret", "HS256 token secret, used without auth.jwt_keys", true, func(c *Config) interface{} { return &c.Auth.TokenSecret }},
+	{"auth.jwt_keys", "JWTKeys", `token signing keys, a comma separated "id:alg:value" list`, true, func(c *Config) interface{} { return &c.Auth.JWTKeys }},
+	{"auth.jwt_key_id", "JWTKeyID", "id of the key new tokens are signed with", false, func(c *Config) interface{} { return &c.Auth.JWTKeyID }},
+	{"auth.access_token_ttl", "AccessTokenTTL", "how long an access token is valid", false, func(c *Config) interface{} { return &c.Auth.AccessTokenTTL }},
+	{"auth.refresh_token_ttl", "RefreshTokenTTL", "how long a refresh token can be used", false, func(c *Config) interface{} { return &c.Auth.RefreshTokenTTL }},
+	{"auth.password_reset_token_ttl", "PasswordResetTokenTTL", "how long a password reset token can be used", false, func(c *Config) interface{} { return &c.Auth.PasswordResetTokenTTL }},
+	{"encryption.keys", "EncryptionKeys", `message encryption keys, a comma separated "id:base64key" list`, true, func(c *Config) interface{} { return &c.Encryption.Keys }},
+	{"encryption.key_id", "EncryptionKeyID", "id of the key new messages are encrypted with", false, func(c *Config) interface{} { return &c.Encryption.KeyID }},
+	{"password.algorithm", "PasswordHashAlgorithm", "password hash, bcrypt or argon2id", false, func(c *Config) interface{} { return &c.Password.Algorithm }},
+	{"password.cost", "PasswordHashCost", "bcrypt cost or argon2id passes, 0 for the default", false, func(c *Config) interface{} { return &c.Password.Cost }},
+	{"username.min_length", "UsernameMinLength", "shortest username, 0 for the default", false, func(c *Config) interface{} { return &c.Username.MinLength }},
+	{"username.max_length", "UsernameMaxLength", "longest username, 0 for the default", false, func(c *Config) interface{} { return &c.Username.MaxLength }},
+	{"username.pattern", "UsernamePattern", "pattern normalized usernames match", false, func(c *Config) interface{} { return &c.Username.Pattern }},
+	{"username.reserved", "UsernameReserved", "comma separated usernames nobody can sign up with", false, func(c *Config) interface{} { return &c.Username.Reserved }},
+	{"random_id.alphabet", "RandomIDAlphabet", "characters of generated random ids", false, func(c *Config) interface{} { return &c.RandomID.Alphabet }},
+	{"random_id.length", "RandomIDLength", "length of generated random ids, 0 for the default", false, func(c *Config) interface{} { return &c.RandomID.Length }},
+	{"messages.reaper_interval", "ReaperInterval", "how often expired messages are cleaned up", false, func(c *Config) interface{} { return &c.Messages.ReaperInterval }},
+	{"messages.trash_retention", "TrashRetention", "how long deleted messages can be restored, 0 to keep them", false, func(c *Config) interface{} { return &c.Messages.TrashRetention }},
+	{"messages.passphrase_max_attempts", "PassphraseMaxAttempts", "wrong passphrases before a link is locked", false, func(c *Config) interface{} { return &c.Messages.PassphraseMaxAttempts }},
+	{"messages.passphrase_lock_duration", "PassphraseLockDuration", "how long a link stays locked", false, func(c *Config) interface{} { return &c.Messages.PassphraseLockDuration }},
+	{"messages.random_id_max_attempts", "RandomIDMaxAttempts", "generated random ids tried before giving up on collisions", false, func(c *Config) interface{} { return &c.Messages.RandomIDMaxAttempts }},
+	{"messages.view_ip_salt", "ViewIPSalt", "key of the hashed reader IP addresses", true, func(c *Config) interface{} { return &c.Messages.ViewIPSalt }},
+	{"search.index", "SearchIndex", "search index, mysql or memory", false, func(c *Config) interface{} { return &c.Search.Index }},
+	{"search.key", "SearchIndexKey", "key of the hashed indexed words", true, func(c *Config) interface{} { return &c.Search.Key }},
+	{"notifier.file", "NotifierFile", "file password reset tokens are written to, the log when empty", false, func(c *Config) interface{} { return &c.Notifier.File }},
+}
+
+// Load reads the configuration from its defaults, the file named by the
+// -config flag or FileEnv, the environment and the flags in args, in that
+// order of precedence, and validates it. Callers can define their own flags
+// on fs before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	file := fs.String("config", "", "YAML or TOML configuration file, $"+FileEnv+" when empty")
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flags[s.key] = fs.String(flagName(s.key), "", fmt.Sprintf("%s ($%s)", s.usage, s.env))
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	path := *file
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		err = cfg.apply(values, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string]string)
+	for _, s := range settings {
+		v, ok := os.LookupEnv(s.env)
+		if ok {
+			values[s.key] = v
+		}
+	}
+	err = cfg.apply(values, "environment")
+	if err != nil {
+		return nil, err
+	}
+
+	values = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if f.Name == flagName(s.key) {
+				values[s.key] = *flags[s.key]
+			}
+		}
+	})
+	err = cfg.apply(values, "flags")
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// apply sets the values of source, keyed like settings.
+func (c *Config) apply(values map[string]string, source string) error {
+	for key, value := range values {
+		s, ok := lookup(key)
+		if !ok {
+			return fmt.Errorf("error, %s: unknown setting %q", source, key)
+		}
+		err := set(s.field(c), value)
+		if err != nil {
+			return fmt.Errorf("error, %s: %s: %w", source, key, err)
+		}
+	}
+	return nil
+}
+
+func set(field interface{}, value string) error {
+	var err error
+	switch p := field.(type) {
+	case *string:
+		*p = value
+	case *int:
+		*p, err = strconv.Atoi(value)
+	case *time.Duration:
+		*p, err = parseDuration(value)
+	default:
+		panic(fmt.Sprintf("configs: unsupported field %T", field))
+	}
+	return err
+}
+
+// parseDuration reads a Go duration, such as "90s" or "1h30m". A plain
+// number, as YAML and TOML files give without quotes, is seconds.
+func parseDuration(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func lookup(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// readFile reads a configuration file, YAML or TOML by its extension.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error, config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return decodeYAML(data)
+	case ".toml":
+		return decodeTOML(data)
+	}
+	return nil, fmt.Errorf("error, config file %s should be .yaml, .yml or .toml", path)
+}
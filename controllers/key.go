@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"net/http"
+	"pocket-message/pkg/jwtkeys"
+
+	"github.com/labstack/echo/v4"
+)
+
+func NewKeyHandler(ks *jwtkeys.KeySet) KeyHandler {
+	return &keyHandler{KeySet: ks}
+}
+
+type KeyHandler interface {
+	JWKS(echo.Context) error
+}
+
+type keyHandler struct {
+	*jwtkeys.KeySet
+}
+
+func (h *keyHandler) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.KeySet.JWKS())
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pocket-message/pkg/jwtkeys"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+type KeySuite struct {
+	suite.Suite
+	handler KeyHandler
+}
+
+func (s *KeySuite) SetupSuite() {
+	s.handler = NewKeyHandler(jwtkeys.FromSecret("secret"))
+}
+
+func (s *KeySuite) TearDownSuite() {
+}
+
+func TestSuiteKey(t *testing.T) {
+	suite.Run(t, new(KeySuite))
+}
+
+// JWKS
+func (s *KeySuite) TestJWKS() {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	c := echo.New().NewContext(r, w)
+	c.SetPath("/.well-known/jwks.json")
+
+	if s.NoError(serve(s.handler.JWKS, c)) {
+		var resp jwtkeys.JWKS
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			s.Error(err, "error unmarshalling")
+		}
+
+		// HS256 secrets must never be published.
+		s.Equal(http.StatusOK, w.Result().StatusCode)
+		s.Empty(resp.Keys)
+	}
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/markdown"
+	"pocket-message/pkg/sanitize"
+	"pocket-message/services"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// messagePageHeaders keep a shared message from loading anything, being
+// framed or cached, and from leaking its link to the sites it links to.
+var messagePageHeaders = map[string]string{
+	"Content-Security-Policy": "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'",
+	"Referrer-Policy":         "no-referrer",
+	"Cache-Control":           "no-store",
+	"X-Content-Type-Options":  "nosniff",
+}
+
+var messagePage = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Pocket Message{{end}}</title>
+<style>
+body { font: 16px/1.6 system-ui, sans-serif; color: #222; background: #f6f6f4; margin: 0; }
+main { max-width: 42rem; margin: 2rem auto; padding: 1.5rem 2rem; background: #fff; border-radius: 8px; box-shadow: 0 1px 3px rgba(0,0,0,.1); }
+h1.title { font-size: 1.6rem; margin-top: 0; }
+.plain { white-space: pre-wrap; overflow-wrap: anywhere; }
+pre { background: #f3f3f3; padding: .75rem; overflow-x: auto; }
+blockquote { border-left: 4px solid #ddd; margin-left: 0; padding-left: 1rem; color: #555; }
+.notice { color: #555; }
+input, button { font: inherit; padding: .4rem .6rem; }
+</style>
+</head>
+<body>
+<main>
+{{if .Title}}<h1 class="title">{{.Title}}</h1>{{end}}
+{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
+{{if .Open}}<form method="post">
+<button type="submit">Open message</button>
+</form>{{end}}
+{{if .Passphrase}}<form method="post">
+<label>Passphrase <input type="password" name="passphrase" autocomplete="off" autofocus required></label>
+<button type="submit">Open</button>
+</form>{{end}}
+{{if .Markdown}}<div class="content">{{.Markdown}}</div>{{end}}
+{{if .Plain}}<div class="content plain">{{.Plain}}</div>{{end}}
+</main>
+</body>
+</html>
+`))
+
+type messagePageData struct {
+	Title    string
+	Notice   string
+	Plain    string
+	Markdown template.HTML
+	// Open shows the button that opens the message.
+	Open bool
+	// Passphrase shows the form to open a protected message.
+	Passphrase bool
+}
+
+// wantsHTML reports whether the client prefers a page to JSON, as browsers
+// opening a shared link do.
+func wantsHTML(c echo.Context) bool {
+	htmlQ, jsonQ := 0.0, 0.0
+	for _, accepted := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case echo.MIMETextHTML:
+			htmlQ = q
+		case echo.MIMEApplicationJSON:
+			jsonQ = q
+		}
+	}
+	return htmlQ > 0 && htmlQ > jsonQ
+}
+
+// renderOpenPage answers with the page that asks before opening a shared
+// message. Link previews and prefetching browsers follow links on their own,
+// so only the POST sent by its button reads the message and counts the visit.
+func renderOpenPage(c echo.Context) error {
+	return renderPage(c, http.StatusOK, messagePageData{
+		Notice: "Someone shared a message with you. It may be deleted once it is opened.",
+		Open:   true,
+	})
+}
+
+// renderMessagePage answers with the page of a shared message, or of the
+// error that kept it from being read.
+func renderMessagePage(c echo.Context, result dto.PocketMessageWithRandomID, err error) error {
+	if err != nil {
+		status, data := errorPage(c, err)
+		return renderPage(c, status, data)
+	}
+	return renderPage(c, http.StatusOK, messagePageOf(result))
+}
+
+func renderPage(c echo.Context, status int, data messagePageData) error {
+	var buf bytes.Buffer
+	err := messagePage.Execute(&buf, data)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range messagePageHeaders {
+		c.Response().Header().Set(k, v)
+	}
+	return c.HTMLBlob(status, buf.Bytes())
+}
+
+func messagePageOf(pm dto.PocketMessageWithRandomID) messagePageData {
+	data := messagePageData{Title: pm.Title}
+	var notices []string
+	switch {
+	case pm.Encrypted:
+		// The key is in the fragment of the link, which only a client that
+		// supports encrypted messages reads.
+		notices = append(notices, "This message is end-to-end encrypted and can only be read with a client that supports encrypted messages.")
+	case pm.Format == models.FormatMarkdown:
+		data.Markdown = template.HTML(sanitize.Markdown.Sanitize(markdown.Render(pm.Content)))
+	default:
+		data.Plain = pm.Content
+	}
+	if pm.BurnAfterRead {
+		notices = append(notices, "This message was deleted after this reading. Copy anything you need before leaving the page.")
+	}
+	data.Notice = strings.Join(notices, " ")
+	return data
+}
+
+func errorPage(c echo.Context, err error) (int, messagePageData) {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code, messagePageData{Notice: "The request could not be read. Try opening the link again."}
+	}
+
+	e := apperr.As(err)
+	status := apperr.Status(e.Kind)
+	switch e.Kind {
+	case apperr.KindNotFound, apperr.KindGone:
+		return status, messagePageData{Notice: "This message does not exist or is no longer available."}
+	case apperr.KindUnauthorized:
+		notice := "This message is protected. Enter its passphrase to read it."
+		if errors.Is(err, services.ErrPassphraseInvalid) {
+			notice = "The passphrase is not correct. Try again."
+		}
+		return status, messagePageData{Notice: notice, Passphrase: true}
+	case apperr.KindLocked:
+		return status, messagePageData{Notice: "Too many wrong passphrases were tried. Try again later."}
+	case apperr.KindInternal:
+		c.Logger().Error(err)
+	}
+	return status, messagePageData{Notice: "Something went wrong. Try again later."}
+}
@@ -1,79 +1,215 @@
 package controllers
 
 import (
-	"errors"
+	"context"
 	"pocket-message/dto"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/diff"
+	"pocket-message/services"
 
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
 )
 
 type MockPocketMessageServices struct{}
 
-func (s *MockPocketMessageServices) NewPocketMessage(c echo.Context) error {
-	var pm models.PocketMessage
-	err := c.Bind(&pm)
-	if err != nil {
-		return err
-	}
-
-	if pm.Title == "" {
-		return errors.New("error, title should not be empty")
+func (s *MockPocketMessageServices) NewPocketMessage(ctx context.Context, req dto.NewPocketMessage) error {
+	if req.Title == "" {
+		return apperr.Validation("error, title should not be empty")
 	}
-	if pm.Content == "" {
-		return errors.New("error, content should not be empty")
+	if req.Content == "" {
+		return apperr.Validation("error, content should not be empty")
 	}
 
 	return nil
 }
-func (s *MockPocketMessageServices) GetPocketMessageByRandomID(c echo.Context) (dto.PocketMessageWithRandomID, error) {
-	rid := c.Param("random_id")
+func (s *MockPocketMessageServices) GetPocketMessageByRandomID(ctx context.Context, req dto.ReadPocketMessage) (dto.PocketMessageWithRandomID, error) {
+	rid := req.RandomID
 	if rid == "" {
-		return dto.PocketMessageWithRandomID{}, errors.New("error, random_id parameter can not be empty")
+		return dto.PocketMessageWithRandomID{}, apperr.Validation("error, random_id parameter can not be empty")
+	}
+	if rid == "expired" {
+		return dto.PocketMessageWithRandomID{}, services.ErrPocketMessageExpired
+	}
+	if rid == "protected" {
+		switch req.Passphrase {
+		case "":
+			return dto.PocketMessageWithRandomID{}, services.ErrPassphraseRequired
+		case "rahasia":
+			return dto.PocketMessageWithRandomID{Title: "Ini Test", Content: "Ini juga Test"}, nil
+		}
+		return dto.PocketMessageWithRandomID{}, services.ErrPassphraseInvalid
+	}
+	if rid == "locked" {
+		return dto.PocketMessageWithRandomID{}, services.ErrPocketMessageLocked
+	}
+	if rid == "markdown" {
+		return dto.PocketMessageWithRandomID{
+			Title:   "Ini <Test>",
+			Content: "**Ini** juga [Test](https://example.com)<script>alert(1)</script>",
+			Format:  models.FormatMarkdown,
+		}, nil
+	}
+	if rid == "encrypted" {
+		return dto.PocketMessageWithRandomID{Title: "Ini Test", Content: "eyJ2IjoxfQ", Encrypted: true, BurnAfterRead: true}, nil
 	}
 
 	return dto.PocketMessageWithRandomID{Title: "Ini Test", Content: "Ini juga Test"}, nil
 }
-func (s *MockPocketMessageServices) UpdatePocketMessage(c echo.Context) error {
-	var pm models.PocketMessage
-	err := c.Bind(&pm)
-	if err != nil {
-		return err
+func (s *MockPocketMessageServices) UpdatePocketMessage(ctx context.Context, req dto.UpdatePocketMessage) error {
+	if req.Title == "" {
+		return apperr.Validation("error, title should not be empty")
+	}
+	if req.Content == "" {
+		return apperr.Validation("error, content should not be empty")
 	}
 
-	if pm.Title == "" {
-		return errors.New("error, title should not be empty")
+	return ownerError(req.UUID)
+}
+func (s *MockPocketMessageServices) DeletePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	return ownerError(msgID)
+}
+func (s *MockPocketMessageServices) GetUserPocketMessage(ctx context.Context, req dto.ListOwnedMessages) (dto.OwnedMessagePage, error) {
+	_, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return dto.OwnedMessagePage{}, err
+	}
+	if req.Sort == "title" {
+		return dto.OwnedMessagePage{}, apperr.Validation("error, sort should be created, updated or visits")
 	}
-	if pm.Content == "" {
-		return errors.New("error, content should not be empty")
+	if req.Status == "maybe" {
+		return dto.OwnedMessagePage{}, apperr.Validation("error, status should be active, expired or revoked")
+	}
+	return dto.OwnedMessagePage{
+		Messages: []dto.OwnedMessage{
+			{
+				Title:   "halo dunia",
+				Content: "halo kamu",
+				Visit:   1,
+			},
+		},
+		NextCursor: "eyJpZCI6MX0",
+		Total:      2,
+	}, nil
+}
+func (s *MockPocketMessageServices) CreateShareLink(ctx context.Context, msgID uuid.UUID, req dto.NewShareLink) (dto.ShareLink, error) {
+	if req.Slug == "taken-slug" {
+		return dto.ShareLink{}, services.ErrSlugTaken
+	}
+	err := ownerError(msgID)
+	if err != nil {
+		return dto.ShareLink{}, err
 	}
 
-	pm.UUID, err = uuid.Parse(c.Param("uuid"))
+	return dto.ShareLink{
+		RandomID: "asdfghjkl",
+		Label:    req.Label,
+		MaxVisit: req.MaxVisit,
+	}, nil
+}
+func (s *MockPocketMessageServices) GetShareLinks(ctx context.Context, msgID uuid.UUID) ([]dto.ShareLink, error) {
+	err := ownerError(msgID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return []dto.ShareLink{
+		{RandomID: "asdfghjkl", Label: "family", Visit: 2},
+	}, nil
 }
-func (s *MockPocketMessageServices) DeletePocketMessage(c echo.Context) error {
-	_, err := uuid.Parse(c.Param("uuid"))
+func (s *MockPocketMessageServices) RevokeShareLink(ctx context.Context, msgID uuid.UUID, randomID string) error {
+	if randomID == "missing" {
+		return services.ErrShareLinkNotFound
+	}
+	return ownerError(msgID)
+}
+func (s *MockPocketMessageServices) GetPocketMessageStats(ctx context.Context, req dto.StatsRequest) (dto.MessageStats, error) {
+	if req.Bucket == "week" {
+		return dto.MessageStats{}, apperr.Validation("error, bucket should be hour or day")
+	}
+	err := ownerError(req.UUID)
 	if err != nil {
-		return err
+		return dto.MessageStats{}, err
 	}
-	return nil
+
+	return dto.MessageStats{
+		TotalVisits: 3,
+		Views:       3,
+		Bucket:      "day",
+	}, nil
 }
-func (s *MockPocketMessageServices) GetUserPocketMessage(c echo.Context) ([]dto.OwnedMessage, error) {
-	_, err := middleware.DecodeJWT(c)
+func (s *MockPocketMessageServices) SearchPocketMessages(ctx context.Context, req dto.SearchPocketMessages) ([]dto.SearchResult, error) {
+	_, err := authz.PrincipalFrom(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return []dto.OwnedMessage{
+	if req.Query == "" {
+		return nil, apperr.Validation("error, q should have a word of at least 2 characters")
+	}
+	return []dto.SearchResult{
 		{
-			Title:   "halo dunia",
-			Content: "halo kamu",
-			Visit:   1,
+			UUID:    uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+			Title:   "halo <mark>dunia</mark>",
+			Snippet: "halo kamu",
+			Score:   2,
 		},
 	}, nil
 }
+func (s *MockPocketMessageServices) GetPocketMessageRevisions(ctx context.Context, msgID uuid.UUID) ([]dto.Revision, error) {
+	err := ownerError(msgID)
+	if err != nil {
+		return nil, err
+	}
+	return []dto.Revision{
+		{Number: 1, Title: "halo dunia", Content: "halo kamu"},
+		{Number: 2, Title: "halo dunia", Content: "halo semua"},
+	}, nil
+}
+func (s *MockPocketMessageServices) DiffPocketMessageRevisions(ctx context.Context, req dto.RevisionDiffRequest) (dto.RevisionDiff, error) {
+	if req.To == 9 {
+		return dto.RevisionDiff{}, services.ErrRevisionNotFound
+	}
+	err := ownerError(req.UUID)
+	if err != nil {
+		return dto.RevisionDiff{}, err
+	}
+	return dto.RevisionDiff{
+		From:    1,
+		To:      2,
+		Title:   []diff.Line{{Op: diff.OpEqual, Text: "halo dunia"}},
+		Content: []diff.Line{{Op: diff.OpDelete, Text: "halo kamu"}, {Op: diff.OpInsert, Text: "halo semua"}},
+	}, nil
+}
+func (s *MockPocketMessageServices) RestorePocketMessageRevision(ctx context.Context, msgID uuid.UUID, n int) error {
+	if n == 9 {
+		return services.ErrRevisionNotFound
+	}
+	return ownerError(msgID)
+}
+func (s *MockPocketMessageServices) GetTrashedPocketMessages(ctx context.Context) ([]dto.TrashedMessage, error) {
+	_, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []dto.TrashedMessage{
+		{Title: "halo dunia"},
+	}, nil
+}
+func (s *MockPocketMessageServices) RestorePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	return ownerError(msgID)
+}
+func (s *MockPocketMessageServices) PurgePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	return ownerError(msgID)
+}
+
+// ownerError fails for the "...0403" message of another user and the unknown "...0404" message.
+func ownerError(id uuid.UUID) error {
+	switch id.String() {
+	case "00000000-0000-0000-0000-000000000403":
+		return authz.ErrForbidden
+	case "00000000-0000-0000-0000-000000000404":
+		return services.ErrPocketMessageNotFound
+	}
+	return nil
+}
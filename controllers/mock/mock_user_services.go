@@ -1,39 +1,34 @@
 package controllers
 
 import (
-	"errors"
+	"context"
 	"pocket-message/dto"
-	"pocket-message/models"
-
-	"github.com/labstack/echo/v4"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/services"
 )
 
 type MockUserServices struct{}
 
-func (s *MockUserServices) SignUp(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
-	if err != nil {
-		return err
+func (s *MockUserServices) SignUp(ctx context.Context, req dto.SignUp) error {
+	if req.Password == "" {
+		return apperr.Validation("error, password should not be empty")
 	}
-
-	if u.Password == "" {
-		return errors.New("error, password should not be empty")
+	if req.Username == "doraemon" {
+		return services.ErrUsernameTaken
 	}
 
 	return nil
 }
-func (s *MockUserServices) Login(c echo.Context) (dto.Login, error) {
-	var u models.User
-	err := c.Bind(&u)
-	if err != nil {
-		return dto.Login{}, err
+func (s *MockUserServices) Login(ctx context.Context, req dto.Credentials) (dto.Login, error) {
+	if req.Username == "" {
+		return dto.Login{}, apperr.Validation("error, username should not be empty")
 	}
-	if u.Username == "" {
-		return dto.Login{}, errors.New("error, username should not be empty")
+	if req.Password == "" {
+		return dto.Login{}, apperr.Validation("error, password should not be empty")
 	}
-	if u.Password == "" {
-		return dto.Login{}, errors.New("error, password should not be empty")
+	if req.Password == "salah" {
+		return dto.Login{}, services.ErrInvalidCredentials
 	}
 
 	return dto.Login{
@@ -41,31 +36,61 @@ func (s *MockUserServices) Login(c echo.Context) (dto.Login, error) {
 		Token:    "Idol",
 	}, nil
 }
-func (s *MockUserServices) UpdateUsername(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
-	if err != nil {
-		return err
+func (s *MockUserServices) UpdateUsername(ctx context.Context, req dto.UpdateUsername) error {
+	if req.Username == "" {
+		return apperr.Validation("error, username should not be empty")
 	}
-	if u.Username == "" {
-		return errors.New("error, username should not be empty")
+	if req.Username == "doraemon" {
+		return services.ErrUsernameTaken
 	}
 
 	return nil
 }
-func (s *MockUserServices) UpdatePassword(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
-	if err != nil {
-		return err
+func (s *MockUserServices) RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error {
+	if req.Username == "" {
+		return apperr.Validation("error, username should not be empty")
 	}
 
-	if u.Username == "" {
-		return errors.New("error, username should not be empty")
+	return nil
+}
+func (s *MockUserServices) ResetPassword(ctx context.Context, req dto.PasswordResetConfirm) error {
+	if req.Token != "valid-token" {
+		return services.ErrInvalidResetToken
 	}
-	if u.Password == "" {
-		return errors.New("error, password should not be empty")
+
+	return nil
+}
+func (s *MockUserServices) ChangePassword(ctx context.Context, req dto.ChangePassword) error {
+	if req.NewPassword == "" {
+		return apperr.Validation("error, new_password should not be empty")
+	}
+	if req.CurrentPassword != "12345678" {
+		return services.ErrInvalidCredentials
 	}
 
 	return nil
 }
+func (s *MockUserServices) RefreshToken(ctx context.Context, req dto.RefreshToken) (dto.Login, error) {
+	switch req.RefreshToken {
+	case "":
+		return dto.Login{}, apperr.Validation("error, refresh_token should not be empty")
+	case "reused":
+		return dto.Login{}, services.ErrRefreshTokenReused
+	case "valid-refresh":
+		return dto.Login{
+			Username:     "Super",
+			Token:        "Idol",
+			RefreshToken: "Fresh",
+		}, nil
+	}
+
+	return dto.Login{}, services.ErrInvalidRefreshToken
+}
+func (s *MockUserServices) Logout(ctx context.Context) error {
+	_, err := authz.PrincipalFrom(ctx)
+	return err
+}
+func (s *MockUserServices) LogoutAll(ctx context.Context) error {
+	_, err := authz.PrincipalFrom(ctx)
+	return err
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"fmt"
+	"pocket-message/pkg/apperr"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// bind reads the request body into req and checks it with the validator of
+// the echo instance.
+func bind(c echo.Context, req interface{}) error {
+	err := c.Bind(req)
+	if err != nil {
+		return err
+	}
+	return c.Validate(req)
+}
+
+func paramUUID(c echo.Context) (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		return uuid.Nil, apperr.Validation("uuid invalid")
+	}
+	return id, nil
+}
+
+// queryInt reads an optional integer query parameter, 0 when missing.
+func queryInt(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, apperr.Validation(fmt.Sprintf("error, %s should be an integer", name))
+	}
+	return n, nil
+}
+
+func queryTime(c echo.Context, name string) (*time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, apperr.Validation(fmt.Sprintf("error, %s should be an RFC 3339 time", name))
+	}
+	return &t, nil
+}
@@ -8,9 +8,11 @@ import (
 	"net/http/httptest"
 	m "pocket-message/controllers/mock"
 	"pocket-message/dto"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -61,11 +63,11 @@ func (s *PocketMessageSuite) TestNewPocketMessage() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.NewPocketMessage(c)) {
+			if s.NoError(serve(s.handler.NewPocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -88,9 +90,10 @@ func (s *PocketMessageSuite) TestNewPocketMessageError() {
 		name          string
 		method        string
 		path          string
-		body          models.PocketMessage
+		body          interface{}
 		expectCode    int
 		expectMessage string
+		expectFields  []apperr.FieldError
 	}{
 		{
 			name:   "new_pocket_message-error",
@@ -100,8 +103,42 @@ func (s *PocketMessageSuite) TestNewPocketMessageError() {
 				Title:   "",
 				Content: "iya kamu",
 			},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, title should not be empty",
+			expectFields: []apperr.FieldError{
+				{Field: "title", Message: "error, title should not be empty"},
+			},
+		},
+		{
+			name:   "new_pocket_message-error_every_field",
+			method: http.MethodPost,
+			path:   "/api/v1/pocket-messages",
+			body: models.PocketMessage{
+				Title:  strings.Repeat("a", 256),
+				Format: "html",
+			},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, title should be at most 255 characters",
+			expectFields: []apperr.FieldError{
+				{Field: "title", Message: "error, title should be at most 255 characters"},
+				{Field: "content", Message: "error, content should not be empty"},
+				{Field: "format", Message: "error, format should be plain or markdown"},
+			},
+		},
+		{
+			name:   "new_pocket_message-error_passphrase_too_long",
+			method: http.MethodPost,
+			path:   "/api/v1/pocket-messages",
+			body: dto.NewPocketMessage{
+				Title:      "yes",
+				Content:    "no",
+				Passphrase: strings.Repeat("a", 73),
+			},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, passphrase should be at most 72 bytes",
+			expectFields: []apperr.FieldError{
+				{Field: "passphrase", Message: "error, passphrase should be at most 72 bytes"},
+			},
 		},
 	}
 	for _, v := range testCase {
@@ -110,15 +147,16 @@ func (s *PocketMessageSuite) TestNewPocketMessageError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.NewPocketMessage(c)) {
+			if s.NoError(serve(s.handler.NewPocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
-					Message string `json:"message"`
+					Message string              `json:"message"`
+					Errors  []apperr.FieldError `json:"errors"`
 				}
 				var resp response
 				err := json.Unmarshal(body, &resp)
@@ -128,6 +166,7 @@ func (s *PocketMessageSuite) TestNewPocketMessageError() {
 
 				s.Equal(v.expectCode, w.Result().StatusCode)
 				s.Equal(v.expectMessage, resp.Message)
+				s.Equal(v.expectFields, resp.Errors)
 			}
 		})
 	}
@@ -160,13 +199,13 @@ func (s *PocketMessageSuite) TestGetPocketMessageByRandomID() {
 
 			r := httptest.NewRequest(v.method, "/", nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.SetParamNames("random_id")
 			c.SetParamValues("ini_param_test")
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.GetPocketMessageByRandomID(c)) {
+			if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -204,7 +243,7 @@ func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDError() {
 				Title:   "",
 				Content: "",
 			},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, random_id parameter can not be empty",
 		},
 	}
@@ -213,13 +252,13 @@ func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDError() {
 
 			r := httptest.NewRequest(v.method, "/", nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.SetParamNames("random_id")
 			c.SetParamValues("")
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.GetPocketMessageByRandomID(c)) {
+			if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -240,6 +279,127 @@ func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDError() {
 		})
 	}
 }
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorStatus() {
+	testCase := []struct {
+		name          string
+		method        string
+		path          string
+		paramValue    string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "get_pocket_message_by_random_id-expired",
+			method:        http.MethodGet,
+			path:          "/api/v1/msg/:random_id",
+			paramValue:    "expired",
+			expectCode:    http.StatusGone,
+			expectMessage: "error, pocket message has expired",
+		},
+		{
+			name:          "get_pocket_message_by_random_id-passphrase_required",
+			method:        http.MethodGet,
+			path:          "/api/v1/msg/:random_id",
+			paramValue:    "protected",
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, passphrase is required",
+		},
+		{
+			name:          "get_pocket_message_by_random_id-locked",
+			method:        http.MethodGet,
+			path:          "/api/v1/msg/:random_id",
+			paramValue:    "locked",
+			expectCode:    http.StatusLocked,
+			expectMessage: "error, pocket message is temporarily locked",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(v.method, "/", nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath(v.path)
+			c.SetParamNames("random_id")
+			c.SetParamValues(v.paramValue)
+
+			if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
+				body := w.Body.Bytes()
+
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(body, &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDPassphrase() {
+	testCase := []struct {
+		name          string
+		header        string
+		form          string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "get_pocket_message_by_random_id-passphrase_header",
+			header:        "rahasia",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "get_pocket_message_by_random_id-passphrase_form",
+			form:          "passphrase=rahasia",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "get_pocket_message_by_random_id-passphrase_invalid",
+			header:        "salah",
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, passphrase is invalid",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.form))
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath("/api/v1/msg/:random_id")
+			c.SetParamNames("random_id")
+			c.SetParamValues("protected")
+			if v.header != "" {
+				c.Request().Header.Set("X-Passphrase", v.header)
+			}
+			if v.form != "" {
+				c.Request().Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
 
 // UpdatePocketMessage
 func (s *PocketMessageSuite) TestUpdatePocketMessage() {
@@ -276,13 +436,13 @@ func (s *PocketMessageSuite) TestUpdatePocketMessage() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetParamNames(v.paramName)
 			c.SetParamValues(v.paramValue)
 			c.Request().Header.Set("Content-Type", "application/json")
 			c.Request().Header.Set("test", "true")
 
-			if s.NoError(s.handler.UpdatePocketMessage(c)) {
+			if s.NoError(serve(s.handler.UpdatePocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -319,11 +479,37 @@ func (s *PocketMessageSuite) TestUpdatePocketMessageError() {
 				Title:   "",
 				Content: "apakah kamu sehat?",
 			},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, title should not be empty",
 			paramName:     "uuid",
 			paramValue:    "00000000-0000-0000-0000-000000000000",
 		},
+		{
+			name:   "update_pocket_message-error_not_owner",
+			method: http.MethodPut,
+			path:   "/api/v1/pocket-messages",
+			body: models.PocketMessage{
+				Title:   "untuk kamu",
+				Content: "apakah kamu sehat?",
+			},
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+			paramName:     "uuid",
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+		},
+		{
+			name:   "update_pocket_message-error_not_found",
+			method: http.MethodPut,
+			path:   "/api/v1/pocket-messages",
+			body: models.PocketMessage{
+				Title:   "untuk kamu",
+				Content: "apakah kamu sehat?",
+			},
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, pocket message not found",
+			paramName:     "uuid",
+			paramValue:    "00000000-0000-0000-0000-000000000404",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
@@ -334,13 +520,13 @@ func (s *PocketMessageSuite) TestUpdatePocketMessageError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetParamNames(v.paramName)
 			c.SetParamValues(v.paramValue)
 			c.Request().Header.Set("Content-Type", "application/json")
 			c.Request().Header.Set("test", "true")
 
-			if s.NoError(s.handler.UpdatePocketMessage(c)) {
+			if s.NoError(serve(s.handler.UpdatePocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -390,13 +576,13 @@ func (s *PocketMessageSuite) TestDeletePocketMessage() {
 
 			r := httptest.NewRequest(v.method, "/", nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetParamNames(v.paramName)
 			c.SetParamValues(v.paramValue)
 			c.Request().Header.Set("Content-Type", "application/json")
 			c.Request().Header.Set("test", "true")
 
-			if s.NoError(s.handler.DeletePocketMessage(c)) {
+			if s.NoError(serve(s.handler.DeletePocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -433,24 +619,42 @@ func (s *PocketMessageSuite) TestDeletePocketMessageError() {
 			// 	Title:   "untuk kamu",
 			// 	Content: "apakah kamu sehat?",
 			// },
-			expectCode:    http.StatusInternalServerError,
-			expectMessage: "invalid UUID length: 8",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "uuid invalid",
 			paramName:     "uuid",
 			paramValue:    "00000000",
 		},
+		{
+			name:          "delete_pocket_message-error_not_owner",
+			method:        http.MethodDelete,
+			path:          "/api/v1/pocket-messages",
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+			paramName:     "uuid",
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+		},
+		{
+			name:          "delete_pocket_message-error_not_found",
+			method:        http.MethodDelete,
+			path:          "/api/v1/pocket-messages",
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, pocket message not found",
+			paramName:     "uuid",
+			paramValue:    "00000000-0000-0000-0000-000000000404",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 
 			r := httptest.NewRequest(v.method, "/", nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetParamNames(v.paramName)
 			c.SetParamValues(v.paramValue)
 			c.Request().Header.Set("Content-Type", "application/json")
 			c.Request().Header.Set("test", "true")
 
-			if s.NoError(s.handler.DeletePocketMessage(c)) {
+			if s.NoError(serve(s.handler.DeletePocketMessage, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -478,6 +682,8 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessage() {
 		uuid          uuid.UUID
 		username      string
 		expectBody    []dto.OwnedMessage
+		expectCursor  string
+		expectTotal   int64
 		expectCode    int
 		expectMessage string
 	}{
@@ -494,6 +700,8 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessage() {
 					Visit:   1,
 				},
 			},
+			expectCursor:  "eyJpZCI6MX0",
+			expectTotal:   2,
 			expectCode:    http.StatusOK,
 			expectMessage: "success",
 		},
@@ -503,8 +711,8 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessage() {
 
 			r := httptest.NewRequest(v.method, "/", nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			tok, err := middleware.GetToken(v.uuid, v.username)
+			c := newEcho().NewContext(r, w)
+			tok, err := tokens.SignSessionToken(v.uuid, v.username, uuid.New())
 			if err != nil {
 				s.Error(err, "error get token")
 			}
@@ -512,12 +720,14 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessage() {
 			c.Request().Header.Set("Authorization", token)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.GetOwnedPocketMessage(c)) {
+			if s.NoError(serve(tokens.Principal()(s.handler.GetOwnedPocketMessage), c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
-					Message string             `json:"message"`
-					Data    []dto.OwnedMessage `json:"data"`
+					Message    string             `json:"message"`
+					Data       []dto.OwnedMessage `json:"data"`
+					NextCursor string             `json:"next_cursor"`
+					Total      int64              `json:"total"`
 				}
 				var resp response
 				err := json.Unmarshal(body, &resp)
@@ -528,6 +738,8 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessage() {
 				s.Equal(v.expectCode, w.Result().StatusCode)
 				s.Equal(v.expectMessage, resp.Message)
 				s.Equal(v.expectBody, resp.Data)
+				s.Equal(v.expectCursor, resp.NextCursor)
+				s.Equal(v.expectTotal, resp.Total)
 			}
 		})
 	}
@@ -537,6 +749,8 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessageError() {
 		name          string
 		method        string
 		path          string
+		query         string
+		auth          bool
 		uuid          uuid.UUID
 		username      string
 		expectBody    []dto.OwnedMessage
@@ -550,20 +764,72 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessageError() {
 			uuid:          uuid.New(),
 			username:      "udin",
 			expectBody:    nil,
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusUnauthorized,
 			expectMessage: "authorization header not found",
 		},
+		{
+			name:          "get_owned_pocket_message-error_sort",
+			method:        http.MethodGet,
+			path:          "/api/v1/pocket-messages",
+			query:         "sort=title",
+			auth:          true,
+			uuid:          uuid.New(),
+			username:      "udin",
+			expectBody:    nil,
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, sort should be created, updated or visits",
+		},
+		{
+			name:          "get_owned_pocket_message-error_limit",
+			method:        http.MethodGet,
+			path:          "/api/v1/pocket-messages",
+			query:         "limit=ten",
+			auth:          true,
+			uuid:          uuid.New(),
+			username:      "udin",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, limit should be an integer",
+		},
+		{
+			name:          "get_owned_pocket_message-error_created_from",
+			method:        http.MethodGet,
+			path:          "/api/v1/pocket-messages",
+			query:         "created_from=yesterday",
+			auth:          true,
+			uuid:          uuid.New(),
+			username:      "udin",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, created_from should be an RFC 3339 time",
+		},
+		{
+			name:          "get_owned_pocket_message-error_status",
+			method:        http.MethodGet,
+			path:          "/api/v1/pocket-messages",
+			query:         "status=maybe",
+			auth:          true,
+			uuid:          uuid.New(),
+			username:      "udin",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, status should be active, expired or revoked",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 
-			r := httptest.NewRequest(v.method, "/", nil)
+			r := httptest.NewRequest(v.method, "/?"+v.query, nil)
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.Request().Header.Set("Authorization", "")
+			if v.auth {
+				tok, err := tokens.SignSessionToken(v.uuid, v.username, uuid.New())
+				if err != nil {
+					s.Error(err, "error get token")
+				}
+				c.Request().Header.Set("Authorization", fmt.Sprintf("Bearer %s", tok))
+			}
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.GetOwnedPocketMessage(c)) {
+			if s.NoError(serve(tokens.Principal()(s.handler.GetOwnedPocketMessage), c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -583,3 +849,636 @@ func (s *PocketMessageSuite) TestGetOwnedPocketMessageError() {
 		})
 	}
 }
+
+// ShareLinks Unit Test
+func (s *PocketMessageSuite) TestCreateShareLink() {
+	past := time.Now().Add(-time.Hour)
+	testCase := []struct {
+		name          string
+		body          dto.NewShareLink
+		paramValue    string
+		expectCode    int
+		expectMessage string
+		expectFields  []apperr.FieldError
+	}{
+		{
+			name:          "create_share_link-normal",
+			body:          dto.NewShareLink{Label: "family", MaxVisit: 3},
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusCreated,
+			expectMessage: "created",
+		},
+		{
+			name:          "create_share_link-error_slug_taken",
+			body:          dto.NewShareLink{Slug: "taken-slug"},
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusConflict,
+			expectMessage: "error, slug has been taken",
+		},
+		{
+			name: "create_share_link-error_every_field",
+			body: dto.NewShareLink{
+				Label:      strings.Repeat("a", 101),
+				ExpiredAt:  &past,
+				ExpiresIn:  "-1h",
+				MaxVisit:   -1,
+				Passphrase: strings.Repeat("a", 73),
+				Slug:       "my birthday",
+			},
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, label should be at most 100 characters",
+			expectFields: []apperr.FieldError{
+				{Field: "label", Message: "error, label should be at most 100 characters"},
+				{Field: "expired_at", Message: "error, expired_at should be in the future"},
+				{Field: "expires_in", Message: "error, expires_in should be a positive duration"},
+				{Field: "max_visit", Message: "error, max_visit should be at least 0"},
+				{Field: "passphrase", Message: "error, passphrase should be at most 72 bytes"},
+				{Field: "slug", Message: "error, slug should be 3 to 64 letters, digits, '-' or '_'"},
+			},
+		},
+		{
+			name:          "create_share_link-error_not_owner",
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			res, _ := json.Marshal(v.body)
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(res))
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath("/api/v1/pocket-messages/:uuid/links")
+			c.SetParamNames("uuid")
+			c.SetParamValues(v.paramValue)
+			c.Request().Header.Set("Content-Type", "application/json")
+
+			if s.NoError(serve(s.handler.CreateShareLink, c)) {
+				type response struct {
+					Message string              `json:"message"`
+					Data    dto.ShareLink       `json:"data"`
+					Errors  []apperr.FieldError `json:"errors"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+				s.Equal(v.expectFields, resp.Errors)
+				if v.expectCode == http.StatusCreated {
+					s.Equal(v.body.Label, resp.Data.Label)
+					s.NotEmpty(resp.Data.RandomID)
+				}
+			}
+		})
+	}
+}
+func (s *PocketMessageSuite) TestGetShareLinks() {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	c := newEcho().NewContext(r, w)
+	c.SetPath("/api/v1/pocket-messages/:uuid/links")
+	c.SetParamNames("uuid")
+	c.SetParamValues("00000000-0000-0000-0000-000000000001")
+
+	if s.NoError(serve(s.handler.GetShareLinks, c)) {
+		type response struct {
+			Message string          `json:"message"`
+			Data    []dto.ShareLink `json:"data"`
+		}
+		var resp response
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			s.Error(err, "error unmarshalling")
+		}
+
+		s.Equal(http.StatusOK, w.Result().StatusCode)
+		s.Equal("success", resp.Message)
+		s.Len(resp.Data, 1)
+	}
+}
+func (s *PocketMessageSuite) TestRevokeShareLink() {
+	testCase := []struct {
+		name          string
+		paramValues   []string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "revoke_share_link-normal",
+			paramValues:   []string{"00000000-0000-0000-0000-000000000001", "asdfghjkl"},
+			expectCode:    http.StatusOK,
+			expectMessage: "revoked",
+		},
+		{
+			name:          "revoke_share_link-error_not_found",
+			paramValues:   []string{"00000000-0000-0000-0000-000000000001", "missing"},
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, share link not found",
+		},
+		{
+			name:          "revoke_share_link-error_message_not_found",
+			paramValues:   []string{"00000000-0000-0000-0000-000000000404", "asdfghjkl"},
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, pocket message not found",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, "/", nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath("/api/v1/pocket-messages/:uuid/links/:random_id")
+			c.SetParamNames("uuid", "random_id")
+			c.SetParamValues(v.paramValues...)
+
+			if s.NoError(serve(s.handler.RevokeShareLink, c)) {
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+// GetPocketMessageStats Unit Test
+func (s *PocketMessageSuite) TestGetPocketMessageStats() {
+	testCase := []struct {
+		name          string
+		query         string
+		paramValue    string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "get_pocket_message_stats-normal",
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "get_pocket_message_stats-error_bucket",
+			query:         "bucket=week",
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, bucket should be hour or day",
+		},
+		{
+			name:          "get_pocket_message_stats-error_from",
+			query:         "from=yesterday",
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, from should be an RFC 3339 time",
+		},
+		{
+			name:          "get_pocket_message_stats-error_uuid",
+			paramValue:    "not-a-uuid",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "uuid invalid",
+		},
+		{
+			name:          "get_pocket_message_stats-error_not_owner",
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+v.query, nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath("/api/v1/pocket-messages/:uuid/stats")
+			c.SetParamNames("uuid")
+			c.SetParamValues(v.paramValue)
+
+			if s.NoError(serve(s.handler.GetPocketMessageStats, c)) {
+				type response struct {
+					Message string           `json:"message"`
+					Data    dto.MessageStats `json:"data"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+				if v.expectCode == http.StatusOK {
+					s.Equal(3, resp.Data.Views)
+				}
+			}
+		})
+	}
+}
+
+// SearchPocketMessages Unit Test
+func (s *PocketMessageSuite) TestSearchPocketMessages() {
+	testCase := []struct {
+		name          string
+		query         string
+		auth          bool
+		expectCode    int
+		expectMessage string
+		expectResults int
+	}{
+		{
+			name:          "search_pocket_messages-normal",
+			query:         "q=dunia",
+			auth:          true,
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+			expectResults: 1,
+		},
+		{
+			name:          "search_pocket_messages-error_query",
+			auth:          true,
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, q should have a word of at least 2 characters",
+		},
+		{
+			name:          "search_pocket_messages-error_token",
+			query:         "q=dunia",
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "authorization header not found",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+v.query, nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath("/api/v1/pocket-messages/search")
+			if v.auth {
+				tok, err := tokens.SignSessionToken(uuid.New(), "udin", uuid.New())
+				if err != nil {
+					s.Error(err, "error get token")
+				}
+				c.Request().Header.Set("Authorization", fmt.Sprintf("Bearer %s", tok))
+			}
+
+			if s.NoError(serve(tokens.Principal()(s.handler.SearchPocketMessages), c)) {
+				type response struct {
+					Message string             `json:"message"`
+					Data    []dto.SearchResult `json:"data"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+				s.Len(resp.Data, v.expectResults)
+			}
+		})
+	}
+}
+
+// Revisions Unit Test
+func (s *PocketMessageSuite) TestPocketMessageRevisions() {
+	testCase := []struct {
+		name          string
+		handler       echo.HandlerFunc
+		method        string
+		paramValue    string
+		n             string
+		query         string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "get_pocket_message_revisions-normal",
+			handler:       s.handler.GetPocketMessageRevisions,
+			method:        http.MethodGet,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "get_pocket_message_revisions-error_not_owner",
+			handler:       s.handler.GetPocketMessageRevisions,
+			method:        http.MethodGet,
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+		},
+		{
+			name:          "diff_pocket_message_revisions-normal",
+			handler:       s.handler.DiffPocketMessageRevisions,
+			method:        http.MethodGet,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			query:         "from=1&to=2",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "diff_pocket_message_revisions-error_not_found",
+			handler:       s.handler.DiffPocketMessageRevisions,
+			method:        http.MethodGet,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			query:         "to=9",
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, revision not found",
+		},
+		{
+			name:          "diff_pocket_message_revisions-error_to",
+			handler:       s.handler.DiffPocketMessageRevisions,
+			method:        http.MethodGet,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			query:         "to=last",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, to should be an integer",
+		},
+		{
+			name:          "restore_pocket_message_revision-normal",
+			handler:       s.handler.RestorePocketMessageRevision,
+			method:        http.MethodPost,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			n:             "1",
+			expectCode:    http.StatusOK,
+			expectMessage: "restored",
+		},
+		{
+			name:          "restore_pocket_message_revision-error_not_found",
+			handler:       s.handler.RestorePocketMessageRevision,
+			method:        http.MethodPost,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			n:             "9",
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, revision not found",
+		},
+		{
+			name:          "restore_pocket_message_revision-error_number",
+			handler:       s.handler.RestorePocketMessageRevision,
+			method:        http.MethodPost,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			n:             "first",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, revision number should be a positive integer",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(v.method, "/?"+v.query, nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetParamNames("uuid", "n")
+			c.SetParamValues(v.paramValue, v.n)
+
+			if s.NoError(serve(v.handler, c)) {
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+// Trash Unit Test
+func (s *PocketMessageSuite) TestPocketMessageTrash() {
+	testCase := []struct {
+		name          string
+		handler       echo.HandlerFunc
+		method        string
+		paramValue    string
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "get_trashed_pocket_messages-normal",
+			handler:       s.handler.GetTrashedPocketMessages,
+			method:        http.MethodGet,
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "restore_pocket_message-normal",
+			handler:       s.handler.RestorePocketMessage,
+			method:        http.MethodPost,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusOK,
+			expectMessage: "restored",
+		},
+		{
+			name:          "restore_pocket_message-error_not_found",
+			handler:       s.handler.RestorePocketMessage,
+			method:        http.MethodPost,
+			paramValue:    "00000000-0000-0000-0000-000000000404",
+			expectCode:    http.StatusNotFound,
+			expectMessage: "error, pocket message not found",
+		},
+		{
+			name:          "purge_pocket_message-normal",
+			handler:       s.handler.PurgePocketMessage,
+			method:        http.MethodDelete,
+			paramValue:    "00000000-0000-0000-0000-000000000001",
+			expectCode:    http.StatusOK,
+			expectMessage: "deleted",
+		},
+		{
+			name:          "purge_pocket_message-error_not_owner",
+			handler:       s.handler.PurgePocketMessage,
+			method:        http.MethodDelete,
+			paramValue:    "00000000-0000-0000-0000-000000000403",
+			expectCode:    http.StatusForbidden,
+			expectMessage: "error, you are not allowed to access this resource",
+		},
+		{
+			name:          "purge_pocket_message-error_uuid",
+			handler:       s.handler.PurgePocketMessage,
+			method:        http.MethodDelete,
+			paramValue:    "not-a-uuid",
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "uuid invalid",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(v.method, "/", nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetParamNames("uuid")
+			c.SetParamValues(v.paramValue)
+			tok, err := tokens.SignSessionToken(uuid.Nil, "super", uuid.New())
+			if err != nil {
+				s.Error(err, "error get token")
+			}
+			c.Request().Header.Set("Authorization", fmt.Sprintf("Bearer %s", tok))
+
+			if s.NoError(serve(tokens.Principal()(v.handler), c)) {
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+// Message Page Unit Test
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDPage() {
+	browser := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	testCase := []struct {
+		name              string
+		randomID          string
+		accept            string
+		expectCode        int
+		expectContains    []string
+		expectNotContains []string
+	}{
+		{
+			name:           "message_page-plain",
+			randomID:       "asdfghjkl",
+			accept:         browser,
+			expectCode:     http.StatusOK,
+			expectContains: []string{"<title>Ini Test</title>", `<div class="content plain">Ini juga Test</div>`},
+		},
+		{
+			name:       "message_page-markdown",
+			randomID:   "markdown",
+			accept:     browser,
+			expectCode: http.StatusOK,
+			expectContains: []string{
+				"<h1 class=\"title\">Ini &lt;Test&gt;</h1>",
+				`<p><strong>Ini</strong> juga <a href="https://example.com" rel="nofollow noopener noreferrer">Test</a>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+			},
+			expectNotContains: []string{"<script>"},
+		},
+		{
+			name:              "message_page-encrypted",
+			randomID:          "encrypted",
+			accept:            browser,
+			expectCode:        http.StatusOK,
+			expectContains:    []string{"end-to-end encrypted", "deleted after this reading"},
+			expectNotContains: []string{"eyJ2IjoxfQ"},
+		},
+		{
+			name:           "message_page-expired",
+			randomID:       "expired",
+			accept:         browser,
+			expectCode:     http.StatusGone,
+			expectContains: []string{"no longer available"},
+		},
+		{
+			name:           "message_page-protected",
+			randomID:       "protected",
+			accept:         browser,
+			expectCode:     http.StatusUnauthorized,
+			expectContains: []string{`<form method="post">`, `name="passphrase"`},
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set("Accept", v.accept)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetParamNames("random_id")
+			c.SetParamValues(v.randomID)
+
+			if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
+				body := w.Body.String()
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(echo.MIMETextHTMLCharsetUTF8, w.Header().Get("Content-Type"))
+				s.Contains(w.Header().Get("Content-Security-Policy"), "default-src 'none'")
+				s.Equal("no-store", w.Header().Get("Cache-Control"))
+				for _, want := range v.expectContains {
+					s.Contains(body, want)
+				}
+				for _, unwanted := range v.expectNotContains {
+					s.NotContains(body, unwanted)
+				}
+			}
+		})
+	}
+}
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDPageOpen() {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	c := newEcho().NewContext(r, w)
+	c.SetParamNames("random_id")
+	// The message is not read, so even an expired one gets the open page.
+	c.SetParamValues("expired")
+
+	if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
+		body := w.Body.String()
+		s.Equal(http.StatusOK, w.Result().StatusCode)
+		s.Equal("no-store", w.Header().Get("Cache-Control"))
+		s.Contains(body, `<form method="post">`)
+		s.Contains(body, "Open message")
+		s.NotContains(body, `name="passphrase"`)
+	}
+}
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDJSONFormat() {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json, text/html;q=0.5")
+	w := httptest.NewRecorder()
+	c := newEcho().NewContext(r, w)
+	c.SetParamNames("random_id")
+	c.SetParamValues("markdown")
+
+	if s.NoError(serve(s.handler.GetPocketMessageByRandomID, c)) {
+		var resp struct {
+			Data dto.PocketMessageWithRandomID `json:"data"`
+		}
+		s.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		s.Equal(http.StatusOK, w.Result().StatusCode)
+		s.Equal(models.FormatMarkdown, resp.Data.Format)
+		s.Equal("**Ini** juga [Test](https://example.com)<script>alert(1)</script>", resp.Data.Content)
+	}
+}
+func (s *PocketMessageSuite) TestWantsHTML() {
+	testCase := []struct {
+		name   string
+		accept string
+		expect bool
+	}{
+		{name: "wants_html-browser", accept: "text/html,application/xhtml+xml,*/*;q=0.8", expect: true},
+		{name: "wants_html-none", accept: "", expect: false},
+		{name: "wants_html-any", accept: "*/*", expect: false},
+		{name: "wants_html-json", accept: "application/json", expect: false},
+		{name: "wants_html-json_preferred", accept: "text/html;q=0.5, application/json", expect: false},
+		{name: "wants_html-html_preferred", accept: "application/json;q=0.1, text/html", expect: true},
+		{name: "wants_html-refused", accept: "text/html;q=0", expect: false},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", v.accept)
+			c := newEcho().NewContext(r, httptest.NewRecorder())
+			s.Equal(v.expect, wantsHTML(c))
+		})
+	}
+}
@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"net/http"
+	"pocket-message/dto"
+	"pocket-message/pkg/apperr"
 	"pocket-message/services"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -19,30 +22,57 @@ type PocketMessageHandler interface {
 	UpdatePocketMessage(echo.Context) error
 	DeletePocketMessage(echo.Context) error
 	GetOwnedPocketMessage(echo.Context) error
+	CreateShareLink(echo.Context) error
+	GetShareLinks(echo.Context) error
+	RevokeShareLink(echo.Context) error
+	GetPocketMessageStats(echo.Context) error
+	SearchPocketMessages(echo.Context) error
+	GetPocketMessageRevisions(echo.Context) error
+	DiffPocketMessageRevisions(echo.Context) error
+	RestorePocketMessageRevision(echo.Context) error
+	GetTrashedPocketMessages(echo.Context) error
+	RestorePocketMessage(echo.Context) error
+	PurgePocketMessage(echo.Context) error
 }
 type pocketMessageHandler struct {
 	services.PocketMessageServices
 }
 
 func (h *pocketMessageHandler) NewPocketMessage(c echo.Context) error {
+	var req dto.NewPocketMessage
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
 
-	err := h.PocketMessageServices.NewPocketMessage(c)
+	err = h.PocketMessageServices.NewPocketMessage(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(http.StatusCreated, echo.Map{
 		"message": "created",
 	})
 }
+
+// GetPocketMessageByRandomID answers browsers with a page showing the
+// message and other clients with JSON. A browser opening the link gets a
+// page asking before the message is read, see renderOpenPage.
 func (h *pocketMessageHandler) GetPocketMessageByRandomID(c echo.Context) error {
+	html := wantsHTML(c)
+	if html && c.Request().Method == http.MethodGet {
+		return renderOpenPage(c)
+	}
 
-	result, err := h.PocketMessageServices.GetPocketMessageByRandomID(c)
+	var result dto.PocketMessageWithRandomID
+	req, err := readPocketMessage(c)
+	if err == nil {
+		result, err = h.PocketMessageServices.GetPocketMessageByRandomID(c.Request().Context(), req)
+	}
+	if html {
+		return renderMessagePage(c, result, err)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -51,11 +81,19 @@ func (h *pocketMessageHandler) GetPocketMessageByRandomID(c echo.Context) error
 	})
 }
 func (h *pocketMessageHandler) UpdatePocketMessage(c echo.Context) error {
-	err := h.PocketMessageServices.UpdatePocketMessage(c)
+	var req dto.UpdatePocketMessage
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+	req.UUID, err = paramUUID(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
+	}
+
+	err = h.PocketMessageServices.UpdatePocketMessage(c.Request().Context(), req)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -63,11 +101,14 @@ func (h *pocketMessageHandler) UpdatePocketMessage(c echo.Context) error {
 	})
 }
 func (h *pocketMessageHandler) DeletePocketMessage(c echo.Context) error {
-	err := h.PocketMessageServices.DeletePocketMessage(c)
+	id, err := paramUUID(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
+	}
+
+	err = h.PocketMessageServices.DeletePocketMessage(c.Request().Context(), id)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -75,11 +116,185 @@ func (h *pocketMessageHandler) DeletePocketMessage(c echo.Context) error {
 	})
 }
 func (h *pocketMessageHandler) GetOwnedPocketMessage(c echo.Context) error {
-	result, err := h.PocketMessageServices.GetUserPocketMessage(c)
+	req, err := listOwnedMessages(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := h.PocketMessageServices.GetUserPocketMessage(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":     "success",
+		"data":        page.Messages,
+		"next_cursor": page.NextCursor,
+		"total":       page.Total,
+	})
+}
+func (h *pocketMessageHandler) SearchPocketMessages(c echo.Context) error {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.SearchPocketMessages(c.Request().Context(), dto.SearchPocketMessages{
+		Query: c.QueryParam("q"),
+		Limit: limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) CreateShareLink(c echo.Context) error {
+	var req dto.NewShareLink
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.CreateShareLink(c.Request().Context(), id, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"message": "created",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) GetShareLinks(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.GetShareLinks(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) RevokeShareLink(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.PocketMessageServices.RevokeShareLink(c.Request().Context(), id, c.Param("random_id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "revoked",
+	})
+}
+func (h *pocketMessageHandler) GetPocketMessageStats(c echo.Context) error {
+	req := dto.StatsRequest{Bucket: c.QueryParam("bucket")}
+	var err error
+	req.UUID, err = paramUUID(c)
+	if err != nil {
+		return err
+	}
+	req.From, err = queryTime(c, "from")
+	if err != nil {
+		return err
+	}
+	req.To, err = queryTime(c, "to")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.GetPocketMessageStats(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) GetPocketMessageRevisions(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.GetPocketMessageRevisions(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) DiffPocketMessageRevisions(c echo.Context) error {
+	var req dto.RevisionDiffRequest
+	var err error
+	req.UUID, err = paramUUID(c)
+	if err != nil {
+		return err
+	}
+	req.From, err = queryInt(c, "from")
+	if err != nil {
+		return err
+	}
+	req.To, err = queryInt(c, "to")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.PocketMessageServices.DiffPocketMessageRevisions(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *pocketMessageHandler) RestorePocketMessageRevision(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		return apperr.Validation("error, revision number should be a positive integer")
+	}
+
+	err = h.PocketMessageServices.RestorePocketMessageRevision(c.Request().Context(), id, n)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "restored",
+	})
+}
+func (h *pocketMessageHandler) GetTrashedPocketMessages(c echo.Context) error {
+	result, err := h.PocketMessageServices.GetTrashedPocketMessages(c.Request().Context())
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -87,3 +302,80 @@ func (h *pocketMessageHandler) GetOwnedPocketMessage(c echo.Context) error {
 		"data":    result,
 	})
 }
+func (h *pocketMessageHandler) RestorePocketMessage(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.PocketMessageServices.RestorePocketMessage(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "restored",
+	})
+}
+func (h *pocketMessageHandler) PurgePocketMessage(c echo.Context) error {
+	id, err := paramUUID(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.PocketMessageServices.PurgePocketMessage(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "deleted",
+	})
+}
+
+// readPocketMessage reads the passphrase from the X-Passphrase header, or
+// from the body sent by the passphrase form of the message page.
+func readPocketMessage(c echo.Context) (dto.ReadPocketMessage, error) {
+	req := dto.ReadPocketMessage{
+		RandomID:   c.Param("random_id"),
+		Passphrase: c.Request().Header.Get("X-Passphrase"),
+		Viewer: dto.Viewer{
+			IP:        c.RealIP(),
+			UserAgent: c.Request().UserAgent(),
+			Referrer:  c.Request().Referer(),
+		},
+	}
+	if req.Passphrase == "" {
+		var p dto.Passphrase
+		err := c.Bind(&p)
+		if err != nil {
+			return dto.ReadPocketMessage{}, err
+		}
+		req.Passphrase = p.Passphrase
+	}
+	return req, nil
+}
+
+func listOwnedMessages(c echo.Context) (dto.ListOwnedMessages, error) {
+	req := dto.ListOwnedMessages{
+		Sort:   c.QueryParam("sort"),
+		Order:  c.QueryParam("order"),
+		Cursor: c.QueryParam("cursor"),
+		Title:  c.QueryParam("title"),
+		Status: c.QueryParam("status"),
+	}
+	var err error
+	req.Limit, err = queryInt(c, "limit")
+	if err != nil {
+		return dto.ListOwnedMessages{}, err
+	}
+	req.CreatedFrom, err = queryTime(c, "created_from")
+	if err != nil {
+		return dto.ListOwnedMessages{}, err
+	}
+	req.CreatedTo, err = queryTime(c, "created_to")
+	if err != nil {
+		return dto.ListOwnedMessages{}, err
+	}
+	return req, nil
+}
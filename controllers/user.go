@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"pocket-message/dto"
 	"pocket-message/services"
 
 	"github.com/labstack/echo/v4"
@@ -17,7 +18,12 @@ type UserHandler interface {
 	SignUp(echo.Context) error
 	Login(echo.Context) error
 	UpdateUsername(echo.Context) error
-	UpdatePassword(echo.Context) error
+	RequestPasswordReset(echo.Context) error
+	ResetPassword(echo.Context) error
+	ChangePassword(echo.Context) error
+	RefreshToken(echo.Context) error
+	Logout(echo.Context) error
+	LogoutAll(echo.Context) error
 }
 
 type userHandler struct {
@@ -25,12 +31,15 @@ type userHandler struct {
 }
 
 func (h *userHandler) SignUp(c echo.Context) error {
-	// validation
-	err := h.UserServices.SignUp(c)
+	var req dto.SignUp
+	err := bind(c, &req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
+	}
+
+	err = h.UserServices.SignUp(c.Request().Context(), req)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, echo.Map{
@@ -39,12 +48,15 @@ func (h *userHandler) SignUp(c echo.Context) error {
 }
 
 func (h *userHandler) Login(c echo.Context) error {
+	var req dto.Credentials
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
 
-	result, err := h.UserServices.Login(c)
+	result, err := h.UserServices.Login(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -54,12 +66,15 @@ func (h *userHandler) Login(c echo.Context) error {
 }
 
 func (h *userHandler) UpdateUsername(c echo.Context) error {
+	var req dto.UpdateUsername
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
 
-	err := h.UserServices.UpdateUsername(c)
+	err = h.UserServices.UpdateUsername(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -67,13 +82,87 @@ func (h *userHandler) UpdateUsername(c echo.Context) error {
 	})
 }
 
-func (h *userHandler) UpdatePassword(c echo.Context) error {
+func (h *userHandler) RequestPasswordReset(c echo.Context) error {
+	var req dto.PasswordResetRequest
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+
+	err = h.UserServices.RequestPasswordReset(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "if the username exists, a reset token has been sent",
+	})
+}
+
+func (h *userHandler) ResetPassword(c echo.Context) error {
+	var req dto.PasswordResetConfirm
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+
+	err = h.UserServices.ResetPassword(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+	})
+}
 
-	err := h.UserServices.UpdatePassword(c)
+func (h *userHandler) ChangePassword(c echo.Context) error {
+	var req dto.ChangePassword
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+
+	err = h.UserServices.ChangePassword(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+	})
+}
+func (h *userHandler) RefreshToken(c echo.Context) error {
+	var req dto.RefreshToken
+	err := bind(c, &req)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.UserServices.RefreshToken(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+		"data":    result,
+	})
+}
+func (h *userHandler) Logout(c echo.Context) error {
+	err := h.UserServices.Logout(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "success",
+	})
+}
+func (h *userHandler) LogoutAll(c echo.Context) error {
+	err := h.UserServices.LogoutAll(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"message": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
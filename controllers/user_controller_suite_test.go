@@ -7,9 +7,15 @@ import (
 	"net/http/httptest"
 	m "pocket-message/controllers/mock"
 	"pocket-message/dto"
+	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/jwtkeys"
+	"pocket-message/pkg/validate"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/suite"
 )
@@ -31,6 +37,26 @@ func TestSuiteUser(t *testing.T) {
 	suite.Run(t, new(UserSuite))
 }
 
+// serve runs a handler the way echo does, answering returned errors with the
+// central error handler.
+func serve(h echo.HandlerFunc, c echo.Context) error {
+	err := h(c)
+	if err != nil {
+		middleware.ErrorHandler(err, c)
+	}
+	return nil
+}
+
+// tokens signs the access tokens of the tests.
+var tokens = middleware.NewTokens(jwtkeys.FromSecret("rahasia"), 15*time.Minute)
+
+// newEcho returns an echo with the validator of the API.
+func newEcho() *echo.Echo {
+	e := echo.New()
+	e.Validator = validate.Validator{}
+	return e
+}
+
 func (s *UserSuite) TestSignup() {
 	testCase := []struct {
 		name          string
@@ -46,7 +72,7 @@ func (s *UserSuite) TestSignup() {
 			path:   "/api/v1/signup",
 			body: models.User{
 				Username: "miftah",
-				Password: "test",
+				Password: "test1234",
 			},
 			expectCode:    http.StatusCreated,
 			expectMessage: "created",
@@ -58,11 +84,11 @@ func (s *UserSuite) TestSignup() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.SignUp(c)) {
+			if s.NoError(serve(s.handler.SignUp, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -96,9 +122,31 @@ func (s *UserSuite) TestSignupError() {
 			body: models.User{
 				Username: "miftah",
 			},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, password should not be empty",
 		},
+		{
+			name:   "signup-error_password_too_short",
+			method: http.MethodPost,
+			path:   "/api/v1/signup",
+			body: models.User{
+				Username: "miftah",
+				Password: "pendek",
+			},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, password should be at least 8 characters",
+		},
+		{
+			name:   "signup-error_username_taken",
+			method: http.MethodPost,
+			path:   "/api/v1/signup",
+			body: models.User{
+				Username: "doraemon",
+				Password: "test1234",
+			},
+			expectCode:    http.StatusConflict,
+			expectMessage: "error, username has been taken",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
@@ -106,11 +154,11 @@ func (s *UserSuite) TestSignupError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.SignUp(c)) {
+			if s.NoError(serve(s.handler.SignUp, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -145,7 +193,7 @@ func (s *UserSuite) TestLogin() {
 			path:   "/api/v1/login",
 			body: models.User{
 				Username: "Super",
-				Password: "test",
+				Password: "test1234",
 			},
 			expectBody: dto.Login{
 				Username: "Super",
@@ -161,11 +209,11 @@ func (s *UserSuite) TestLogin() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.Login(c)) {
+			if s.NoError(serve(s.handler.Login, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -191,6 +239,7 @@ func (s *UserSuite) TestLoginError() {
 		method        string
 		path          string
 		body          models.User
+		contentType   string
 		expectBody    dto.Login
 		expectCode    int
 		expectMessage string
@@ -201,12 +250,37 @@ func (s *UserSuite) TestLoginError() {
 			path:   "/api/v1/login",
 			body: models.User{
 				Username: "",
-				Password: "test",
+				Password: "test1234",
 			},
 			expectBody:    dto.Login{},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, username should not be empty",
 		},
+		{
+			name:   "login-error_invalid_credentials",
+			method: http.MethodPost,
+			path:   "/api/v1/login",
+			body: models.User{
+				Username: "Super",
+				Password: "salah",
+			},
+			expectBody:    dto.Login{},
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, username or password is wrong",
+		},
+		{
+			name:   "login-error_binding",
+			method: http.MethodPost,
+			path:   "/api/v1/login",
+			body: models.User{
+				Username: "Super",
+				Password: "test1234",
+			},
+			contentType:   "text/plain",
+			expectBody:    dto.Login{},
+			expectCode:    http.StatusUnsupportedMediaType,
+			expectMessage: "Unsupported Media Type",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
@@ -214,11 +288,14 @@ func (s *UserSuite) TestLoginError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
-			c.Request().Header.Set("Content-Type", "application/json")
+			if v.contentType == "" {
+				v.contentType = "application/json"
+			}
+			c.Request().Header.Set("Content-Type", v.contentType)
 
-			if s.NoError(s.handler.Login(c)) {
+			if s.NoError(serve(s.handler.Login, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -254,7 +331,7 @@ func (s *UserSuite) TestUpdateUsername() {
 			path:   "/api/v1/users/change-username",
 			body: models.User{
 				Username: "Super",
-				Password: "test",
+				Password: "test1234",
 			},
 			expectCode:    http.StatusOK,
 			expectMessage: "success",
@@ -266,11 +343,11 @@ func (s *UserSuite) TestUpdateUsername() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.UpdateUsername(c)) {
+			if s.NoError(serve(s.handler.UpdateUsername, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -303,11 +380,22 @@ func (s *UserSuite) TestUpdateUsernameError() {
 			path:   "/api/v1/users/change-username",
 			body: models.User{
 				Username: "",
-				Password: "test",
+				Password: "test1234",
 			},
-			expectCode:    http.StatusInternalServerError,
+			expectCode:    http.StatusBadRequest,
 			expectMessage: "error, username should not be empty",
 		},
+		{
+			name:   "update_username-error_username_taken",
+			method: http.MethodPut,
+			path:   "/api/v1/users/change-username",
+			body: models.User{
+				Username: "doraemon",
+				Password: "test1234",
+			},
+			expectCode:    http.StatusConflict,
+			expectMessage: "error, username has been taken",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
@@ -315,11 +403,11 @@ func (s *UserSuite) TestUpdateUsernameError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.UpdateUsername(c)) {
+			if s.NoError(serve(s.handler.UpdateUsername, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -337,26 +425,91 @@ func (s *UserSuite) TestUpdateUsernameError() {
 		})
 	}
 }
-func (s *UserSuite) TestUpdatePassword() {
+func (s *UserSuite) TestRequestPasswordReset() {
 	testCase := []struct {
 		name          string
 		method        string
 		path          string
-		body          models.User
+		body          dto.PasswordResetRequest
 		expectCode    int
 		expectMessage string
 	}{
 		{
-			name:   "update_password-normal",
-			method: http.MethodPut,
-			path:   "/api/v1/users/reset-password",
-			body: models.User{
-				Username: "Super",
-				Password: "test",
+			name:          "request_password_reset-normal",
+			method:        http.MethodPost,
+			path:          "/api/v1/users/reset-password/request",
+			body:          dto.PasswordResetRequest{Username: "Super"},
+			expectCode:    http.StatusOK,
+			expectMessage: "if the username exists, a reset token has been sent",
+		},
+		{
+			name:          "request_password_reset-error",
+			method:        http.MethodPost,
+			path:          "/api/v1/users/reset-password/request",
+			body:          dto.PasswordResetRequest{Username: ""},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, username should not be empty",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			res, _ := json.Marshal(v.body)
+
+			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath(v.path)
+			c.Request().Header.Set("Content-Type", "application/json")
+
+			if s.NoError(serve(s.handler.RequestPasswordReset, c)) {
+				body := w.Body.Bytes()
+
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(body, &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+func (s *UserSuite) TestResetPassword() {
+	testCase := []struct {
+		name          string
+		method        string
+		path          string
+		body          dto.PasswordResetConfirm
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:   "reset_password-normal",
+			method: http.MethodPost,
+			path:   "/api/v1/users/reset-password/confirm",
+			body: dto.PasswordResetConfirm{
+				Token:    "valid-token",
+				Password: "test1234",
 			},
 			expectCode:    http.StatusOK,
 			expectMessage: "success",
 		},
+		{
+			name:   "reset_password-error_invalid_token",
+			method: http.MethodPost,
+			path:   "/api/v1/users/reset-password/confirm",
+			body: dto.PasswordResetConfirm{
+				Token:    "used-token",
+				Password: "test1234",
+			},
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, reset token is invalid or expired",
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
@@ -364,11 +517,11 @@ func (s *UserSuite) TestUpdatePassword() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.UpdatePassword(c)) {
+			if s.NoError(serve(s.handler.ResetPassword, c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -386,25 +539,130 @@ func (s *UserSuite) TestUpdatePassword() {
 		})
 	}
 }
-func (s *UserSuite) TestUpdatePasswordError() {
+func (s *UserSuite) TestChangePassword() {
 	testCase := []struct {
 		name          string
 		method        string
 		path          string
-		body          models.User
+		body          dto.ChangePassword
 		expectCode    int
 		expectMessage string
 	}{
 		{
-			name:   "update_password-error",
+			name:   "change_password-normal",
 			method: http.MethodPut,
-			path:   "/api/v1/users/reset-password",
-			body: models.User{
-				Username: "",
-				Password: "asdw",
+			path:   "/api/v1/users/change-password",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     "test1234",
 			},
-			expectCode:    http.StatusInternalServerError,
-			expectMessage: "error, username should not be empty",
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:   "change_password-error_wrong_current_password",
+			method: http.MethodPut,
+			path:   "/api/v1/users/change-password",
+			body: dto.ChangePassword{
+				CurrentPassword: "salah",
+				NewPassword:     "test1234",
+			},
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, username or password is wrong",
+		},
+		{
+			name:   "change_password-error_new_password_empty",
+			method: http.MethodPut,
+			path:   "/api/v1/users/change-password",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     "",
+			},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, new_password should not be empty",
+		},
+		{
+			name:   "change_password-error_new_password_too_long",
+			method: http.MethodPut,
+			path:   "/api/v1/users/change-password",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     strings.Repeat("é", 37),
+			},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, new_password should be at most 72 bytes",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			res, _ := json.Marshal(v.body)
+
+			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath(v.path)
+			c.Request().Header.Set("Content-Type", "application/json")
+
+			if s.NoError(serve(s.handler.ChangePassword, c)) {
+				body := w.Body.Bytes()
+
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(body, &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+// RefreshToken
+func (s *UserSuite) TestRefreshToken() {
+	testCase := []struct {
+		name          string
+		method        string
+		path          string
+		body          dto.RefreshToken
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "refresh_token-normal",
+			method:        http.MethodPost,
+			path:          "/api/v1/token/refresh",
+			body:          dto.RefreshToken{RefreshToken: "valid-refresh"},
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "refresh_token-error_invalid",
+			method:        http.MethodPost,
+			path:          "/api/v1/token/refresh",
+			body:          dto.RefreshToken{RefreshToken: "made-up"},
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, refresh token is invalid or expired",
+		},
+		{
+			name:          "refresh_token-error_reused",
+			method:        http.MethodPost,
+			path:          "/api/v1/token/refresh",
+			body:          dto.RefreshToken{RefreshToken: "reused"},
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "error, refresh token was already used, all sessions of this login are revoked",
+		},
+		{
+			name:          "refresh_token-error_empty",
+			method:        http.MethodPost,
+			path:          "/api/v1/token/refresh",
+			body:          dto.RefreshToken{},
+			expectCode:    http.StatusBadRequest,
+			expectMessage: "error, refresh_token should not be empty",
 		},
 	}
 	for _, v := range testCase {
@@ -413,11 +671,85 @@ func (s *UserSuite) TestUpdatePasswordError() {
 
 			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
 			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
+			c := newEcho().NewContext(r, w)
 			c.SetPath(v.path)
 			c.Request().Header.Set("Content-Type", "application/json")
 
-			if s.NoError(s.handler.UpdatePassword(c)) {
+			if s.NoError(serve(s.handler.RefreshToken, c)) {
+				body := w.Body.Bytes()
+
+				type response struct {
+					Message string `json:"message"`
+				}
+				var resp response
+				err := json.Unmarshal(body, &resp)
+				if err != nil {
+					s.Error(err, "error unmarshalling")
+				}
+
+				s.Equal(v.expectCode, w.Result().StatusCode)
+				s.Equal(v.expectMessage, resp.Message)
+			}
+		})
+	}
+}
+
+// Logout
+func (s *UserSuite) TestLogout() {
+	testCase := []struct {
+		name          string
+		path          string
+		handler       func(echo.Context) error
+		auth          bool
+		expectCode    int
+		expectMessage string
+	}{
+		{
+			name:          "logout-normal",
+			path:          "/api/v1/logout",
+			handler:       s.handler.Logout,
+			auth:          true,
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "logout-error_auth",
+			path:          "/api/v1/logout",
+			handler:       s.handler.Logout,
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "authorization header not found",
+		},
+		{
+			name:          "logout_all-normal",
+			path:          "/api/v1/logout/all",
+			handler:       s.handler.LogoutAll,
+			auth:          true,
+			expectCode:    http.StatusOK,
+			expectMessage: "success",
+		},
+		{
+			name:          "logout_all-error_auth",
+			path:          "/api/v1/logout/all",
+			handler:       s.handler.LogoutAll,
+			expectCode:    http.StatusUnauthorized,
+			expectMessage: "authorization header not found",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			w := httptest.NewRecorder()
+			c := newEcho().NewContext(r, w)
+			c.SetPath(v.path)
+			if v.auth {
+				tok, err := tokens.SignSessionToken(uuid.Nil, "super", uuid.New())
+				if err != nil {
+					s.Error(err, "error get token")
+				}
+				c.Request().Header.Set("Authorization", "Bearer "+tok)
+			}
+
+			if s.NoError(serve(tokens.Principal()(v.handler), c)) {
 				body := w.Body.Bytes()
 
 				type response struct {
@@ -2,39 +2,108 @@ package database
 
 import (
 	"fmt"
-	"os"
+	"net/url"
+	"pocket-message/configs"
 	"pocket-message/models"
+	"time"
 
-	"gorm.io/driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var (
-	DB_Address = os.Getenv("DB_ADDRESS")
-	DB_Name    = os.Getenv("DB_NAME")
-	// DB_Address = "localhost:3306"
-	// DB_Name    = "pocket_message"
-)
-
-func SetEnv(key, def string) string {
-	val, ok := os.LookupEnv(key)
-	if !ok {
-		return def
+func ConnectDB(cfg configs.Database) (*gorm.DB, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return val
+	return gorm.Open(dialector, &gorm.Config{})
 }
 
-func ConnectDB() (*gorm.DB, error) {
-	connectionString := fmt.Sprintf("root:root@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local",
-		DB_Address, DB_Name)
-
-	return gorm.Open(mysql.Open(connectionString), &gorm.Config{})
+// Dialector opens the database of cfg with the driver it names.
+func Dialector(cfg configs.Database) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "mysql":
+		// FormatDSN escapes credentials that would break a hand written DSN.
+		dsn := mysql.NewConfig()
+		dsn.User = cfg.User
+		dsn.Passwd = cfg.Password
+		dsn.Net = "tcp"
+		dsn.Addr = cfg.Address
+		dsn.DBName = cfg.Name
+		dsn.Params = map[string]string{"charset": "utf8"}
+		dsn.ParseTime = true
+		dsn.Loc = time.Local
+		return gormmysql.Open(dsn.FormatDSN()), nil
+	case "postgres":
+		dsn := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(cfg.User, cfg.Password),
+			Host:   cfg.Address,
+			Path:   "/" + cfg.Name,
+		}
+		return postgres.Open(dsn.String()), nil
+	case "sqlite":
+		// Writers wait for each other instead of failing with "database is
+		// locked", and transactions take the write lock up front so two of
+		// them can not deadlock upgrading their read locks.
+		dsn := url.Values{"_busy_timeout": {"5000"}, "_txlock": {"immediate"}}
+		return sqlite.Open("file:" + cfg.Name + "?" + dsn.Encode()), nil
+	}
+	return nil, fmt.Errorf("error, unknown database driver %q", cfg.Driver)
 }
 
 func MigrateDB(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if db.Migrator().HasTable(&models.User{}) {
+		err := normalizeUsernames(db)
+		if err != nil {
+			return err
+		}
+	}
+	if db.Migrator().HasTable(&models.PocketMessageRandomID{}) {
+		err := checkRandomIDs(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	tables := []interface{}{
 		models.User{},
 		models.PocketMessage{},
 		models.PocketMessageRandomID{},
-	)
+		models.PasswordResetToken{},
+		models.Session{},
+		models.MessageView{},
+		models.PocketMessageRevision{},
+	}
+	// The search table has FULLTEXT indexes, which only MySQL has; other
+	// databases search with the in-memory index.
+	if db.Dialector.Name() == "mysql" {
+		tables = append(tables, models.MessageSearch{})
+	}
+	err := db.AutoMigrate(tables...)
+	if err != nil {
+		return err
+	}
+
+	if db.Dialector.Name() == "sqlite" {
+		return sqliteRowIDs(db, "users", "pocket_messages")
+	}
+	return nil
+}
+
+// sqliteRowIDs numbers the rows of tables keyed by (id, uuid). SQLite only
+// generates ids for a single INTEGER PRIMARY KEY, so their id would be left
+// NULL; the trigger copies the rowid, the id gorm reads back after inserting.
+func sqliteRowIDs(db *gorm.DB, tables ...string) error {
+	for _, table := range tables {
+		err := db.Exec(fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_id AFTER INSERT ON %[1]s WHEN NEW.id IS NULL "+
+			"BEGIN UPDATE %[1]s SET id = NEW.rowid WHERE rowid = NEW.rowid; END", table)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
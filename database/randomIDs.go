@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+	"pocket-message/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DuplicateRandomID is a random id more than one share link has, which the
+// random ids generated before they were collision-checked could have.
+type DuplicateRandomID struct {
+	RandomID string
+	Count    int
+}
+
+// FindDuplicateRandomIDs lists the random ids that would break the unique
+// index on pocket_message_random_id.random_id, soft deleted links included.
+func FindDuplicateRandomIDs(db *gorm.DB) ([]DuplicateRandomID, error) {
+	var dups []DuplicateRandomID
+	err := db.Unscoped().Model(&models.PocketMessageRandomID{}).
+		Select("random_id, COUNT(*) AS count").
+		Group("random_id").
+		Having("COUNT(*) > 1").
+		Order("random_id").
+		Scan(&dups).Error
+	if err != nil {
+		return nil, err
+	}
+	return dups, nil
+}
+
+// checkRandomIDs reports duplicate random ids. Which link keeps a shared id
+// has to be decided by hand, so they are not changed.
+func checkRandomIDs(db *gorm.DB) error {
+	dups, err := FindDuplicateRandomIDs(db)
+	if err != nil {
+		return err
+	}
+	if len(dups) > 0 {
+		ids := make([]string, len(dups))
+		for i, dup := range dups {
+			ids[i] = fmt.Sprintf("%q (%d links)", dup.RandomID, dup.Count)
+		}
+		return fmt.Errorf("error, duplicate random ids have to be changed or deleted before migrating: %s", strings.Join(ids, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const duplicateRandomIDsQuery = "SELECT random_id, COUNT(*) AS count FROM `pocket_message_random_id` GROUP BY `random_id` HAVING COUNT(*) > 1 ORDER BY random_id"
+
+type RandomIDsSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	db   *gorm.DB
+}
+
+func TestSuiteRandomIDs(t *testing.T) {
+	suite.Run(t, new(RandomIDsSuite))
+}
+
+func (s *RandomIDsSuite) SetupSuite() {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		s.Error(err)
+	}
+
+	gDB, err := gorm.Open(mysql.New(mysql.Config{
+		SkipInitializeWithVersion: true,
+		Conn:                      db,
+	}), &gorm.Config{})
+	if err != nil {
+		s.Error(err)
+	}
+
+	s.db = gDB
+	s.mock = mock
+}
+
+func (s *RandomIDsSuite) TearDownSuite() {}
+
+// FindDuplicateRandomIDs
+func (s *RandomIDsSuite) TestFindDuplicateRandomIDs() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateRandomIDsQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"random_id", "count"}).
+			AddRow("abcdefgh", 2).
+			AddRow("qwertyui", 3))
+
+	dups, err := FindDuplicateRandomIDs(s.db)
+	s.NoError(err)
+	s.Equal([]DuplicateRandomID{{RandomID: "abcdefgh", Count: 2}, {RandomID: "qwertyui", Count: 3}}, dups)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+
+// checkRandomIDs
+func (s *RandomIDsSuite) TestCheckRandomIDs() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateRandomIDsQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"random_id", "count"}))
+
+	s.NoError(checkRandomIDs(s.db))
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *RandomIDsSuite) TestCheckRandomIDsErrorDuplicate() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateRandomIDsQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"random_id", "count"}).
+			AddRow("abcdefgh", 2))
+
+	err := checkRandomIDs(s.db)
+	s.EqualError(err, `error, duplicate random ids have to be changed or deleted before migrating: "abcdefgh" (2 links)`)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"pocket-message/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DuplicateUsername is a username more than one user shares once usernames
+// are compared case-insensitively.
+type DuplicateUsername struct {
+	Username string
+	Count    int
+}
+
+// FindDuplicateUsernames lists the usernames that would break the unique
+// index on users.username, soft deleted users included.
+func FindDuplicateUsernames(db *gorm.DB) ([]DuplicateUsername, error) {
+	var dups []DuplicateUsername
+	err := db.Unscoped().Model(&models.User{}).
+		Select("LOWER(TRIM(username)) AS username, COUNT(*) AS count").
+		Group("LOWER(TRIM(username))").
+		Having("COUNT(*) > 1").
+		Order("username").
+		Scan(&dups).Error
+	if err != nil {
+		return nil, err
+	}
+	return dups, nil
+}
+
+// normalizeUsernames lower cases the usernames stored before usernames were
+// unique. Duplicates have to be renamed by hand, so they are reported instead.
+func normalizeUsernames(db *gorm.DB) error {
+	dups, err := FindDuplicateUsernames(db)
+	if err != nil {
+		return err
+	}
+	if len(dups) > 0 {
+		names := make([]string, len(dups))
+		for i, dup := range dups {
+			names[i] = fmt.Sprintf("%q (%d users)", dup.Username, dup.Count)
+		}
+		return fmt.Errorf("error, duplicate usernames have to be renamed before migrating: %s", strings.Join(names, ", "))
+	}
+
+	return db.Exec("UPDATE users SET username = LOWER(TRIM(username))").Error
+}
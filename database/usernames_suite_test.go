@@ -0,0 +1,76 @@
+package database
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const duplicateUsernamesQuery = "SELECT LOWER(TRIM(username)) AS username, COUNT(*) AS count FROM `users` GROUP BY LOWER(TRIM(username)) HAVING COUNT(*) > 1 ORDER BY username"
+
+type UsernamesSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	db   *gorm.DB
+}
+
+func TestSuiteUsernames(t *testing.T) {
+	suite.Run(t, new(UsernamesSuite))
+}
+
+func (s *UsernamesSuite) SetupSuite() {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		s.Error(err)
+	}
+
+	gDB, err := gorm.Open(mysql.New(mysql.Config{
+		SkipInitializeWithVersion: true,
+		Conn:                      db,
+	}), &gorm.Config{})
+	if err != nil {
+		s.Error(err)
+	}
+
+	s.db = gDB
+	s.mock = mock
+}
+
+func (s *UsernamesSuite) TearDownSuite() {}
+
+// FindDuplicateUsernames
+func (s *UsernamesSuite) TestFindDuplicateUsernames() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateUsernamesQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "count"}).
+			AddRow("nobita", 2).
+			AddRow("suneo", 3))
+
+	dups, err := FindDuplicateUsernames(s.db)
+	s.NoError(err)
+	s.Equal([]DuplicateUsername{{Username: "nobita", Count: 2}, {Username: "suneo", Count: 3}}, dups)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+
+// normalizeUsernames
+func (s *UsernamesSuite) TestNormalizeUsernames() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateUsernamesQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "count"}))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET username = LOWER(TRIM(username))")).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	s.NoError(normalizeUsernames(s.db))
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *UsernamesSuite) TestNormalizeUsernamesErrorDuplicate() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(duplicateUsernamesQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "count"}).
+			AddRow("nobita", 2))
+
+	err := normalizeUsernames(s.db)
+	s.EqualError(err, `error, duplicate usernames have to be renamed before migrating: "nobita" (2 users)`)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
@@ -8,5 +8,7 @@ import (
 type Token struct {
 	UUID     uuid.UUID `json:"uuid" form:"uuid"`
 	Username string    `json:"username" form:"username"`
+	// SessionID is the session the access token was issued for.
+	SessionID uuid.UUID `json:"sid" form:"sid"`
 	jwt.StandardClaims
 }
@@ -3,4 +3,28 @@ package dto
 type Login struct {
 	Username string `json:"username" form:"username"`
 	Token    string `json:"token" form:"token"`
+
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" validate:"required"`
+}
+
+// SignUp creates a user. Passwords are at most 72 bytes, the most bcrypt
+// reads.
+type SignUp struct {
+	Username string `json:"username" form:"username" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,min=8,maxbytes=72"`
+}
+
+// Credentials log a user in. Passwords set before the minimum length was
+// enforced still work.
+type Credentials struct {
+	Username string `json:"username" form:"username" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,maxbytes=72"`
+}
+
+type UpdateUsername struct {
+	Username string `json:"username" form:"username" validate:"required"`
 }
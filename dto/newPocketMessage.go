@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NewPocketMessage struct {
+	Title         string     `json:"title" form:"title" validate:"required,max=255"`
+	Content       string     `json:"content" form:"content" validate:"required,maxbytes=65536"`
+	Format        string     `json:"format" form:"format" validate:"oneof=plain markdown"` // plain when empty
+	Encrypted     bool       `json:"encrypted" form:"encrypted"`
+	BurnAfterRead bool       `json:"burn_after_read" form:"burn_after_read"`
+	ExpiredAt     *time.Time `json:"expired_at" form:"expired_at" validate:"future"`
+	ExpiresIn     string     `json:"expires_in" form:"expires_in" validate:"duration"` // Go duration, e.g. "24h" or "90m"
+	MaxVisit      int        `json:"max_visit" form:"max_visit" validate:"min=0"`
+	Passphrase    string     `json:"passphrase" form:"passphrase" validate:"maxbytes=72"`
+	Slug          string     `json:"slug" form:"slug" validate:"slug"` // custom random id, generated when empty
+	Label         string     `json:"label" form:"label" validate:"max=100"`
+}
+
+// UpdatePocketMessage replaces the title and content of the pocket message
+// UUID.
+type UpdatePocketMessage struct {
+	UUID      uuid.UUID `json:"-" form:"-"`
+	Title     string    `json:"title" form:"title" validate:"required,max=255"`
+	Content   string    `json:"content" form:"content" validate:"required,maxbytes=65536"`
+	Format    string    `json:"format" form:"format" validate:"oneof=plain markdown"`
+	Encrypted bool      `json:"encrypted" form:"encrypted"`
+}
@@ -1,8 +1,106 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OwnedMessage is one pocket message of its owner. Visit adds up the visits
+// of all its share links; RandomID is one of them.
 type OwnedMessage struct {
-	RandomID string `json:"random_id"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	Visit    int    `json:"visit"`
+	UUID      uuid.UUID `json:"uuid"`
+	RandomID  string    `json:"random_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Visit     int       `json:"visit"`
+	Links     int       `json:"links"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ID        uint   `json:"-"`
+	Encrypted bool   `json:"encrypted"`
+	KeyID     string `json:"-"`
+	DataKey   string `json:"-"`
+}
+
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+	SortVisits  = "visits"
+)
+
+// Statuses of an owned message, by its share links. The reaper deletes
+// expired links, and the messages they leave without one, every
+// messages.reaper_interval, so a message is only expired until its next run.
+// A message without links is one whose links were all revoked, which the
+// reaper keeps.
+const (
+	StatusActive  = "active"  // a share link can be opened
+	StatusExpired = "expired" // no share link can be opened any more
+	StatusRevoked = "revoked" // no share link is left
+)
+
+// OwnedMessageQuery selects a page of the owner's messages. Messages come
+// after the one After points at in the sort order.
+type OwnedMessageQuery struct {
+	Sort  string
+	Desc  bool
+	Limit int
+	After *OwnedMessageCursor
+
+	Title       string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Status      string
+}
+
+// OwnedMessageCursor is the position of a message in a listing: the value
+// it is sorted by and its id to break ties. Total carries the total of a
+// title filtered listing from its first page, since counting it means
+// decrypting every title of the owner.
+type OwnedMessageCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d"`
+	Time  time.Time `json:"t,omitempty"`
+	Visit int       `json:"v,omitempty"`
+	ID    uint      `json:"id"`
+	Total *int64    `json:"n,omitempty"`
+}
+
+// CursorOf returns the position of m in a listing sorted by sort.
+func (m OwnedMessage) CursorOf(sort string, desc bool) OwnedMessageCursor {
+	c := OwnedMessageCursor{Sort: sort, Desc: desc, ID: m.ID}
+	switch sort {
+	case SortUpdated:
+		c.Time = m.UpdatedAt
+	case SortVisits:
+		c.Visit = m.Visit
+	default:
+		c.Time = m.CreatedAt
+	}
+	return c
+}
+
+// OwnedMessagePage is a page of a listing. With a title filter, Total is
+// counted on the first page and carried over by the cursor, so it does not
+// change while paging.
+type OwnedMessagePage struct {
+	Messages   []OwnedMessage `json:"messages"`
+	NextCursor string         `json:"next_cursor"`
+	Total      int64          `json:"total"`
+}
+
+// ListOwnedMessages asks for a page of the caller's messages. Zero values
+// take the defaults; Cursor is the NextCursor of the previous page.
+type ListOwnedMessages struct {
+	Sort   string // created, updated or visits
+	Order  string // asc or desc
+	Limit  int
+	Cursor string
+
+	Title       string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Status      string // active, expired or revoked
 }
@@ -0,0 +1,19 @@
+package dto
+
+type Passphrase struct {
+	Passphrase string `json:"passphrase" form:"passphrase"`
+}
+
+// ReadPocketMessage opens the share link RandomID for Viewer.
+type ReadPocketMessage struct {
+	RandomID   string
+	Passphrase string
+	Viewer     Viewer
+}
+
+// Viewer describes who opened a share link, for the stats of the message.
+type Viewer struct {
+	IP        string
+	UserAgent string
+	Referrer  string
+}
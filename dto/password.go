@@ -0,0 +1,15 @@
+package dto
+
+type PasswordResetRequest struct {
+	Username string `json:"username" form:"username" validate:"required"`
+}
+
+type PasswordResetConfirm struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,min=8,maxbytes=72"`
+}
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" form:"current_password" validate:"required,maxbytes=72"`
+	NewPassword     string `json:"new_password" form:"new_password" validate:"required,min=8,maxbytes=72"`
+}
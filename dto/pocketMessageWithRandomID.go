@@ -1,11 +1,26 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type PocketMessageWithRandomID struct {
-	UUID     uuid.UUID `json:"uuid"`
-	Title    string    `json:"title"`
-	Content  string    `json:"content"`
-	Visit    int       `json:"visit"`
-	RandomID string    `json:"random_id"`
+	UUID          uuid.UUID  `json:"uuid"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	Format        string     `json:"format"`
+	Visit         int        `json:"visit"`
+	RandomID      string     `json:"random_id"`
+	BurnAfterRead bool       `json:"burn_after_read"`
+	Encrypted     bool       `json:"encrypted"`
+	MaxVisit      int        `json:"max_visit"`
+	ExpiredAt     *time.Time `json:"expired_at"`
+
+	PassphraseHash string     `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	KeyID          string     `json:"-"`
+	DataKey        string     `json:"-"`
 }
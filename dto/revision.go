@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"pocket-message/pkg/diff"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Revision struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Format    string    `json:"format"`
+	Encrypted bool      `json:"encrypted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionDiff lists the line edits turning revision From into revision To.
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+}
+
+// RevisionDiffRequest compares two revisions of the pocket message UUID.
+// Zero numbers default to the latest revision and the one before it.
+type RevisionDiffRequest struct {
+	UUID uuid.UUID
+	From int
+	To   int
+}
@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchResult is a pocket message matching a search. Title and Snippet are
+// HTML escaped, with the matching words wrapped in <mark>.
+type SearchResult struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchPocketMessages struct {
+	Query string
+	Limit int // SearchDefaultLimit when zero
+}
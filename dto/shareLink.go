@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+type NewShareLink struct {
+	Label      string     `json:"label" form:"label" validate:"max=100"`
+	ExpiredAt  *time.Time `json:"expired_at" form:"expired_at" validate:"future"`
+	ExpiresIn  string     `json:"expires_in" form:"expires_in" validate:"duration"` // Go duration, e.g. "24h" or "90m"
+	MaxVisit   int        `json:"max_visit" form:"max_visit" validate:"min=0"`
+	Passphrase string     `json:"passphrase" form:"passphrase" validate:"maxbytes=72"`
+	Slug       string     `json:"slug" form:"slug" validate:"slug"` // custom random id, generated when empty
+}
+
+type ShareLink struct {
+	RandomID  string     `json:"random_id"`
+	Label     string     `json:"label"`
+	Visit     int        `json:"visit"`
+	MaxVisit  int        `json:"max_visit"`
+	ExpiredAt *time.Time `json:"expired_at"`
+	Protected bool       `json:"protected"`
+	CreatedAt time.Time  `json:"created_at"`
+}
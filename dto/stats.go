@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MessageStats struct {
+	TotalVisits    int           `json:"total_visits"` // lifetime visits of the current links
+	Views          int           `json:"views"`        // views between from and to
+	UniqueVisitors int           `json:"unique_visitors"`
+	From           time.Time     `json:"from"`
+	To             time.Time     `json:"to"`
+	Bucket         string        `json:"bucket"`
+	Links          []LinkStats   `json:"links"`
+	Series         []StatsBucket `json:"series"`
+}
+
+type LinkStats struct {
+	RandomID string `json:"random_id"`
+	Label    string `json:"label"`
+	Visit    int    `json:"visit"`
+	Views    int    `json:"views"`
+}
+
+type StatsBucket struct {
+	Start time.Time `json:"start"`
+	Views int       `json:"views"`
+}
+
+// StatsRequest asks for the views of the pocket message UUID between From
+// and To. Bucket, From and To default to the last 30 days by day.
+type StatsRequest struct {
+	UUID   uuid.UUID
+	Bucket string
+	From   *time.Time
+	To     *time.Time
+}
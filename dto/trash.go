@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrashedMessage is a deleted pocket message that can still be restored.
+// PurgeAt is when it gets deleted permanently, nil when the trash is kept
+// until emptied by hand.
+type TrashedMessage struct {
+	UUID      uuid.UUID  `json:"uuid"`
+	Title     string     `json:"title"`
+	Encrypted bool       `json:"encrypted"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"`
+}
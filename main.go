@@ -1,25 +1,98 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"os"
 	"pocket-message/configs"
 	"pocket-message/database"
+	"pocket-message/models"
+	"pocket-message/pkg/search"
+	"pocket-message/repositories"
 	"pocket-message/routes"
+	"pocket-message/services"
 )
 
 func main() {
-	db, err := database.ConnectDB()
+	// "config print" shows the configuration the server would start with.
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		cfg, err := configs.Load(flag.NewFlagSet("config print", flag.ExitOnError), os.Args[3:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = cfg.Redacted().WriteYAML(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := configs.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 
-	err = database.MigrateDB(db)
+	repo, index, reindex, err := openStorage(cfg)
 	if err != nil {
 		panic(err)
 	}
+	if reindex {
+		go func() {
+			n, err := services.RebuildSearchIndex(repo, index)
+			if err != nil {
+				log.Printf("search: failed to rebuild the index: %v", err)
+				return
+			}
+			log.Printf("search: indexed %d pocket messages", n)
+		}()
+	}
+
+	go services.RunExpiredMessageReaper(context.Background(), repo, cfg.Messages.ReaperInterval)
+	if cfg.Messages.TrashRetention > 0 {
+		go services.RunTrashPurger(context.Background(), repo, cfg.Messages.ReaperInterval, cfg.Messages.TrashRetention)
+	}
 
-	e := routes.Init(db)
-	err = e.Start(configs.APIPort)
+	e, err := routes.Init(cfg, repo, index)
 	if err != nil {
 		panic(err)
 	}
+	err = e.Start(cfg.API.Port)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// openStorage connects to the database of cfg and migrates it, and opens the
+// search index. reindex tells whether the index lacks the saved messages.
+func openStorage(cfg *configs.Config) (repo repositories.Database, index search.SearchIndex, reindex bool, err error) {
+	if cfg.Database.Driver == "memory" {
+		// The messages go away with the process, so there is nothing to index.
+		return repositories.NewMemory(), search.NewMemory(), false, nil
+	}
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	switch cfg.Search.Index {
+	case "mysql":
+		index = repositories.NewFullTextIndex(db, []byte(cfg.Search.Key))
+	case "memory":
+		index = search.NewMemory()
+	}
+	// Messages saved before the index existed are added in the background.
+	reindex = cfg.Search.Index == "memory" || !db.Migrator().HasTable(&models.MessageSearch{})
+
+	err = database.MigrateDB(db)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	kr, err := cfg.Keyring()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return repositories.NewEncryptedGorm(db, kr), index, reindex, nil
 }
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pocket-message/pkg/apperr"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorHandler is the echo.HTTPErrorHandler of the API. Every failure is
+// answered with {"code": ..., "message": ...} and the status of its kind.
+// Rejected requests also list their invalid fields under "errors".
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, code, message := describe(err)
+	if status == http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		body := echo.Map{
+			"code":    code,
+			"message": message,
+		}
+		if fields := apperr.As(err).Fields; len(fields) > 0 {
+			body["errors"] = fields
+		}
+		err = c.JSON(status, body)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func describe(err error) (int, string, string) {
+	// Errors raised by echo itself, like unknown routes, failed binding or the JWT guard.
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		code := strings.ToLower(strings.ReplaceAll(http.StatusText(he.Code), " ", "_"))
+		message := fmt.Sprint(he.Message)
+		if he.Internal != nil && he.Code >= http.StatusInternalServerError {
+			message = http.StatusText(he.Code)
+		}
+		return he.Code, code, message
+	}
+
+	e := apperr.As(err)
+	return apperr.Status(e.Kind), e.Code, e.Message
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pocket-message/pkg/apperr"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorHandlerSuite struct {
+	suite.Suite
+}
+
+func TestSuiteErrorHandler(t *testing.T) {
+	suite.Run(t, new(ErrorHandlerSuite))
+}
+
+func (s *ErrorHandlerSuite) SetupSuite() {}
+
+func (s *ErrorHandlerSuite) TearDownSuite() {}
+
+// ErrorHandler
+func (s *ErrorHandlerSuite) TestErrorHandler() {
+	testCase := []struct {
+		name          string
+		err           error
+		expectCode    int
+		expectErrCode string
+		expectMessage string
+		expectFields  []apperr.FieldError
+	}{
+		{
+			name:          "error_handler-validation",
+			err:           apperr.Validation("error, title should not be empty"),
+			expectCode:    http.StatusBadRequest,
+			expectErrCode: "validation_failed",
+			expectMessage: "error, title should not be empty",
+		},
+		{
+			name: "error_handler-invalid_fields",
+			err: apperr.Invalid([]apperr.FieldError{
+				{Field: "title", Message: "error, title should not be empty"},
+				{Field: "format", Message: "error, format should be plain or markdown"},
+			}),
+			expectCode:    http.StatusBadRequest,
+			expectErrCode: "validation_failed",
+			expectMessage: "error, title should not be empty",
+			expectFields: []apperr.FieldError{
+				{Field: "title", Message: "error, title should not be empty"},
+				{Field: "format", Message: "error, format should be plain or markdown"},
+			},
+		},
+		{
+			name:          "error_handler-conflict",
+			err:           apperr.Conflict("username has been taken"),
+			expectCode:    http.StatusConflict,
+			expectErrCode: "conflict",
+			expectMessage: "username has been taken",
+		},
+		{
+			name:          "error_handler-unauthorized",
+			err:           ErrMissingToken,
+			expectCode:    http.StatusUnauthorized,
+			expectErrCode: "missing_token",
+			expectMessage: "authorization header not found",
+		},
+		{
+			name:          "error_handler-echo_error",
+			err:           echo.ErrUnsupportedMediaType,
+			expectCode:    http.StatusUnsupportedMediaType,
+			expectErrCode: "unsupported_media_type",
+			expectMessage: "Unsupported Media Type",
+		},
+		{
+			name:          "error_handler-internal_hides_message",
+			err:           errors.New("dial tcp 10.0.0.1:3306: connection refused"),
+			expectCode:    http.StatusInternalServerError,
+			expectErrCode: "internal",
+			expectMessage: "internal server error",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := httptest.NewRecorder()
+			c := echo.New().NewContext(r, w)
+
+			ErrorHandler(v.err, c)
+
+			type response struct {
+				Code    string              `json:"code"`
+				Message string              `json:"message"`
+				Errors  []apperr.FieldError `json:"errors"`
+			}
+			var resp response
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				s.Error(err, "error unmarshalling")
+			}
+
+			s.Equal(v.expectCode, w.Result().StatusCode)
+			s.Equal(v.expectErrCode, resp.Code)
+			s.Equal(v.expectMessage, resp.Message)
+			s.Equal(v.expectFields, resp.Errors)
+		})
+	}
+}
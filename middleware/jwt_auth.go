@@ -1,51 +1,102 @@
 package middleware
 
 import (
-	"errors"
-	"pocket-message/configs"
 	"pocket-message/dto"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/jwtkeys"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
-func GetToken(uuid uuid.UUID, username string) (string, error) {
+var (
+	ErrMissingToken = apperr.New(apperr.KindUnauthorized, "missing_token", "authorization header not found")
+	ErrInvalidToken = apperr.New(apperr.KindUnauthorized, "invalid_token", "token is wrong or expired")
+)
+
+// Tokens signs and verifies access tokens with a key set. Access tokens are
+// valid for ttl; clients renew them with their refresh token.
+type Tokens struct {
+	keys *jwtkeys.KeySet
+	ttl  time.Duration
+}
+
+func NewTokens(ks *jwtkeys.KeySet, ttl time.Duration) *Tokens {
+	return &Tokens{keys: ks, ttl: ttl}
+}
+
+// JWT guards a route with an access token signed by any key of the key set.
+func (t *Tokens) JWT() echo.MiddlewareFunc {
+	return middleware.JWTWithConfig(middleware.JWTConfig{
+		KeyFunc: func(token *jwt.Token) (interface{}, error) {
+			return t.keys.Keyfunc(token)
+		},
+	})
+}
+
+// SignSessionToken issues an access token bound to the given session, so it
+// stops working as soon as the session is revoked.
+func (t *Tokens) SignSessionToken(uuid uuid.UUID, username string, sid uuid.UUID) (string, error) {
 
 	claims := jwt.MapClaims{}
 	claims["uuid"] = uuid
 	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims["sid"] = sid
+	claims["exp"] = time.Now().Add(t.ttl).Unix()
 
-	return token.SignedString([]byte(configs.TokenSecret))
+	return t.keys.Sign(claims)
 }
 
-func DecodeJWT(ctx echo.Context) (dto.Token, error) {
-	var t dto.Token
+func (t *Tokens) DecodeJWT(ctx echo.Context) (dto.Token, error) {
+	var result dto.Token
 
 	auth := ctx.Request().Header.Get("Authorization")
 	if auth == "" {
-		return dto.Token{}, errors.New("authorization header not found")
+		return dto.Token{}, ErrMissingToken
 	}
 
 	splitToken := strings.Split(auth, "Bearer ")
+	if len(splitToken) != 2 {
+		return dto.Token{}, ErrInvalidToken
+	}
 	auth = splitToken[1]
 
-	token, err := jwt.ParseWithClaims(auth, &dto.Token{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(configs.TokenSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(auth, &dto.Token{}, t.keys.Keyfunc)
 	if err != nil {
-		return dto.Token{}, errors.New("token is wrong or expired")
+		return dto.Token{}, ErrInvalidToken
 	}
 
 	if claims, ok := token.Claims.(*dto.Token); ok && token.Valid {
-		t.UUID = claims.UUID
-		t.Username = claims.Username
+		result.UUID = claims.UUID
+		result.Username = claims.Username
+		result.SessionID = claims.SessionID
 	}
 
-	return t, nil
+	return result, nil
+}
+
+// Principal hands the caller of an authenticated route to the services
+// through the request context. It must run after the JWT middleware.
+func (t *Tokens) Principal() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := t.DecodeJWT(c)
+			if err != nil {
+				return err
+			}
+
+			ctx := authz.NewContext(c.Request().Context(), authz.Principal{
+				UUID:      token.UUID,
+				Username:  token.Username,
+				SessionID: token.SessionID,
+			})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
 }
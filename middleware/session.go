@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SessionStore interface {
+	GetSessionByUUID(id uuid.UUID) (models.Session, error)
+}
+
+// ActiveSession rejects access tokens whose session has been revoked or has
+// expired. It must run after the JWT middleware.
+func ActiveSession(store SessionStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := c.Get("user").(*jwt.Token)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+			}
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+			}
+			raw, _ := claims["sid"].(string)
+			sid, err := uuid.Parse(raw)
+			if err != nil || sid == uuid.Nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session revoked or expired")
+			}
+
+			session, err := store.GetSessionByUUID(sid)
+			if errors.Is(err, gorm.ErrRecordNotFound) || apperr.IsKind(err, apperr.KindNotFound) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session revoked or expired")
+			}
+			// Other errors are the store failing, not the caller.
+			if err != nil {
+				return err
+			}
+			if !session.Active(time.Now()) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session revoked or expired")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type sessionStore struct {
+	session models.Session
+	err     error
+}
+
+func (s sessionStore) GetSessionByUUID(uuid.UUID) (models.Session, error) {
+	return s.session, s.err
+}
+
+type SessionSuite struct {
+	suite.Suite
+}
+
+func TestSuiteSession(t *testing.T) {
+	suite.Run(t, new(SessionSuite))
+}
+
+func (s *SessionSuite) SetupSuite() {}
+
+func (s *SessionSuite) TearDownSuite() {}
+
+// ActiveSession
+func (s *SessionSuite) TestActiveSession() {
+	unauthorized := echo.NewHTTPError(http.StatusUnauthorized, "session revoked or expired")
+	revokedAt := time.Now().Add(-time.Minute)
+	testCase := []struct {
+		name        string
+		store       sessionStore
+		expectError error
+	}{
+		{
+			name:  "active_session-normal",
+			store: sessionStore{session: models.Session{ExpiredAt: time.Now().Add(time.Hour)}},
+		},
+		{
+			name:        "active_session-error_revoked",
+			store:       sessionStore{session: models.Session{ExpiredAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}},
+			expectError: unauthorized,
+		},
+		{
+			name:        "active_session-error_not_found",
+			store:       sessionStore{err: gorm.ErrRecordNotFound},
+			expectError: unauthorized,
+		},
+		{
+			name:        "active_session-error_not_found_kind",
+			store:       sessionStore{err: apperr.Wrap(apperr.KindNotFound, "not_found", gorm.ErrRecordNotFound)},
+			expectError: unauthorized,
+		},
+		{
+			name:        "active_session-error_db",
+			store:       sessionStore{err: errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sid": uuid.New().String()}})
+
+			err := ActiveSession(v.store)(func(echo.Context) error { return nil })(c)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// MessageSearch holds the terms of a pocket message for full-text search.
+// Terms are stored as keyed hashes, so the index does not reveal messages
+// that are encrypted at rest.
+type MessageSearch struct {
+	PocketMessageUUID uuid.UUID `gorm:"type:VARCHAR(191);primaryKey"`
+	UserUUID          uuid.UUID `gorm:"type:VARCHAR(191);index"`
+	TitleTerms        string    `gorm:"type:TEXT;index:,class:FULLTEXT"`
+	ContentTerms      string    `gorm:"type:MEDIUMTEXT;index:,class:FULLTEXT"`
+}
+
+func (MessageSearch) TableName() string {
+	return "message_search"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageView is one successful read of a pocket message through a share
+// link. The reader's IP address is only stored hashed.
+type MessageView struct {
+	ID                uint      `gorm:"primaryKey"`
+	PocketMessageUUID uuid.UUID `gorm:"type:VARCHAR(191);index:idx_message_views_message_viewed_at,priority:1"`
+	RandomID          string    `gorm:"type:VARCHAR(191);index"`
+	ViewedAt          time.Time `gorm:"index:idx_message_views_message_viewed_at,priority:2"`
+	IPHash            string    `gorm:"type:VARCHAR(64)"`
+	UserAgent         string    `gorm:"type:VARCHAR(255)"`
+	Referrer          string    `gorm:"type:VARCHAR(255)"`
+}
+
+func (MessageView) TableName() string {
+	return "message_views"
+}
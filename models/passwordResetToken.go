@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordResetToken struct {
+	gorm.Model
+	TokenHash string    `gorm:"type:VARCHAR(64);uniqueIndex"`
+	UserUUID  uuid.UUID `gorm:"type:VARCHAR(191);index"`
+	ExpiredAt time.Time
+	UsedAt    *time.Time
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
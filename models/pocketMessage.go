@@ -5,14 +5,30 @@ import (
 	"gorm.io/gorm"
 )
 
+// Formats of the content of a pocket message. Rows saved before formats
+// existed have none and are plain text.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
 type PocketMessage struct {
 	gorm.Model
-	UUID     uuid.UUID `json:"uuid" gorm:"primaryKey"`
-	Title    string    `json:"title" form:"title"`
-	Content  string    `json:"content" form:"content"`
-	UserUUID uuid.UUID `json:"user_uuid" form:"user_uuid"`
+	UUID          uuid.UUID `json:"uuid" gorm:"primaryKey"`
+	Title         string    `json:"title" form:"title"`
+	Content       string    `json:"content" form:"content"`
+	Format        string    `json:"format" form:"format" gorm:"type:VARCHAR(16)"`
+	UserUUID      uuid.UUID `json:"user_uuid" form:"user_uuid"`
+	BurnAfterRead bool      `json:"burn_after_read" form:"burn_after_read"`
+	Encrypted     bool      `json:"encrypted" form:"encrypted"`
+	KeyID         string    `json:"-"`
+	DataKey       string    `json:"-"`
 }
 
 func (PocketMessage) TableName() string {
 	return "pocket_messages"
 }
+
+func (pm PocketMessage) OwnerUUID() uuid.UUID {
+	return pm.UserUUID
+}
@@ -1,15 +1,23 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type PocketMessageRandomID struct {
 	gorm.Model
-	RandomID          string    `json:"random_id" form:"random_id"`
-	Visit             int       `json:"visit"`
-	PocketMessageUUID uuid.UUID `json:"pocket_message_uuid" form:"pocket_message_uuid" gorm:"type:VARCHAR(191)"`
+	RandomID          string     `json:"random_id" form:"random_id" gorm:"type:VARCHAR(191);uniqueIndex"`
+	Label             string     `json:"label" form:"label"`
+	Visit             int        `json:"visit"`
+	MaxVisit          int        `json:"max_visit" form:"max_visit"`
+	ExpiredAt         *time.Time `json:"expired_at" form:"expired_at"`
+	PocketMessageUUID uuid.UUID  `json:"pocket_message_uuid" form:"pocket_message_uuid" gorm:"type:VARCHAR(191);index"`
+	PassphraseHash    string     `json:"-"`
+	FailedAttempts    int        `json:"-"`
+	LockedUntil       *time.Time `json:"-"`
 }
 
 type Tabler interface {
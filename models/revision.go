@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PocketMessageRevision is one saved version of a pocket message, numbered
+// from 1 in the order the versions were saved. Like the message itself, the
+// title and content are encrypted at rest when a keyring is configured.
+type PocketMessageRevision struct {
+	ID                uint      `gorm:"primaryKey"`
+	PocketMessageUUID uuid.UUID `gorm:"type:VARCHAR(191);uniqueIndex:idx_revisions_message_number,priority:1"`
+	Number            int       `gorm:"uniqueIndex:idx_revisions_message_number,priority:2"`
+	Title             string
+	Content           string
+	Format            string `gorm:"type:VARCHAR(16)"`
+	Encrypted         bool
+	KeyID             string
+	DataKey           string
+	CreatedAt         time.Time
+}
+
+func (PocketMessageRevision) TableName() string {
+	return "pocket_message_revisions"
+}
+
+// RevisionOf returns the content of pm as revision number n.
+func RevisionOf(pm PocketMessage, n int) PocketMessageRevision {
+	return PocketMessageRevision{
+		PocketMessageUUID: pm.UUID,
+		Number:            n,
+		Title:             pm.Title,
+		Content:           pm.Content,
+		Format:            pm.Format,
+		Encrypted:         pm.Encrypted,
+		KeyID:             pm.KeyID,
+		DataKey:           pm.DataKey,
+	}
+}
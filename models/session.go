@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is one refresh token. Rotating a refresh token revokes its session
+// and starts a new one in the same family, so presenting a rotated token again
+// reveals that it was stolen and the whole family gets revoked.
+type Session struct {
+	gorm.Model
+	UUID             uuid.UUID `gorm:"type:VARCHAR(191);uniqueIndex"`
+	UserUUID         uuid.UUID `gorm:"type:VARCHAR(191);index"`
+	FamilyID         uuid.UUID `gorm:"type:VARCHAR(191);index"`
+	RefreshTokenHash string    `gorm:"type:VARCHAR(64);uniqueIndex"`
+	ExpiredAt        time.Time
+	RotatedAt        *time.Time
+	RevokedAt        *time.Time
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// Active reports whether the session can still authenticate requests.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiredAt)
+}
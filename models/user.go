@@ -7,8 +7,8 @@ import (
 
 type User struct {
 	gorm.Model
-	UUID          uuid.UUID       `json:"uuid" gorm:"primaryKey;type=uuid"`
-	Username      string          `json:"username" form:"username"`
+	UUID          uuid.UUID       `json:"uuid" gorm:"primaryKey;uniqueIndex;type=uuid"`
+	Username      string          `json:"username" form:"username" gorm:"type:VARCHAR(191);uniqueIndex"`
 	Password      string          `json:"password" form:"password"`
 	PocketMessage []PocketMessage `gorm:"foreignKey:UserUUID;references:UUID;type:VARCHAR(191);constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
@@ -0,0 +1,124 @@
+// Package apperr defines the typed errors services and repositories return.
+// The kind of an error decides the HTTP status, the code is a stable machine
+// readable identifier clients can switch on.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindGone         Kind = "gone"
+	KindLocked       Kind = "locked"
+	KindInternal     Kind = "internal"
+)
+
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+	// Fields lists every invalid field of a rejected request.
+	Fields []FieldError
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error with a specific code, for errors clients need to
+// tell apart from other errors of the same kind.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap keeps err reachable through errors.Is and errors.As.
+func Wrap(kind Kind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+func Validation(message string) *Error {
+	return New(KindValidation, "validation_failed", message)
+}
+
+// Invalid reports all failing fields of a request at once. Its message is
+// the first field's, for clients that only read the message.
+func Invalid(fields []FieldError) *Error {
+	e := Validation("error, request is invalid")
+	if len(fields) > 0 {
+		e.Message = fields[0].Message
+	}
+	e.Fields = fields
+	return e
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, "not_found", message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, "conflict", message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, "unauthorized", message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, "forbidden", message)
+}
+
+// As returns the typed error in err's chain. Untyped errors become internal
+// errors that hide their message.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Kind: KindInternal, Code: "internal", Message: "internal server error", Err: err}
+}
+
+// IsKind reports whether err's chain has a typed error of kind.
+func IsKind(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+// Status is the HTTP status code of a kind.
+func Status(kind Kind) int {
+	switch kind {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindGone:
+		return http.StatusGone
+	case KindLocked:
+		return http.StatusLocked
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,92 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AppErrSuite struct {
+	suite.Suite
+}
+
+func TestSuiteAppErr(t *testing.T) {
+	suite.Run(t, new(AppErrSuite))
+}
+
+func (s *AppErrSuite) SetupSuite() {}
+
+func (s *AppErrSuite) TearDownSuite() {}
+
+// As
+func (s *AppErrSuite) TestAs() {
+	cause := errors.New("record not found")
+	testCase := []struct {
+		name          string
+		err           error
+		expectKind    Kind
+		expectCode    string
+		expectMessage string
+		expectStatus  int
+	}{
+		{
+			name:          "as-validation",
+			err:           Validation("title should not be empty"),
+			expectKind:    KindValidation,
+			expectCode:    "validation_failed",
+			expectMessage: "title should not be empty",
+			expectStatus:  http.StatusBadRequest,
+		},
+		{
+			name: "as-invalid",
+			err: Invalid([]FieldError{
+				{Field: "title", Message: "error, title should not be empty"},
+				{Field: "content", Message: "error, content should not be empty"},
+			}),
+			expectKind:    KindValidation,
+			expectCode:    "validation_failed",
+			expectMessage: "error, title should not be empty",
+			expectStatus:  http.StatusBadRequest,
+		},
+		{
+			name:          "as-wrapped",
+			err:           fmt.Errorf("loading message: %w", Wrap(KindNotFound, "not_found", cause)),
+			expectKind:    KindNotFound,
+			expectCode:    "not_found",
+			expectMessage: "record not found",
+			expectStatus:  http.StatusNotFound,
+		},
+		{
+			name:          "as-specific_code",
+			err:           New(KindGone, "pocket_message_expired", "expired"),
+			expectKind:    KindGone,
+			expectCode:    "pocket_message_expired",
+			expectMessage: "expired",
+			expectStatus:  http.StatusGone,
+		},
+		{
+			name:          "as-untyped_is_internal",
+			err:           errors.New("dial tcp: connection refused"),
+			expectKind:    KindInternal,
+			expectCode:    "internal",
+			expectMessage: "internal server error",
+			expectStatus:  http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			e := As(v.err)
+			s.Equal(v.expectKind, e.Kind)
+			s.Equal(v.expectCode, e.Code)
+			s.Equal(v.expectMessage, e.Message)
+			s.Equal(v.expectStatus, Status(e.Kind))
+		})
+	}
+}
+func (s *AppErrSuite) TestWrapKeepsCause() {
+	cause := errors.New("record not found")
+	s.ErrorIs(Wrap(KindNotFound, "not_found", cause), cause)
+}
@@ -0,0 +1,40 @@
+// Package authz decides whether a user may act on a resource. Services ask a
+// Policy before they change anything, so new sharing rules only need a new
+// Policy instead of changes to every service.
+package authz
+
+import (
+	"pocket-message/pkg/apperr"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// ErrForbidden is returned when the subject may not perform the action.
+var ErrForbidden = apperr.New(apperr.KindForbidden, "forbidden", "error, you are not allowed to access this resource")
+
+// Resource is anything owned by a single user.
+type Resource interface {
+	OwnerUUID() uuid.UUID
+}
+
+type Policy interface {
+	Authorize(subject uuid.UUID, action Action, resource Resource) error
+}
+
+// OwnerPolicy only lets the owner of a resource act on it.
+type OwnerPolicy struct{}
+
+func (OwnerPolicy) Authorize(subject uuid.UUID, action Action, resource Resource) error {
+	if resource.OwnerUUID() != subject {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthzSuite struct {
+	suite.Suite
+}
+
+func TestSuiteAuthz(t *testing.T) {
+	suite.Run(t, new(AuthzSuite))
+}
+
+func (s *AuthzSuite) SetupSuite() {}
+
+func (s *AuthzSuite) TearDownSuite() {}
+
+type resource uuid.UUID
+
+func (r resource) OwnerUUID() uuid.UUID {
+	return uuid.UUID(r)
+}
+
+// OwnerPolicy
+func (s *AuthzSuite) TestOwnerPolicy() {
+	owner := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	testCase := []struct {
+		name        string
+		subject     uuid.UUID
+		action      Action
+		expectError error
+	}{
+		{
+			name:    "owner_policy-owner_update",
+			subject: owner,
+			action:  ActionUpdate,
+		},
+		{
+			name:    "owner_policy-owner_delete",
+			subject: owner,
+			action:  ActionDelete,
+		},
+		{
+			name:        "owner_policy-error_other_user",
+			subject:     uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+			action:      ActionUpdate,
+			expectError: ErrForbidden,
+		},
+		{
+			name:        "owner_policy-error_anonymous",
+			subject:     uuid.Nil,
+			action:      ActionRead,
+			expectError: ErrForbidden,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			err := OwnerPolicy{}.Authorize(v.subject, v.action, resource(owner))
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+// Principal
+func (s *AuthzSuite) TestPrincipalFrom() {
+	p := Principal{UUID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), Username: "super"}
+	testCase := []struct {
+		name            string
+		ctx             context.Context
+		expectPrincipal Principal
+		expectError     error
+	}{
+		{
+			name:            "principal_from-normal",
+			ctx:             NewContext(context.Background(), p),
+			expectPrincipal: p,
+		},
+		{
+			name:        "principal_from-error_missing",
+			ctx:         context.Background(),
+			expectError: ErrUnauthenticated,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			got, err := PrincipalFrom(v.ctx)
+			s.Equal(v.expectError, err)
+			s.Equal(v.expectPrincipal, got)
+		})
+	}
+}
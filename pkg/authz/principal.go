@@ -0,0 +1,36 @@
+package authz
+
+import (
+	"context"
+	"pocket-message/pkg/apperr"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnauthenticated is returned when an action needs a caller and the
+// context carries none.
+var ErrUnauthenticated = apperr.New(apperr.KindUnauthorized, "unauthenticated", "error, authentication is required")
+
+// Principal is the authenticated user a request acts for.
+type Principal struct {
+	UUID     uuid.UUID
+	Username string
+	// SessionID is the session the caller logged in with.
+	SessionID uuid.UUID
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller carried by ctx.
+func PrincipalFrom(ctx context.Context) (Principal, error) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return p, nil
+}
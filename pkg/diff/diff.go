@@ -0,0 +1,110 @@
+// Package diff compares two texts line by line.
+package diff
+
+import "strings"
+
+const (
+	OpEqual  = "equal"
+	OpDelete = "delete"
+	OpInsert = "insert"
+)
+
+// MaxCells bounds the work of Lines: when the lines that differ would need a
+// larger table, the old lines are reported deleted and the new ones inserted
+// instead of looking for common lines among them.
+var MaxCells = 4 << 20
+
+// Line is a line of the old text that was kept or deleted, or a line of the
+// new text that was inserted.
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the edits that turn a into b, keeping as many lines as
+// possible.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Common leading and trailing lines need no table.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	result := make([]Line, 0, len(x)+len(y))
+	for _, l := range x[:pre] {
+		result = append(result, Line{OpEqual, l})
+	}
+	result = append(result, middle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		result = append(result, Line{OpEqual, l})
+	}
+	return result
+}
+
+// middle diffs x and y through their longest common subsequence.
+func middle(x, y []string) []Line {
+	var result []Line
+	if (len(x)+1)*(len(y)+1) > MaxCells {
+		for _, l := range x {
+			result = append(result, Line{OpDelete, l})
+		}
+		for _, l := range y {
+			result = append(result, Line{OpInsert, l})
+		}
+		return result
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			result = append(result, Line{OpEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, Line{OpDelete, x[i]})
+			i++
+		default:
+			result = append(result, Line{OpInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		result = append(result, Line{OpDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		result = append(result, Line{OpInsert, y[j]})
+	}
+	return result
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
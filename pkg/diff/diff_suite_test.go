@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DiffSuite struct {
+	suite.Suite
+}
+
+func TestSuiteDiff(t *testing.T) {
+	suite.Run(t, new(DiffSuite))
+}
+
+func (s *DiffSuite) SetupSuite() {}
+
+func (s *DiffSuite) TearDownSuite() {}
+
+// Lines
+func (s *DiffSuite) TestLines() {
+	testCase := []struct {
+		name        string
+		a           string
+		b           string
+		expectLines []Line
+	}{
+		{
+			name:        "lines-equal",
+			a:           "halo\ndunia",
+			b:           "halo\ndunia",
+			expectLines: []Line{{OpEqual, "halo"}, {OpEqual, "dunia"}},
+		},
+		{
+			name:        "lines-changed_line",
+			a:           "halo\ndunia\nkamu",
+			b:           "halo\nbumi\nkamu",
+			expectLines: []Line{{OpEqual, "halo"}, {OpDelete, "dunia"}, {OpInsert, "bumi"}, {OpEqual, "kamu"}},
+		},
+		{
+			name:        "lines-inserted_and_deleted",
+			a:           "a\nb\nc\nd",
+			b:           "b\nc\ne\nd",
+			expectLines: []Line{{OpDelete, "a"}, {OpEqual, "b"}, {OpEqual, "c"}, {OpInsert, "e"}, {OpEqual, "d"}},
+		},
+		{
+			name:        "lines-crlf",
+			a:           "halo\r\ndunia",
+			b:           "halo\ndunia",
+			expectLines: []Line{{OpEqual, "halo"}, {OpEqual, "dunia"}},
+		},
+		{
+			name:        "lines-from_empty",
+			a:           "",
+			b:           "halo",
+			expectLines: []Line{{OpInsert, "halo"}},
+		},
+		{
+			name:        "lines-both_empty",
+			expectLines: []Line{},
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectLines, Lines(v.a, v.b))
+		})
+	}
+}
+func (s *DiffSuite) TestLinesMaxCells() {
+	cells := MaxCells
+	MaxCells = 4
+	defer func() { MaxCells = cells }()
+
+	s.Equal([]Line{
+		{OpEqual, "x"},
+		{OpDelete, "a"}, {OpDelete, "b"},
+		{OpInsert, "b"}, {OpInsert, "c"},
+	}, Lines("x\na\nb", "x\nb\nc"))
+}
@@ -0,0 +1,153 @@
+// Package e2e implements the envelope format used by end-to-end encrypted
+// pocket messages. The server only ever validates the envelope structure;
+// the key lives in the URL fragment and never reaches it.
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// Version is the current envelope version.
+	Version = 1
+	// Algorithm is the only supported algorithm, AES-256 in GCM mode.
+	Algorithm = "A256GCM"
+
+	KeySize   = 32
+	NonceSize = 12
+	TagSize   = 16
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("error, content is not a valid encrypted envelope")
+	ErrInvalidKey      = errors.New("error, key should be 32 bytes encoded as base64url")
+)
+
+var encoding = base64.RawURLEncoding
+
+type Envelope struct {
+	Version    int    `json:"v"`
+	Algorithm  string `json:"alg"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ct"`
+}
+
+// GenerateKey returns a new random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeKey encodes key so it can be carried in a URL fragment.
+func EncodeKey(key []byte) string {
+	return encoding.EncodeToString(key)
+}
+
+// DecodeKey reverses EncodeKey.
+func DecodeKey(s string) ([]byte, error) {
+	key, err := encoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with key into a new envelope.
+func Seal(key, plaintext []byte) (Envelope, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	nonce := make([]byte, NonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Version:    Version,
+		Algorithm:  Algorithm,
+		Nonce:      encoding.EncodeToString(nonce),
+		Ciphertext: encoding.EncodeToString(aead.Seal(nil, nonce, plaintext, nil)),
+	}, nil
+}
+
+// Open decrypts env with key.
+func Open(key []byte, env Envelope) ([]byte, error) {
+	err := env.Validate()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, _ := encoding.DecodeString(env.Nonce)
+	ciphertext, _ := encoding.DecodeString(env.Ciphertext)
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// Validate checks the envelope structure without decrypting it.
+func (env Envelope) Validate() error {
+	if env.Version != Version || env.Algorithm != Algorithm {
+		return ErrInvalidEnvelope
+	}
+
+	nonce, err := encoding.DecodeString(env.Nonce)
+	if err != nil || len(nonce) != NonceSize {
+		return ErrInvalidEnvelope
+	}
+
+	ciphertext, err := encoding.DecodeString(env.Ciphertext)
+	if err != nil || len(ciphertext) < TagSize {
+		return ErrInvalidEnvelope
+	}
+
+	return nil
+}
+
+// Marshal returns the envelope in the form stored as message content.
+func (env Envelope) Marshal() (string, error) {
+	b, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Parse decodes and validates an envelope stored as message content.
+func Parse(content string) (Envelope, error) {
+	var env Envelope
+	err := json.Unmarshal([]byte(content), &env)
+	if err != nil {
+		return Envelope{}, ErrInvalidEnvelope
+	}
+
+	err = env.Validate()
+	if err != nil {
+		return Envelope{}, err
+	}
+	return env, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
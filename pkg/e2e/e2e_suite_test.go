@@ -0,0 +1,119 @@
+package e2e
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EnvelopeSuite struct {
+	suite.Suite
+	key []byte
+}
+
+func TestSuiteEnvelope(t *testing.T) {
+	suite.Run(t, new(EnvelopeSuite))
+}
+
+func (s *EnvelopeSuite) SetupSuite() {
+	key, err := GenerateKey()
+	if err != nil {
+		s.Error(err, "error generate key")
+	}
+	s.key = key
+}
+
+func (s *EnvelopeSuite) TearDownSuite() {}
+
+// Seal and Open
+func (s *EnvelopeSuite) TestSealOpen() {
+	testCase := []struct {
+		name      string
+		plaintext string
+	}{
+		{
+			name:      "seal_open-normal",
+			plaintext: "password wifi kantor: UwawPangkat2",
+		},
+		{
+			name:      "seal_open-empty",
+			plaintext: "",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			env, err := Seal(s.key, []byte(v.plaintext))
+			s.NoError(err)
+
+			content, err := env.Marshal()
+			s.NoError(err)
+
+			parsed, err := Parse(content)
+			s.NoError(err)
+
+			key, err := DecodeKey(EncodeKey(s.key))
+			s.NoError(err)
+
+			plaintext, err := Open(key, parsed)
+			s.NoError(err)
+			s.Equal(v.plaintext, string(plaintext))
+		})
+	}
+}
+func (s *EnvelopeSuite) TestOpenErrorWrongKey() {
+	env, err := Seal(s.key, []byte("rahasia"))
+	s.NoError(err)
+
+	other, err := GenerateKey()
+	s.NoError(err)
+
+	_, err = Open(other, env)
+	s.Error(err)
+}
+
+// Parse
+func (s *EnvelopeSuite) TestParseError() {
+	testCase := []struct {
+		name        string
+		content     string
+		expectError error
+	}{
+		{
+			name:        "parse-error_not_json",
+			content:     "halo dunia",
+			expectError: ErrInvalidEnvelope,
+		},
+		{
+			name:        "parse-error_version",
+			content:     `{"v":2,"alg":"A256GCM","nonce":"Ssn2hjGus0oVNMP-","ct":"bquSQGdkkYMOrV8f_QBedXpHTABAAQ"}`,
+			expectError: ErrInvalidEnvelope,
+		},
+		{
+			name:        "parse-error_algorithm",
+			content:     `{"v":1,"alg":"none","nonce":"Ssn2hjGus0oVNMP-","ct":"bquSQGdkkYMOrV8f_QBedXpHTABAAQ"}`,
+			expectError: ErrInvalidEnvelope,
+		},
+		{
+			name:        "parse-error_nonce",
+			content:     `{"v":1,"alg":"A256GCM","nonce":"Ssn2","ct":"bquSQGdkkYMOrV8f_QBedXpHTABAAQ"}`,
+			expectError: ErrInvalidEnvelope,
+		},
+		{
+			name:        "parse-error_ciphertext",
+			content:     `{"v":1,"alg":"A256GCM","nonce":"Ssn2hjGus0oVNMP-","ct":"bquS"}`,
+			expectError: ErrInvalidEnvelope,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			_, err := Parse(v.content)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+// DecodeKey
+func (s *EnvelopeSuite) TestDecodeKeyError() {
+	_, err := DecodeKey("tc2h1bQxnqpw686p")
+	s.Equal(ErrInvalidKey, err)
+}
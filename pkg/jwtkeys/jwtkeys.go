@@ -0,0 +1,235 @@
+// Package jwtkeys signs and verifies access tokens with a set of keys.
+// Tokens are signed with the active key and carry its id in the "kid"
+// header, so tokens signed with a retired key keep verifying while it is
+// still configured. Public keys are published as a JWK set.
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey           = errors.New("error, token signing key id is not configured")
+	ErrInvalidKey           = errors.New("error, token signing key is malformed")
+	ErrUnsupportedAlgorithm = errors.New("error, token signing algorithm should be HS256, RS256 or EdDSA")
+	ErrNoSigningKey         = errors.New("error, active token key has no private key")
+	ErrAlgorithmMismatch    = errors.New("error, token algorithm does not match its key")
+)
+
+// Key is one signing or verification key. Asymmetric keys loaded from a
+// public key can only verify.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// HMAC returns an HS256 key for secret.
+func HMAC(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// NewKey builds a key from its material: the raw secret for HS256, a PEM
+// private or public key for RS256 and EdDSA.
+func NewKey(id, alg string, material []byte) (Key, error) {
+	if id == "" {
+		return Key{}, ErrInvalidKey
+	}
+
+	switch alg {
+	case HS256:
+		if len(material) == 0 {
+			return Key{}, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+		return HMAC(id, material), nil
+	case RS256:
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(material); err == nil {
+			return Key{ID: id, Method: jwt.SigningMethodRS256, sign: private, verify: &private.PublicKey}, nil
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(material)
+		if err != nil {
+			return Key{}, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+		return Key{ID: id, Method: jwt.SigningMethodRS256, verify: public}, nil
+	case EdDSA:
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(material); err == nil {
+			return Key{ID: id, Method: jwt.SigningMethodEdDSA, sign: private, verify: private.(ed25519.PrivateKey).Public()}, nil
+		}
+		public, err := jwt.ParseEdPublicKeyFromPEM(material)
+		if err != nil {
+			return Key{}, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+		return Key{ID: id, Method: jwt.SigningMethodEdDSA, verify: public}, nil
+	}
+	return Key{}, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+}
+
+type KeySet struct {
+	activeID string
+	keys     []Key
+}
+
+// New returns a key set that signs with the key named activeID.
+func New(activeID string, keys ...Key) (*KeySet, error) {
+	seen := make(map[string]bool)
+	var active *Key
+	for i, key := range keys {
+		if key.ID == "" || seen[key.ID] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key.ID)
+		}
+		seen[key.ID] = true
+		if key.ID == activeID {
+			active = &keys[i]
+		}
+	}
+	if active == nil {
+		return nil, ErrUnknownKey
+	}
+	if active.sign == nil {
+		return nil, ErrNoSigningKey
+	}
+	return &KeySet{activeID: activeID, keys: keys}, nil
+}
+
+// FromSecret returns a key set with a single HS256 key named "default".
+func FromSecret(secret string) *KeySet {
+	return &KeySet{activeID: "default", keys: []Key{HMAC("default", []byte(secret))}}
+}
+
+// Parse builds a key set from a comma separated "id:alg:value" list. Values
+// starting with "@" name a file holding the key material; other values are
+// the base64 encoded HS256 secret. An empty list returns a nil key set. When
+// activeID is empty the only configured key becomes active.
+func Parse(spec, activeID string) (*KeySet, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var keys []Key
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return nil, ErrInvalidKey
+		}
+		id, alg, value := parts[0], parts[1], parts[2]
+
+		var material []byte
+		var err error
+		if strings.HasPrefix(value, "@") {
+			material, err = os.ReadFile(value[1:])
+		} else {
+			material, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+
+		key, err := NewKey(id, alg, material)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if activeID == "" && len(keys) == 1 {
+		activeID = keys[0].ID
+	}
+	return New(activeID, keys...)
+}
+
+// ActiveID is the id of the key new tokens are signed with.
+func (ks *KeySet) ActiveID() string {
+	return ks.activeID
+}
+
+// Sign signs claims with the active key.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, _ := ks.key(ks.activeID)
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.sign)
+}
+
+// Keyfunc finds the key a token was signed with. The token's algorithm must
+// match the key, so a public key can never be used as an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.key(kid)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrAlgorithmMismatch
+	}
+	return key.verify, nil
+}
+
+func (ks *KeySet) key(id string) (Key, bool) {
+	for _, key := range ks.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys of the set. HS256 secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Alg: RS256,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Alg: EdDSA,
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
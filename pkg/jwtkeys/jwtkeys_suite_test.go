@@ -0,0 +1,204 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/suite"
+)
+
+type JWTKeysSuite struct {
+	suite.Suite
+	rsaFile     string
+	rsaPubFile  string
+	edFile      string
+	secret      string
+	rsaKey      *rsa.PrivateKey
+	edPublicKey ed25519.PublicKey
+}
+
+func TestSuiteJWTKeys(t *testing.T) {
+	suite.Run(t, new(JWTKeysSuite))
+}
+
+func (s *JWTKeysSuite) SetupSuite() {
+	dir := s.T().TempDir()
+
+	var err error
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	s.rsaFile = writePEM(s.T(), dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(s.rsaKey))
+	public, err := x509.MarshalPKIXPublicKey(&s.rsaKey.PublicKey)
+	s.Require().NoError(err)
+	s.rsaPubFile = writePEM(s.T(), dir, "rsa.pub.pem", "PUBLIC KEY", public)
+
+	var edKey ed25519.PrivateKey
+	s.edPublicKey, edKey, err = ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	private, err := x509.MarshalPKCS8PrivateKey(edKey)
+	s.Require().NoError(err)
+	s.edFile = writePEM(s.T(), dir, "ed.pem", "PRIVATE KEY", private)
+
+	s.secret = base64.StdEncoding.EncodeToString([]byte("super secret"))
+}
+
+func (s *JWTKeysSuite) TearDownSuite() {}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// inner unwraps the error returned by Keyfunc from a jwt validation error.
+func inner(err error) error {
+	if verr, ok := err.(*jwt.ValidationError); ok {
+		return verr.Inner
+	}
+	return err
+}
+
+// Parse
+func (s *JWTKeysSuite) TestParse() {
+	testCase := []struct {
+		name         string
+		spec         string
+		activeID     string
+		expectActive string
+		expectError  error
+	}{
+		{
+			name:         "parse-hs256",
+			spec:         "h1:HS256:" + s.secret,
+			expectActive: "h1",
+		},
+		{
+			name:         "parse-rs256_and_eddsa",
+			spec:         "r1:RS256:@" + s.rsaFile + ", e1:EdDSA:@" + s.edFile,
+			activeID:     "e1",
+			expectActive: "e1",
+		},
+		{
+			name:        "parse-error_unknown_active",
+			spec:        "h1:HS256:" + s.secret + ",r1:RS256:@" + s.rsaFile,
+			expectError: ErrUnknownKey,
+		},
+		{
+			name:        "parse-error_public_key_active",
+			spec:        "r1:RS256:@" + s.rsaPubFile,
+			expectError: ErrNoSigningKey,
+		},
+		{
+			name:        "parse-error_unsupported_algorithm",
+			spec:        "h1:HS512:" + s.secret,
+			expectError: ErrUnsupportedAlgorithm,
+		},
+		{
+			name:        "parse-error_missing_file",
+			spec:        "r1:RS256:@/does/not/exist.pem",
+			expectError: ErrInvalidKey,
+		},
+		{
+			name:        "parse-error_duplicate_id",
+			spec:        "h1:HS256:" + s.secret + ",h1:RS256:@" + s.rsaFile,
+			activeID:    "h1",
+			expectError: ErrInvalidKey,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			ks, err := Parse(v.spec, v.activeID)
+			if v.expectError != nil {
+				s.ErrorIs(err, v.expectError)
+				return
+			}
+			s.NoError(err)
+			s.Equal(v.expectActive, ks.ActiveID())
+		})
+	}
+}
+func (s *JWTKeysSuite) TestParseEmpty() {
+	ks, err := Parse(" ", "")
+	s.NoError(err)
+	s.Nil(ks)
+}
+
+// Sign
+func (s *JWTKeysSuite) TestSignAndVerify() {
+	for _, spec := range []string{
+		"h1:HS256:" + s.secret,
+		"r1:RS256:@" + s.rsaFile,
+		"e1:EdDSA:@" + s.edFile,
+	} {
+		ks, err := Parse(spec, "")
+		s.Require().NoError(err)
+
+		signed, err := ks.Sign(jwt.MapClaims{"username": "udin"})
+		s.NoError(err)
+
+		token, err := jwt.Parse(signed, ks.Keyfunc)
+		s.NoError(err)
+		s.Equal(ks.ActiveID(), token.Header["kid"])
+		s.Equal("udin", token.Claims.(jwt.MapClaims)["username"])
+	}
+}
+func (s *JWTKeysSuite) TestRotation() {
+	old, err := Parse("h1:HS256:"+s.secret, "")
+	s.Require().NoError(err)
+	signed, err := old.Sign(jwt.MapClaims{})
+	s.Require().NoError(err)
+
+	rotated, err := Parse("h1:HS256:"+s.secret+",r1:RS256:@"+s.rsaFile, "r1")
+	s.Require().NoError(err)
+	_, err = jwt.Parse(signed, rotated.Keyfunc)
+	s.NoError(err)
+
+	retired, err := Parse("r1:RS256:@"+s.rsaFile, "")
+	s.Require().NoError(err)
+	_, err = jwt.Parse(signed, retired.Keyfunc)
+	s.Equal(ErrUnknownKey, inner(err))
+}
+func (s *JWTKeysSuite) TestKeyfuncErrorAlgorithmMismatch() {
+	ks, err := Parse("r1:RS256:@"+s.rsaFile, "")
+	s.Require().NoError(err)
+
+	// An HS256 token "signed" with the public key must not verify.
+	public := x509.MarshalPKCS1PublicKey(&s.rsaKey.PublicKey)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{})
+	token.Header["kid"] = "r1"
+	signed, err := token.SignedString(public)
+	s.Require().NoError(err)
+
+	_, err = jwt.Parse(signed, ks.Keyfunc)
+	s.Equal(ErrAlgorithmMismatch, inner(err))
+}
+
+// JWKS
+func (s *JWTKeysSuite) TestJWKS() {
+	ks, err := Parse("h1:HS256:"+s.secret+",r1:RS256:@"+s.rsaFile+",e1:EdDSA:@"+s.edFile, "h1")
+	s.Require().NoError(err)
+
+	set := ks.JWKS()
+	s.Len(set.Keys, 2)
+
+	s.Equal("RSA", set.Keys[0].Kty)
+	s.Equal("r1", set.Keys[0].Kid)
+	s.Equal("AQAB", set.Keys[0].E)
+	s.Equal(base64.RawURLEncoding.EncodeToString(s.rsaKey.N.Bytes()), set.Keys[0].N)
+
+	s.Equal("OKP", set.Keys[1].Kty)
+	s.Equal("e1", set.Keys[1].Kid)
+	s.Equal("Ed25519", set.Keys[1].Crv)
+	s.Equal(base64.RawURLEncoding.EncodeToString(s.edPublicKey), set.Keys[1].X)
+}
@@ -0,0 +1,180 @@
+// Package keyring implements envelope encryption for data stored at rest.
+// Every row gets its own data key, which is wrapped by one of the configured
+// key-encryption keys and stored next to the row together with the key id.
+// Rotating the key-encryption key only requires re-wrapping data keys.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const KeySize = 32
+
+var (
+	ErrUnknownKey    = errors.New("error, encryption key id is not configured")
+	ErrInvalidKey    = errors.New("error, encryption key should be 32 bytes encoded as base64")
+	ErrInvalidCipher = errors.New("error, encrypted value is malformed")
+)
+
+var encoding = base64.StdEncoding
+
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// New returns a keyring that wraps new data keys with keys[activeID].
+func New(activeID string, keys map[string][]byte) (*Keyring, error) {
+	for id, key := range keys {
+		if id == "" || len(key) != KeySize {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return &Keyring{activeID: activeID, keys: keys}, nil
+}
+
+// Parse builds a keyring from a "id:base64key,id:base64key" list. An empty
+// list disables encryption and returns a nil keyring. When activeID is empty
+// the only configured key becomes active.
+func Parse(spec, activeID string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		key, err := encoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+		keys[id] = key
+	}
+
+	if activeID == "" && len(keys) == 1 {
+		for id := range keys {
+			activeID = id
+		}
+	}
+	return New(activeID, keys)
+}
+
+// ActiveID is the id of the key used to wrap new data keys.
+func (k *Keyring) ActiveID() string {
+	return k.activeID
+}
+
+// NewDataKey generates a data key wrapped with the active key.
+func (k *Keyring) NewDataKey() (DataKey, error) {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return DataKey{}, err
+	}
+
+	wrapped, err := seal(k.keys[k.activeID], key)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return DataKey{KeyID: k.activeID, Wrapped: wrapped, key: key}, nil
+}
+
+// OpenDataKey unwraps a data key stored with a row.
+func (k *Keyring) OpenDataKey(keyID, wrapped string) (DataKey, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return DataKey{}, ErrUnknownKey
+	}
+
+	key, err := open(kek, wrapped)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return DataKey{KeyID: keyID, Wrapped: wrapped, key: key}, nil
+}
+
+// Rewrap re-wraps a stored data key with the active key.
+func (k *Keyring) Rewrap(keyID, wrapped string) (DataKey, error) {
+	dk, err := k.OpenDataKey(keyID, wrapped)
+	if err != nil {
+		return DataKey{}, err
+	}
+
+	wrapped, err = seal(k.keys[k.activeID], dk.key)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return DataKey{KeyID: k.activeID, Wrapped: wrapped, key: dk.key}, nil
+}
+
+// DataKey encrypts the fields of a single row.
+type DataKey struct {
+	KeyID   string
+	Wrapped string
+	key     []byte
+}
+
+func (d DataKey) Encrypt(plaintext string) (string, error) {
+	return seal(d.key, []byte(plaintext))
+}
+
+func (d DataKey) Decrypt(value string) (string, error) {
+	plaintext, err := open(d.key, value)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// seal returns base64(nonce || ciphertext).
+func seal(key, plaintext []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func open(key []byte, value string) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := encoding.DecodeString(value)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrInvalidCipher
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidCipher
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,131 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type KeyringSuite struct {
+	suite.Suite
+}
+
+func TestSuiteKeyring(t *testing.T) {
+	suite.Run(t, new(KeyringSuite))
+}
+
+func (s *KeyringSuite) SetupSuite() {}
+
+func (s *KeyringSuite) TearDownSuite() {}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+// Parse
+func (s *KeyringSuite) TestParse() {
+	testCase := []struct {
+		name         string
+		spec         string
+		activeID     string
+		expectActive string
+		expectError  error
+	}{
+		{
+			name:         "parse-single_key",
+			spec:         "k1:" + testKey(1),
+			expectActive: "k1",
+		},
+		{
+			name:         "parse-active_key",
+			spec:         "k1:" + testKey(1) + ", k2:" + testKey(2),
+			activeID:     "k2",
+			expectActive: "k2",
+		},
+		{
+			name:        "parse-error_unknown_active",
+			spec:        "k1:" + testKey(1) + ",k2:" + testKey(2),
+			expectError: ErrUnknownKey,
+		},
+		{
+			name:        "parse-error_missing_id",
+			spec:        testKey(1),
+			expectError: ErrInvalidKey,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			kr, err := Parse(v.spec, v.activeID)
+			s.ErrorIs(err, v.expectError)
+			if v.expectError == nil {
+				s.Equal(v.expectActive, kr.ActiveID())
+			}
+		})
+	}
+}
+func (s *KeyringSuite) TestParseEmpty() {
+	kr, err := Parse("", "")
+	s.NoError(err)
+	s.Nil(kr)
+}
+func (s *KeyringSuite) TestParseErrorKeySize() {
+	_, err := Parse("k1:c2hvcnQ=", "")
+	s.ErrorIs(err, ErrInvalidKey)
+}
+
+// DataKey
+func (s *KeyringSuite) TestEncryptDecrypt() {
+	kr, err := Parse("k1:"+testKey(1), "")
+	s.NoError(err)
+
+	dk, err := kr.NewDataKey()
+	s.NoError(err)
+	s.Equal("k1", dk.KeyID)
+
+	ciphertext, err := dk.Encrypt("superman mencari jodoh")
+	s.NoError(err)
+	s.NotContains(ciphertext, "superman")
+
+	opened, err := kr.OpenDataKey(dk.KeyID, dk.Wrapped)
+	s.NoError(err)
+
+	plaintext, err := opened.Decrypt(ciphertext)
+	s.NoError(err)
+	s.Equal("superman mencari jodoh", plaintext)
+}
+func (s *KeyringSuite) TestRewrap() {
+	old, err := Parse("k1:"+testKey(1), "")
+	s.NoError(err)
+	dk, err := old.NewDataKey()
+	s.NoError(err)
+	ciphertext, err := dk.Encrypt("tapi boong")
+	s.NoError(err)
+
+	rotated, err := Parse("k1:"+testKey(1)+",k2:"+testKey(2), "k2")
+	s.NoError(err)
+	rewrapped, err := rotated.Rewrap(dk.KeyID, dk.Wrapped)
+	s.NoError(err)
+	s.Equal("k2", rewrapped.KeyID)
+
+	current, err := Parse("k2:"+testKey(2), "")
+	s.NoError(err)
+	opened, err := current.OpenDataKey(rewrapped.KeyID, rewrapped.Wrapped)
+	s.NoError(err)
+	plaintext, err := opened.Decrypt(ciphertext)
+	s.NoError(err)
+	s.Equal("tapi boong", plaintext)
+
+	_, err = current.OpenDataKey(dk.KeyID, dk.Wrapped)
+	s.Equal(ErrUnknownKey, err)
+}
+func (s *KeyringSuite) TestDecryptErrorTampered() {
+	kr, err := Parse("k1:"+testKey(1), "")
+	s.NoError(err)
+	dk, err := kr.NewDataKey()
+	s.NoError(err)
+
+	_, err = dk.Decrypt("AAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	s.Equal(ErrInvalidCipher, err)
+}
@@ -0,0 +1,698 @@
+// Package markdown renders a subset of Markdown to HTML.
+//
+// Supported are ATX headings, paragraphs with hard line breaks, emphasis,
+// strong emphasis, strikethrough, code spans, fenced code blocks, block
+// quotes, bullet and ordered lists, thematic breaks, links and autolinks.
+// Raw HTML and entities are shown as typed, images become links to the
+// image and links to anything but http, https and mailto URLs are dropped,
+// keeping their text.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// URLSchemes are the schemes links may point to.
+var URLSchemes = []string{"http", "https", "mailto"}
+
+// hardBreak marks a hard line break in the text of a paragraph. NUL bytes of
+// the source are replaced beforehand, so it can not be typed.
+const hardBreak = '\x00'
+
+// Render returns the HTML of the Markdown src.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\x00", "\uFFFD")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+	for i, l := range lines {
+		lines[i] = expandTabs(l)
+	}
+
+	var b strings.Builder
+	blocks(&b, lines, false)
+	return b.String()
+}
+
+// blocks renders lines as a sequence of blocks. The paragraphs of tight
+// list items are rendered without <p>.
+func blocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		switch {
+		case trimmed == "":
+			i++
+		case indent < 4 && fence(trimmed) != "":
+			i = codeBlock(b, lines, i)
+		case indent < 4 && headingLevel(trimmed) > 0:
+			heading(b, trimmed)
+			i++
+		case indent < 4 && isThematicBreak(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case indent < 4 && strings.HasPrefix(trimmed, ">"):
+			i = blockquote(b, lines, i)
+		default:
+			if _, ok := listMarker(line); ok {
+				i = list(b, lines, i)
+				continue
+			}
+			i = paragraph(b, lines, i, tight)
+		}
+	}
+}
+
+// startsBlock reports whether line interrupts a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if trimmed == "" || len(line)-len(trimmed) >= 4 {
+		return trimmed == ""
+	}
+	if fence(trimmed) != "" || headingLevel(trimmed) > 0 || isThematicBreak(trimmed) || strings.HasPrefix(trimmed, ">") {
+		return true
+	}
+	m, ok := listMarker(line)
+	// Only lists starting at 1 interrupt a paragraph, so a line starting
+	// with a year does not begin a list.
+	return ok && m.content < len(line) && (!m.ordered || m.start == 1)
+}
+
+func paragraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		if len(text) > 0 && startsBlock(lines[i]) {
+			break
+		}
+		text = append(text, strings.TrimLeft(lines[i], " "))
+	}
+
+	for j := range text[:len(text)-1] {
+		switch l := text[j]; {
+		case strings.HasSuffix(l, "  "):
+			text[j] = strings.TrimRight(l, " ") + string(hardBreak)
+		case strings.HasSuffix(l, "\\"):
+			text[j] = l[:len(l)-1] + string(hardBreak)
+		}
+	}
+	text[len(text)-1] = strings.TrimRight(text[len(text)-1], " ")
+
+	if !tight {
+		b.WriteString("<p>")
+	}
+	inline(b, strings.Join(text, "\n"))
+	if !tight {
+		b.WriteString("</p>")
+	}
+	b.WriteString("\n")
+	return i
+}
+
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || (n < len(line) && line[n] != ' ') {
+		return 0
+	}
+	return n
+}
+
+func heading(b *strings.Builder, line string) {
+	level := headingLevel(line)
+	text := strings.TrimSpace(line[level:])
+	// A closing sequence of #s is not part of the heading.
+	if end := strings.TrimRight(text, "#"); end == "" || strings.HasSuffix(end, " ") {
+		text = strings.TrimSpace(end)
+	}
+
+	tag := "h" + strconv.Itoa(level)
+	b.WriteString("<" + tag + ">")
+	inline(b, text)
+	b.WriteString("</" + tag + ">\n")
+}
+
+func isThematicBreak(line string) bool {
+	var mark rune
+	n := 0
+	for _, r := range line {
+		switch {
+		case r == ' ':
+		case (r == '-' || r == '*' || r == '_') && (mark == 0 || r == mark):
+			mark = r
+			n++
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// fence returns the opening fence of a fenced code block line, "" when the
+// line opens none.
+func fence(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 || (line[0] == '`' && strings.Contains(line[n:], "`")) {
+		return ""
+	}
+	return line[:n]
+}
+
+func codeBlock(b *strings.Builder, lines []string, i int) int {
+	trimmed := strings.TrimLeft(lines[i], " ")
+	indent := len(lines[i]) - len(trimmed)
+	open := fence(trimmed)
+
+	b.WriteString("<pre><code")
+	if info := strings.Fields(trimmed[len(open):]); len(info) > 0 && isLanguage(info[0]) {
+		b.WriteString(` class="language-` + info[0] + `"`)
+	}
+	b.WriteString(">")
+
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		t := strings.TrimLeft(line, " ")
+		if len(line)-len(t) < 4 && strings.HasPrefix(t, open) && strings.Trim(t, open[:1]+" ") == "" {
+			i++
+			break
+		}
+		for k := 0; k < indent && strings.HasPrefix(line, " "); k++ {
+			line = line[1:]
+		}
+		b.WriteString(html.EscapeString(line))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func isLanguage(s string) bool {
+	for _, r := range s {
+		if !(r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '+')) {
+			return false
+		}
+	}
+	return true
+}
+
+func blockquote(b *strings.Builder, lines []string, i int) int {
+	var quoted []string
+	for ; i < len(lines); i++ {
+		t := strings.TrimLeft(lines[i], " ")
+		if len(lines[i])-len(t) < 4 && strings.HasPrefix(t, ">") {
+			t = strings.TrimPrefix(t[1:], " ")
+			quoted = append(quoted, t)
+			continue
+		}
+		// A paragraph continues on lines without >.
+		last := len(quoted) - 1
+		if t == "" || startsBlock(lines[i]) || strings.TrimSpace(quoted[last]) == "" {
+			break
+		}
+		quoted = append(quoted, lines[i])
+	}
+
+	b.WriteString("<blockquote>\n")
+	blocks(b, quoted, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+type marker struct {
+	ordered bool
+	start   int
+	// delim is the bullet or the character after the number.
+	delim byte
+	// content is the column the item's content starts at.
+	content int
+}
+
+func listMarker(line string) (marker, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := len(line) - len(trimmed)
+	if indent >= 4 || trimmed == "" {
+		return marker{}, false
+	}
+
+	var m marker
+	width := 0
+	switch c := trimmed[0]; {
+	case c == '-' || c == '*' || c == '+':
+		m.delim, width = c, 1
+	case c >= '0' && c <= '9':
+		for width < len(trimmed) && width < 9 && trimmed[width] >= '0' && trimmed[width] <= '9' {
+			width++
+		}
+		if width == len(trimmed) || (trimmed[width] != '.' && trimmed[width] != ')') {
+			return marker{}, false
+		}
+		m.ordered = true
+		m.start, _ = strconv.Atoi(trimmed[:width])
+		m.delim = trimmed[width]
+		width++
+	default:
+		return marker{}, false
+	}
+
+	rest := trimmed[width:]
+	if rest != "" && rest[0] != ' ' {
+		return marker{}, false
+	}
+	spaces := len(rest) - len(strings.TrimLeft(rest, " "))
+	if spaces == 0 || spaces > 4 || spaces == len(rest) {
+		spaces = 1
+	}
+	m.content = indent + width + spaces
+	return m, true
+}
+
+func list(b *strings.Builder, lines []string, i int) int {
+	first, _ := listMarker(lines[i])
+
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		m, ok := listMarker(lines[i])
+		if !ok || m.ordered != first.ordered || m.delim != first.delim {
+			break
+		}
+
+		item := []string{cut(lines[i], m.content)}
+		blank := false
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			t := strings.TrimLeft(line, " ")
+			switch {
+			case t == "":
+				blank = true
+				item = append(item, "")
+				continue
+			case len(line)-len(t) >= m.content:
+				if blank {
+					loose = true
+				}
+				blank = false
+				item = append(item, cut(line, m.content))
+				continue
+			case isListItem(line):
+				// The next item, or the end of the list.
+			case !blank && !startsBlock(line):
+				// A paragraph continues on lines that are not indented.
+				item = append(item, line)
+				continue
+			}
+			break
+		}
+		for len(item) > 0 && strings.TrimSpace(item[len(item)-1]) == "" {
+			item = item[:len(item)-1]
+		}
+		items = append(items, item)
+
+		if blank {
+			if next, ok := listMarker(lineAt(lines, i)); ok && next.ordered == first.ordered && next.delim == first.delim {
+				loose = true
+			}
+		}
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		blocks(&inner, item, !loose)
+		s := inner.String()
+		if !loose {
+			s = strings.TrimSuffix(s, "\n")
+		} else {
+			b.WriteString("\n")
+		}
+		b.WriteString(s)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func isListItem(line string) bool {
+	_, ok := listMarker(line)
+	return ok
+}
+
+// cut removes the first n columns of line, which may be shorter.
+func cut(line string, n int) string {
+	if len(line) <= n {
+		return ""
+	}
+	return line[n:]
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+func expandTabs(line string) string {
+	var b strings.Builder
+	for i, r := range line {
+		switch r {
+		case '\t':
+			b.WriteString(strings.Repeat(" ", 4-b.Len()%4))
+		case ' ':
+			b.WriteByte(' ')
+		default:
+			return b.String() + line[i:]
+		}
+	}
+	return b.String()
+}
+
+// inline renders the text of a paragraph or heading.
+func inline(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == hardBreak:
+			// The line ending follows the mark.
+			b.WriteString("<br>")
+			i++
+		case c == '`':
+			i = codeSpan(b, s, i)
+		case c == '<':
+			i = autolink(b, s, i)
+		case c == '[' || (c == '!' && strings.HasPrefix(s[i+1:], "[")):
+			i = link(b, s, i)
+		case c == '*' || c == '_' || c == '~':
+			i = emphasis(b, s, i)
+		default:
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune("\\`<[!*_~\x00", rune(s[j])) {
+				j++
+			}
+			b.WriteString(html.EscapeString(s[i:j]))
+			i = j
+		}
+	}
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && (unicode.IsPunct(rune(c)) || unicode.IsSymbol(rune(c)))
+}
+
+func runLength(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// codeEnd returns the index of the backtick run closing the code span
+// opened at i, or -1.
+func codeEnd(s string, i int) int {
+	n := runLength(s, i)
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		m := runLength(s, j)
+		if m == n {
+			return j
+		}
+		j += m
+	}
+	return -1
+}
+
+func codeSpan(b *strings.Builder, s string, i int) int {
+	n := runLength(s, i)
+	end := codeEnd(s, i)
+	if end < 0 {
+		b.WriteString(s[i : i+n])
+		return i + n
+	}
+
+	code := strings.NewReplacer("\n", " ", string(hardBreak), " ").Replace(s[i+n : end])
+	if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+		code = code[1 : len(code)-1]
+	}
+	b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+	return end + n
+}
+
+func autolink(b *strings.Builder, s string, i int) int {
+	end := strings.IndexByte(s[i:], '>')
+	if end > 1 {
+		dest := s[i+1 : i+end]
+		if !strings.ContainsAny(dest, " <\n\x00") {
+			href := dest
+			if !strings.Contains(dest, ":") && strings.Contains(dest, "@") {
+				href = "mailto:" + dest
+			}
+			if safeURL(href) {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(dest) + "</a>")
+				return i + end + 1
+			}
+		}
+	}
+	b.WriteString("&lt;")
+	return i + 1
+}
+
+// link renders [text](destination "title") and ![alt](source) at i.
+func link(b *strings.Builder, s string, i int) int {
+	image := s[i] == '!'
+	open := i
+	if image {
+		open++
+	}
+
+	closeBracket := -1
+	depth := 0
+loop:
+	for j := open; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			if end := codeEnd(s, j); end >= 0 {
+				j = end + runLength(s, end) - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeBracket = j
+				break loop
+			}
+		}
+	}
+	dest, title, end, ok := destination(s, closeBracket+1)
+	if closeBracket < 0 || !ok {
+		b.WriteString(html.EscapeString(s[i : open+1]))
+		return open + 1
+	}
+
+	text := s[open+1 : closeBracket]
+	if !safeURL(dest) {
+		inline(b, text)
+		return end
+	}
+	b.WriteString(`<a href="` + html.EscapeString(dest) + `"`)
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	b.WriteString(">")
+	if image {
+		b.WriteString(html.EscapeString(text))
+	} else {
+		inline(b, text)
+	}
+	b.WriteString("</a>")
+	return end
+}
+
+// destination parses `(destination "title")` at i and returns the index
+// after it.
+func destination(s string, i int) (dest, title string, end int, ok bool) {
+	if i <= 0 || i >= len(s) || s[i] != '(' {
+		return "", "", 0, false
+	}
+	j := skipSpaces(s, i+1)
+
+	if j < len(s) && s[j] == '<' {
+		k := strings.IndexAny(s[j+1:], ">\n")
+		if k < 0 || s[j+1+k] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[j+1 : j+1+k]
+		j += k + 2
+	} else {
+		start, depth := j, 0
+		for ; j < len(s) && s[j] > ' '; j++ {
+			if s[j] == '\\' && j+1 < len(s) {
+				j++
+			} else if s[j] == '(' {
+				depth++
+			} else if s[j] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		dest = s[start:j]
+	}
+
+	k := skipSpaces(s, j)
+	if k > j && k < len(s) && strings.IndexByte(`"'(`, s[k]) >= 0 {
+		closer := s[k]
+		if closer == '(' {
+			closer = ')'
+		}
+		t := strings.IndexByte(s[k+1:], closer)
+		if t < 0 {
+			return "", "", 0, false
+		}
+		title = unescape(s[k+1 : k+1+t])
+		k = skipSpaces(s, k+t+2)
+	}
+	if k >= len(s) || s[k] != ')' {
+		return "", "", 0, false
+	}
+	return unescape(dest), title, k + 1, true
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func safeURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	for _, scheme := range URLSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// emphasis renders *em*, **strong**, ***both*** and ~~strikethrough~~ at
+// i. Underscores only emphasize whole words.
+func emphasis(b *strings.Builder, s string, i int) int {
+	c := s[i]
+	n := runLength(s, i)
+	if (c == '~' && n != 2) || n > 3 {
+		b.WriteString(s[i : i+n])
+		return i + n
+	}
+
+	before, _ := utf8.DecodeLastRuneInString(s[:i])
+	after, _ := utf8.DecodeRuneInString(s[i+n:])
+	opens := i+n < len(s) && !unicode.IsSpace(after) && after != hardBreak
+	if c == '_' && i > 0 && isWordRune(before) {
+		opens = false
+	}
+	end := -1
+	if opens {
+		end = emphasisEnd(s, i+n, c, n)
+	}
+	if end < 0 {
+		b.WriteString(s[i : i+n])
+		return i + n
+	}
+
+	tags := map[int][2]string{
+		1: {"<em>", "</em>"},
+		2: {"<strong>", "</strong>"},
+		3: {"<em><strong>", "</strong></em>"},
+	}[n]
+	if c == '~' {
+		tags = [2]string{"<del>", "</del>"}
+	}
+	b.WriteString(tags[0])
+	inline(b, s[i+n:end])
+	b.WriteString(tags[1])
+	return end + n
+}
+
+// emphasisEnd returns the index of the run of n c closing an emphasis whose
+// content starts at i, or -1. Runs of another length are nested emphasis.
+func emphasisEnd(s string, i int, c byte, n int) int {
+	for j := i; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if end := codeEnd(s, j); end >= 0 {
+				j = end + runLength(s, end)
+				continue
+			}
+		case c:
+			m := runLength(s, j)
+			before, _ := utf8.DecodeLastRuneInString(s[:j])
+			after, _ := utf8.DecodeRuneInString(s[j+m:])
+			closes := j > i && !unicode.IsSpace(before) && before != hardBreak
+			if c == '_' && j+m < len(s) && isWordRune(after) {
+				closes = false
+			}
+			if m == n && closes {
+				return j
+			}
+			j += m
+			continue
+		}
+		j++
+	}
+	return -1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
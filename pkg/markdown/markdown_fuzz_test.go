@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// unsafe returns why the HTML s could run script in a browser, or "" when
+// it can not: a script element, an event handler attribute or a link to a
+// javascript:, vbscript: or data: URL, however its scheme is spelled.
+func unsafe(s string) string {
+	if strings.Contains(strings.ToLower(s), "<script") {
+		return "script element"
+	}
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			return ""
+		}
+		if tt != nethtml.StartTagToken && tt != nethtml.SelfClosingTagToken {
+			continue
+		}
+		for _, attr := range z.Token().Attr {
+			key := strings.ToLower(attr.Key)
+			if strings.HasPrefix(key, "on") {
+				return "event handler " + attr.Key
+			}
+			if key != "href" && key != "src" {
+				continue
+			}
+			// Browsers drop tabs and newlines from URLs and ignore leading
+			// control characters and spaces.
+			url := strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(attr.Val)
+			url = strings.ToLower(strings.TrimLeft(url, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x0b\x0c\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f "))
+			for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+				if strings.HasPrefix(url, scheme) {
+					return "link to " + attr.Val
+				}
+			}
+		}
+	}
+}
+
+func FuzzRender(f *testing.F) {
+	for _, src := range []string{
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"[x](&#106;avascript:alert(1))",
+		"[x](javascript&colon;alert(1))",
+		"[x](<java\tscript:alert(1)>)",
+		"[x](\\javascript:alert(1))",
+		"![x](data:text/html,<script>alert(1)</script>)",
+		"<javascript:alert(1)>",
+		"<JAVASCRIPT:alert(1)>",
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[x](https://example.com \"\\\" onmouseover=\\\"alert(1)\")",
+		"```\"><script>\nalert(1)\n```",
+		"- *a* [b](http://x)\n  > `c`",
+	} {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		out := Render(src)
+		if reason := unsafe(out); reason != "" {
+			t.Errorf("Render(%q) = %q has a %s", src, out, reason)
+		}
+	})
+}
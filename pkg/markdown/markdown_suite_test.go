@@ -0,0 +1,178 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MarkdownSuite struct {
+	suite.Suite
+}
+
+func TestSuiteMarkdown(t *testing.T) {
+	suite.Run(t, new(MarkdownSuite))
+}
+
+func (s *MarkdownSuite) SetupSuite() {}
+
+func (s *MarkdownSuite) TearDownSuite() {}
+
+// Render
+func (s *MarkdownSuite) TestRenderBlocks() {
+	testCase := []struct {
+		name       string
+		src        string
+		expectHTML string
+	}{
+		{
+			name:       "render-paragraphs",
+			src:        "halo\ndunia\n\nhalo kamu",
+			expectHTML: "<p>halo\ndunia</p>\n<p>halo kamu</p>\n",
+		},
+		{
+			name:       "render-hard_breaks",
+			src:        "halo  \ndunia\\\nkamu",
+			expectHTML: "<p>halo<br>\ndunia<br>\nkamu</p>\n",
+		},
+		{
+			name:       "render-headings",
+			src:        "# Halo\n### Dunia ###\n#hashtag",
+			expectHTML: "<h1>Halo</h1>\n<h3>Dunia</h3>\n<p>#hashtag</p>\n",
+		},
+		{
+			name:       "render-thematic_break",
+			src:        "halo\n* * *\ndunia",
+			expectHTML: "<p>halo</p>\n<hr>\n<p>dunia</p>\n",
+		},
+		{
+			name:       "render-fenced_code",
+			src:        "```go\nif a < b {\n\treturn\n}\n```\nhalo",
+			expectHTML: "<pre><code class=\"language-go\">if a &lt; b {\n    return\n}\n</code></pre>\n<p>halo</p>\n",
+		},
+		{
+			name:       "render-fenced_code_unsafe_language",
+			src:        "~~~ \"><script>\nx\n~~~",
+			expectHTML: "<pre><code>x\n</code></pre>\n",
+		},
+		{
+			name:       "render-unclosed_fence",
+			src:        "```\n# halo",
+			expectHTML: "<pre><code># halo\n</code></pre>\n",
+		},
+		{
+			name:       "render-blockquote",
+			src:        "> halo\ndunia\n> > kamu",
+			expectHTML: "<blockquote>\n<p>halo\ndunia</p>\n<blockquote>\n<p>kamu</p>\n</blockquote>\n</blockquote>\n",
+		},
+		{
+			name:       "render-tight_list",
+			src:        "- halo\n- dunia\n  - kamu\n- semua",
+			expectHTML: "<ul>\n<li>halo</li>\n<li>dunia\n<ul>\n<li>kamu</li>\n</ul></li>\n<li>semua</li>\n</ul>\n",
+		},
+		{
+			name:       "render-loose_ordered_list",
+			src:        "3. halo\n\n4. dunia",
+			expectHTML: "<ol start=\"3\">\n<li>\n<p>halo</p>\n</li>\n<li>\n<p>dunia</p>\n</li>\n</ol>\n",
+		},
+		{
+			name:       "render-number_does_not_interrupt_paragraph",
+			src:        "lahir tahun\n1999. halo",
+			expectHTML: "<p>lahir tahun\n1999. halo</p>\n",
+		},
+		{
+			name:       "render-raw_html_escaped",
+			src:        "<script>alert(1)</script>\n\n<b onclick=\"x\">halo</b>",
+			expectHTML: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n<p>&lt;b onclick=&#34;x&#34;&gt;halo&lt;/b&gt;</p>\n",
+		},
+		{
+			name:       "render-crlf_and_nul",
+			src:        "halo\r\ndunia\x00",
+			expectHTML: "<p>halo\ndunia�</p>\n",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectHTML, Render(v.src))
+		})
+	}
+}
+
+func (s *MarkdownSuite) TestRenderInline() {
+	testCase := []struct {
+		name       string
+		src        string
+		expectHTML string
+	}{
+		{
+			name:       "render-emphasis",
+			src:        "*halo* **dunia** ***kamu*** ~~semua~~",
+			expectHTML: "<p><em>halo</em> <strong>dunia</strong> <em><strong>kamu</strong></em> <del>semua</del></p>\n",
+		},
+		{
+			name:       "render-nested_emphasis",
+			src:        "*halo **dunia** kamu*",
+			expectHTML: "<p><em>halo <strong>dunia</strong> kamu</em></p>\n",
+		},
+		{
+			name:       "render-intraword_underscore",
+			src:        "snake_case_name and _halo_",
+			expectHTML: "<p>snake_case_name and <em>halo</em></p>\n",
+		},
+		{
+			name:       "render-unmatched_delimiters",
+			src:        "2 * 3 = 6 and **halo",
+			expectHTML: "<p>2 * 3 = 6 and **halo</p>\n",
+		},
+		{
+			name:       "render-code_span",
+			src:        "run `a <b> *c*` or `` ` ``",
+			expectHTML: "<p>run <code>a &lt;b&gt; *c*</code> or <code>`</code></p>\n",
+		},
+		{
+			name:       "render-backslash_escape",
+			src:        "\\*halo\\* \\<b>",
+			expectHTML: "<p>*halo* &lt;b&gt;</p>\n",
+		},
+		{
+			name:       "render-link",
+			src:        "[halo *dunia*](https://example.com/a_(b) \"judul\")",
+			expectHTML: "<p><a href=\"https://example.com/a_(b)\" title=\"judul\">halo <em>dunia</em></a></p>\n",
+		},
+		{
+			name:       "render-unsafe_link",
+			src:        "[halo](javascript:alert(1)) [dunia](/relative)",
+			expectHTML: "<p>halo dunia</p>\n",
+		},
+		{
+			name:       "render-obfuscated_unsafe_link",
+			src:        "[halo](JaVaScRiPt:alert(1)) [dunia](&#106;avascript:alert(1)) [kamu](<java\tscript:alert(1)>)",
+			expectHTML: "<p>halo dunia kamu</p>\n",
+		},
+		{
+			name:       "render-image_as_link",
+			src:        "![kucing](https://example.com/cat.png)",
+			expectHTML: "<p><a href=\"https://example.com/cat.png\">kucing</a></p>\n",
+		},
+		{
+			name:       "render-not_a_link",
+			src:        "[halo] dunia",
+			expectHTML: "<p>[halo] dunia</p>\n",
+		},
+		{
+			name:       "render-autolinks",
+			src:        "<https://example.com?a=1&b=2> <halo@example.com> <javascript:alert(1)>",
+			expectHTML: "<p><a href=\"https://example.com?a=1&amp;b=2\">https://example.com?a=1&amp;b=2</a> <a href=\"mailto:halo@example.com\">halo@example.com</a> &lt;javascript:alert(1)&gt;</p>\n",
+		},
+		{
+			name:       "render-quote_in_link",
+			src:        "[halo](https://example.com/\"onmouseover=\"x)",
+			expectHTML: "<p><a href=\"https://example.com/&#34;onmouseover=&#34;x\">halo</a></p>\n",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectHTML, Render(v.src))
+		})
+	}
+}
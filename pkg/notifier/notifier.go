@@ -0,0 +1,51 @@
+// Package notifier delivers out-of-band messages such as password reset
+// tokens to users.
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+type Notifier interface {
+	Notify(recipient, subject, body string) error
+}
+
+// New returns a FileNotifier appending to path, or a LogNotifier when path is empty.
+func New(path string) Notifier {
+	if path == "" {
+		return LogNotifier{}
+	}
+	return &FileNotifier{Path: path}
+}
+
+// LogNotifier writes notifications to the standard logger. It is meant for local development.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(recipient, subject, body string) error {
+	log.Printf("notify %s: %s: %s", recipient, subject, body)
+	return nil
+}
+
+// FileNotifier appends notifications to a file, one per line.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (n *FileNotifier) Notify(recipient, subject, body string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", time.Now().Format(time.RFC3339), recipient, subject, body)
+	return err
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NotifierSuite struct {
+	suite.Suite
+}
+
+func TestSuiteNotifier(t *testing.T) {
+	suite.Run(t, new(NotifierSuite))
+}
+
+func (s *NotifierSuite) SetupSuite() {}
+
+func (s *NotifierSuite) TearDownSuite() {}
+
+func (s *NotifierSuite) TestNew() {
+	s.Equal(LogNotifier{}, New(""))
+	s.Equal(&FileNotifier{Path: "reset.log"}, New("reset.log"))
+}
+
+func (s *NotifierSuite) TestFileNotifier() {
+	path := filepath.Join(s.T().TempDir(), "notifications.log")
+	n := New(path)
+
+	s.NoError(n.Notify("udin", "password reset", "use token abc"))
+	s.NoError(n.Notify("suneo", "password reset", "use token def"))
+
+	b, err := os.ReadFile(path)
+	s.NoError(err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	s.Len(lines, 2)
+	s.True(strings.HasSuffix(lines[0], "\tudin\tpassword reset\tuse token abc"))
+	s.True(strings.HasSuffix(lines[1], "\tsuneo\tpassword reset\tuse token def"))
+}
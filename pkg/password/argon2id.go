@@ -0,0 +1,104 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidArgon2idHash = errors.New("error, stored argon2id hash is malformed")
+
+// DefaultArgon2idHasher follows the RFC 9106 second recommended option.
+var DefaultArgon2idHasher = Argon2idHasher{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// Argon2idHasher stores hashes in the PHC string format
+// $argon2id$v=19$m=MEMORY,t=TIME,p=THREADS$SALT$KEY.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(stored, password string) (bool, error) {
+	return verify(stored, password)
+}
+
+func (h Argon2idHasher) NeedsRehash(stored string) bool {
+	if !isArgon2id(stored) {
+		return true
+	}
+	p, _, key, err := parseArgon2id(stored)
+	if err != nil {
+		return true
+	}
+	return p.Time != h.Time || p.Memory != h.Memory || p.Threads != h.Threads || uint32(len(key)) != h.KeyLen
+}
+
+func verifyArgon2id(stored, password string) (bool, error) {
+	p, salt, key, err := parseArgon2id(stored)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func parseArgon2id(stored string) (Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return Argon2idHasher{}, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	var p Argon2idHasher
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, ErrInvalidArgon2idHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idHasher{}, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,116 @@
+// Package password hashes and verifies user passwords.
+//
+// Hashers recognise every supported storage format so the configured
+// algorithm or cost can change at any time: stored values that do not match
+// the current policy, including legacy plain text rows, still verify and are
+// reported by NeedsRehash so callers can upgrade them after a successful login.
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var ErrUnknownAlgorithm = errors.New("error, unknown password hash algorithm")
+
+type Hasher interface {
+	// Hash returns the storage form of password under the current policy.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the stored value.
+	Verify(stored, password string) (bool, error)
+	// NeedsRehash reports whether stored was produced by another policy.
+	NeedsRehash(stored string) bool
+}
+
+// NewHasher returns the hasher for algorithm. cost is the bcrypt cost or the
+// argon2id number of passes; zero selects the default.
+func NewHasher(algorithm string, cost int) (Hasher, error) {
+	switch algorithm {
+	case Bcrypt, "":
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("error, bcrypt cost should be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return BcryptHasher{Cost: cost}, nil
+	case Argon2id:
+		h := DefaultArgon2idHasher
+		if cost != 0 {
+			if cost < 1 {
+				return nil, errors.New("error, argon2id passes should be positive")
+			}
+			h.Time = uint32(cost)
+		}
+		return h, nil
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+}
+
+// ParseCost parses a cost from configuration, treating "" as the default.
+func ParseCost(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(stored, password string) (bool, error) {
+	return verify(stored, password)
+}
+
+func (h BcryptHasher) NeedsRehash(stored string) bool {
+	if !isBcrypt(stored) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return err != nil || cost != h.Cost
+}
+
+// verify checks password against any supported storage format.
+func verify(stored, password string) (bool, error) {
+	switch {
+	case isBcrypt(stored):
+		err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case isArgon2id(stored):
+		return verifyArgon2id(stored, password)
+	default:
+		// Rows saved before passwords were hashed.
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1, nil
+	}
+}
+
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+func isArgon2id(stored string) bool {
+	return strings.HasPrefix(stored, "$argon2id$")
+}
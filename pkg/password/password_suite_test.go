@@ -0,0 +1,119 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordSuite struct {
+	suite.Suite
+	bcrypt   Hasher
+	argon2id Hasher
+}
+
+func TestSuitePassword(t *testing.T) {
+	suite.Run(t, new(PasswordSuite))
+}
+
+func (s *PasswordSuite) SetupSuite() {
+	s.bcrypt = BcryptHasher{Cost: bcrypt.MinCost}
+	s.argon2id = Argon2idHasher{Time: 1, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}
+}
+
+func (s *PasswordSuite) TearDownSuite() {}
+
+// Hash and Verify
+func (s *PasswordSuite) TestHashVerify() {
+	testCase := []struct {
+		name   string
+		hasher Hasher
+	}{
+		{name: "hash_verify-bcrypt", hasher: s.bcrypt},
+		{name: "hash_verify-argon2id", hasher: s.argon2id},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			stored, err := v.hasher.Hash("akuGantenk")
+			s.NoError(err)
+			s.NotEqual("akuGantenk", stored)
+			s.False(v.hasher.NeedsRehash(stored))
+
+			ok, err := v.hasher.Verify(stored, "akuGantenk")
+			s.NoError(err)
+			s.True(ok)
+
+			ok, err = v.hasher.Verify(stored, "akuJelek")
+			s.NoError(err)
+			s.False(ok)
+		})
+	}
+}
+
+// Policy changes
+func (s *PasswordSuite) TestNeedsRehash() {
+	bcryptHash, err := s.bcrypt.Hash("akuGantenk")
+	s.NoError(err)
+	argonHash, err := s.argon2id.Hash("akuGantenk")
+	s.NoError(err)
+
+	testCase := []struct {
+		name   string
+		hasher Hasher
+		stored string
+		expect bool
+	}{
+		{name: "needs_rehash-plain_text", hasher: s.bcrypt, stored: "akuGantenk", expect: true},
+		{name: "needs_rehash-bcrypt_cost_changed", hasher: BcryptHasher{Cost: bcrypt.MinCost + 1}, stored: bcryptHash, expect: true},
+		{name: "needs_rehash-bcrypt_to_argon2id", hasher: s.argon2id, stored: bcryptHash, expect: true},
+		{name: "needs_rehash-argon2id_to_bcrypt", hasher: s.bcrypt, stored: argonHash, expect: true},
+		{name: "needs_rehash-argon2id_passes_changed", hasher: Argon2idHasher{Time: 2, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}, stored: argonHash, expect: true},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expect, v.hasher.NeedsRehash(v.stored))
+
+			ok, err := v.hasher.Verify(v.stored, "akuGantenk")
+			s.NoError(err)
+			s.True(ok)
+		})
+	}
+}
+func (s *PasswordSuite) TestVerifyLegacyPlainText() {
+	ok, err := s.bcrypt.Verify("akuGantenk", "akuJelek")
+	s.NoError(err)
+	s.False(ok)
+}
+func (s *PasswordSuite) TestVerifyErrorMalformedArgon2id() {
+	_, err := s.argon2id.Verify("$argon2id$v=19$m=1024$salt$key", "akuGantenk")
+	s.Equal(ErrInvalidArgon2idHash, err)
+}
+
+// NewHasher
+func (s *PasswordSuite) TestNewHasher() {
+	testCase := []struct {
+		name        string
+		algorithm   string
+		cost        int
+		expect      Hasher
+		expectError bool
+	}{
+		{name: "new_hasher-bcrypt_default", algorithm: Bcrypt, expect: BcryptHasher{Cost: bcrypt.DefaultCost}},
+		{name: "new_hasher-bcrypt_cost", algorithm: Bcrypt, cost: 12, expect: BcryptHasher{Cost: 12}},
+		{name: "new_hasher-argon2id_default", algorithm: Argon2id, expect: DefaultArgon2idHasher},
+		{name: "new_hasher-error_bcrypt_cost", algorithm: Bcrypt, cost: 99, expectError: true},
+		{name: "new_hasher-error_unknown", algorithm: "md5", expectError: true},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			h, err := NewHasher(v.algorithm, v.cost)
+			if v.expectError {
+				s.Error(err)
+				return
+			}
+			s.NoError(err)
+			s.Equal(v.expect, h)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package randomid generates the public ids pocket messages are shared by
+// and validates the custom slugs owners can pick instead.
+package randomid
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+const (
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	DefaultLength   = 10
+)
+
+var (
+	ErrInvalidAlphabet = errors.New("error, random id alphabet should have 2 to 256 distinct characters")
+	ErrInvalidLength   = errors.New("error, random id length should be between 4 and 64")
+)
+
+// slugPattern keeps custom slugs URL safe without escaping.
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{2,63}$`)
+
+// Generator draws ids uniformly from its alphabet.
+type Generator struct {
+	Alphabet string
+	Length   int
+	// Rand is the source of randomness, crypto/rand when nil.
+	Rand io.Reader
+}
+
+func Default() Generator {
+	return Generator{Alphabet: DefaultAlphabet, Length: DefaultLength}
+}
+
+// Parse builds a generator from its configuration strings. Empty values
+// select the defaults.
+func Parse(alphabet, length string) (Generator, error) {
+	g := Default()
+	if alphabet != "" {
+		g.Alphabet = alphabet
+	}
+	if length != "" {
+		n, err := strconv.Atoi(length)
+		if err != nil {
+			return Generator{}, fmt.Errorf("%w: %s", ErrInvalidLength, err)
+		}
+		g.Length = n
+	}
+
+	if len(g.Alphabet) < 2 || len(g.Alphabet) > 256 {
+		return Generator{}, ErrInvalidAlphabet
+	}
+	seen := make(map[rune]bool)
+	for _, r := range g.Alphabet {
+		if r >= 0x80 || seen[r] {
+			return Generator{}, ErrInvalidAlphabet
+		}
+		seen[r] = true
+	}
+	if g.Length < 4 || g.Length > 64 {
+		return Generator{}, ErrInvalidLength
+	}
+	return g, nil
+}
+
+// Generate returns a new random id. Bytes that would bias the pick towards
+// the start of the alphabet are rejected.
+func (g Generator) Generate() (string, error) {
+	src := g.Rand
+	if src == nil {
+		src = rand.Reader
+	}
+
+	n := len(g.Alphabet)
+	limit := 256 - 256%n
+	id := make([]byte, 0, g.Length)
+	buf := make([]byte, g.Length)
+	for len(id) < g.Length {
+		_, err := io.ReadFull(src, buf)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(id) < g.Length {
+				id = append(id, g.Alphabet[int(b)%n])
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// ValidSlug reports whether slug can be used as a custom random id: 3 to 64
+// letters, digits, "-" or "_", starting with a letter or digit.
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
@@ -0,0 +1,130 @@
+package randomid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RandomIDSuite struct {
+	suite.Suite
+}
+
+func TestSuiteRandomID(t *testing.T) {
+	suite.Run(t, new(RandomIDSuite))
+}
+
+func (s *RandomIDSuite) SetupSuite() {}
+
+func (s *RandomIDSuite) TearDownSuite() {}
+
+// Parse
+func (s *RandomIDSuite) TestParse() {
+	testCase := []struct {
+		name           string
+		alphabet       string
+		length         string
+		expectAlphabet string
+		expectLength   int
+		expectError    error
+	}{
+		{
+			name:           "parse-default",
+			expectAlphabet: DefaultAlphabet,
+			expectLength:   DefaultLength,
+		},
+		{
+			name:           "parse-custom",
+			alphabet:       "0123456789abcdef",
+			length:         "16",
+			expectAlphabet: "0123456789abcdef",
+			expectLength:   16,
+		},
+		{
+			name:        "parse-error_alphabet_too_short",
+			alphabet:    "a",
+			expectError: ErrInvalidAlphabet,
+		},
+		{
+			name:        "parse-error_alphabet_duplicate",
+			alphabet:    "abca",
+			expectError: ErrInvalidAlphabet,
+		},
+		{
+			name:        "parse-error_alphabet_not_ascii",
+			alphabet:    "abcé",
+			expectError: ErrInvalidAlphabet,
+		},
+		{
+			name:        "parse-error_length_too_short",
+			length:      "3",
+			expectError: ErrInvalidLength,
+		},
+		{
+			name:        "parse-error_length_invalid",
+			length:      "ten",
+			expectError: ErrInvalidLength,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			g, err := Parse(v.alphabet, v.length)
+			if v.expectError != nil {
+				s.ErrorIs(err, v.expectError)
+				return
+			}
+			s.NoError(err)
+			s.Equal(v.expectAlphabet, g.Alphabet)
+			s.Equal(v.expectLength, g.Length)
+		})
+	}
+}
+
+// Generate
+func (s *RandomIDSuite) TestGenerate() {
+	g := Default()
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := g.Generate()
+		s.Require().NoError(err)
+		s.Len(id, DefaultLength)
+		s.Equal("", strings.Trim(id, DefaultAlphabet))
+		s.False(seen[id], "duplicate id %s", id)
+		seen[id] = true
+	}
+}
+func (s *RandomIDSuite) TestGenerateRejectsBiasedBytes() {
+	// With 62 characters bytes from 248 up would favour the first 8, so they are skipped.
+	g := Default()
+	g.Length = 4
+	g.Rand = bytes.NewReader([]byte{255, 248, 0, 61, 62, 1, 0, 0})
+
+	id, err := g.Generate()
+	s.NoError(err)
+	s.Equal("a9ab", id)
+}
+func (s *RandomIDSuite) TestGenerateErrorSource() {
+	g := Default()
+	g.Rand = bytes.NewReader([]byte{1, 2})
+
+	_, err := g.Generate()
+	s.Error(err)
+}
+
+// ValidSlug
+func (s *RandomIDSuite) TestValidSlug() {
+	for slug, valid := range map[string]bool{
+		"birthday":              true,
+		"Birthday-2022_card":    true,
+		"ab":                    false,
+		"-birthday":             false,
+		"happy birthday":        false,
+		"birthday/2022":         false,
+		strings.Repeat("a", 64): true,
+		strings.Repeat("a", 65): false,
+	} {
+		s.Equal(valid, ValidSlug(slug), slug)
+	}
+}
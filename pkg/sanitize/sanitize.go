@@ -0,0 +1,167 @@
+// Package sanitize strips HTML down to an allowlist of elements and
+// attributes, so untrusted markup can be served from our own origin.
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+type Policy struct {
+	// Elements maps every allowed element to its allowed attributes.
+	Elements map[string][]string
+	// URLSchemes are the schemes allowed in href attributes; other links
+	// lose the attribute.
+	URLSchemes []string
+	// ClassPattern is matched by allowed class attributes; without it no
+	// class is kept.
+	ClassPattern *regexp.Regexp
+}
+
+// Markdown allows what package markdown renders.
+var Markdown = Policy{
+	Elements: map[string][]string{
+		"p": nil, "br": nil, "hr": nil,
+		"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+		"em": nil, "strong": nil, "del": nil,
+		"blockquote": nil, "pre": nil, "code": {"class"},
+		"ul": nil, "ol": {"start"}, "li": nil,
+		"a": {"href", "title"},
+	},
+	URLSchemes:   []string{"http", "https", "mailto"},
+	ClassPattern: regexp.MustCompile(`^language-[A-Za-z0-9_+-]+$`),
+}
+
+// dropped are elements removed together with their content.
+var dropped = map[string]bool{
+	"script": true, "style": true, "template": true, "title": true, "textarea": true,
+	"iframe": true, "object": true, "embed": true, "noscript": true, "noembed": true,
+	"noframes": true, "xmp": true, "svg": true, "math": true, "select": true,
+}
+
+var void = map[string]bool{"br": true, "hr": true}
+
+// Sanitize returns s without the elements and attributes p does not allow.
+// The text of removed elements is kept, except for elements like script
+// whose content is not text. Comments are removed and unclosed elements are
+// closed.
+func (p Policy) Sanitize(s string) string {
+	var b strings.Builder
+	var open []string
+	skip, skipDepth := "", 0
+
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		if skip != "" {
+			switch {
+			case tt == nethtml.StartTagToken && tok.Data == skip:
+				skipDepth++
+			case tt == nethtml.EndTagToken && tok.Data == skip:
+				skipDepth--
+				if skipDepth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.TextToken:
+			b.WriteString(html.EscapeString(tok.Data))
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if dropped[tok.Data] {
+				if tt == nethtml.StartTagToken {
+					skip, skipDepth = tok.Data, 1
+				}
+				continue
+			}
+			allowed, ok := p.Elements[tok.Data]
+			if !ok {
+				continue
+			}
+			p.writeStart(&b, tok, allowed)
+			if !void[tok.Data] {
+				if tt == nethtml.SelfClosingTagToken {
+					b.WriteString("</" + tok.Data + ">")
+				} else {
+					open = append(open, tok.Data)
+				}
+			}
+		case nethtml.EndTagToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for len(open) > i {
+					b.WriteString("</" + open[len(open)-1] + ">")
+					open = open[:len(open)-1]
+				}
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+func (p Policy) writeStart(b *strings.Builder, tok nethtml.Token, allowed []string) {
+	b.WriteString("<" + tok.Data)
+	for _, attr := range tok.Attr {
+		if attr.Namespace != "" || !contains(allowed, attr.Key) || !p.allowValue(attr.Key, attr.Val) {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if tok.Data == "a" {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+}
+
+func (p Policy) allowValue(key, val string) bool {
+	switch key {
+	case "href":
+		u, err := url.Parse(strings.TrimSpace(val))
+		if err != nil {
+			return false
+		}
+		for _, scheme := range p.URLSchemes {
+			if strings.EqualFold(u.Scheme, scheme) {
+				return true
+			}
+		}
+		return false
+	case "class":
+		return p.ClassPattern != nil && p.ClassPattern.MatchString(val)
+	case "start":
+		for _, r := range val {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return val != "" && len(val) <= 9
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
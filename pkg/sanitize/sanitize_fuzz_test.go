@@ -0,0 +1,94 @@
+package sanitize
+
+import (
+	"pocket-message/pkg/markdown"
+	"strings"
+	"testing"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// unsafe returns why the HTML s could run script in a browser, or "" when
+// it can not: a script element, an event handler attribute or a link to a
+// javascript:, vbscript: or data: URL, however its scheme is spelled.
+func unsafe(s string) string {
+	if strings.Contains(strings.ToLower(s), "<script") {
+		return "script element"
+	}
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			return ""
+		}
+		if tt != nethtml.StartTagToken && tt != nethtml.SelfClosingTagToken {
+			continue
+		}
+		for _, attr := range z.Token().Attr {
+			key := strings.ToLower(attr.Key)
+			if strings.HasPrefix(key, "on") {
+				return "event handler " + attr.Key
+			}
+			if key != "href" && key != "src" {
+				continue
+			}
+			// Browsers drop tabs and newlines from URLs and ignore leading
+			// control characters and spaces.
+			url := strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(attr.Val)
+			url = strings.ToLower(strings.TrimLeft(url, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x0b\x0c\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f "))
+			for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+				if strings.HasPrefix(url, scheme) {
+					return "link to " + attr.Val
+				}
+			}
+		}
+	}
+}
+
+// obfuscated are links that spell a javascript: URL with entities, mixed
+// case and whitespace, and other ways to slip script through.
+var obfuscated = []string{
+	`<a href="JaVaScRiPt:alert(1)">x</a>`,
+	`<a href="&#106;avascript:alert(1)">x</a>`,
+	`<a href="&#x6A;&#x61;&#x76;&#x61;script:alert(1)">x</a>`,
+	`<a href="&#0000106avascript:alert(1)">x</a>`,
+	`<a href="javascript&colon;alert(1)">x</a>`,
+	`<a href="java&#9;script:alert(1)">x</a>`,
+	`<a href="java&NewLine;script:alert(1)">x</a>`,
+	`<a href="&#x01; javascript:alert(1)">x</a>`,
+	`<a href=" &#14;javascript:alert(1)">x</a>`,
+	`<a href=vbscript:msgbox(1)>x</a>`,
+	`<a href="DATA:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+	`<A HREF="javascript:alert(1)" ONCLICK="alert(1)">x</A>`,
+	`<img src=x onerror=alert(1)>`,
+	`<svg/onload=alert(1)>`,
+	`<scr<script>ipt>alert(1)</script>`,
+	`<SCRIPT>alert(1)</SCRIPT>`,
+	`<p/onmouseover=alert(1)>x</p>`,
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, s := range obfuscated {
+		f.Add(s)
+	}
+	f.Add(markdown.Render("[x](https://example.com \"t\") `code` **b**"))
+	f.Fuzz(func(t *testing.T, s string) {
+		out := Markdown.Sanitize(s)
+		if reason := unsafe(out); reason != "" {
+			t.Errorf("Sanitize(%q) = %q has a %s", s, out, reason)
+		}
+	})
+}
+
+func FuzzSanitizeMarkdown(f *testing.F) {
+	for _, s := range obfuscated {
+		f.Add(s)
+		f.Add("[x](" + s + ")")
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		out := Markdown.Sanitize(markdown.Render(src))
+		if reason := unsafe(out); reason != "" {
+			t.Errorf("Sanitize(Render(%q)) = %q has a %s", src, out, reason)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package sanitize
+
+import (
+	"pocket-message/pkg/markdown"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SanitizeSuite struct {
+	suite.Suite
+}
+
+func TestSuiteSanitize(t *testing.T) {
+	suite.Run(t, new(SanitizeSuite))
+}
+
+func (s *SanitizeSuite) SetupSuite() {}
+
+func (s *SanitizeSuite) TearDownSuite() {}
+
+// Sanitize
+func (s *SanitizeSuite) TestSanitize() {
+	testCase := []struct {
+		name       string
+		html       string
+		expectHTML string
+	}{
+		{
+			name:       "sanitize-allowed",
+			html:       "<p>halo <strong>dunia</strong><br></p>",
+			expectHTML: "<p>halo <strong>dunia</strong><br></p>",
+		},
+		{
+			name:       "sanitize-script_dropped",
+			html:       "<p>halo<script>alert(1)</script></p><style>p{}</style>",
+			expectHTML: "<p>halo</p>",
+		},
+		{
+			name:       "sanitize-nested_dropped",
+			html:       "<svg><svg></svg><p>x</p></svg>halo",
+			expectHTML: "halo",
+		},
+		{
+			name:       "sanitize-unknown_element_keeps_text",
+			html:       "<div onclick=\"x\"><b>halo</b> <img src=x onerror=alert(1)>dunia</div>",
+			expectHTML: "halo dunia",
+		},
+		{
+			name:       "sanitize-attributes",
+			html:       "<p class=\"x\" style=\"color:red\" onclick=\"x\">halo</p><ol start=\"3\" reversed><li>a</li></ol>",
+			expectHTML: "<p>halo</p><ol start=\"3\"><li>a</li></ol>",
+		},
+		{
+			name:       "sanitize-links",
+			html:       "<a href=\"https://example.com/?a=1&amp;b=2\" target=\"_top\">a</a><a href=\" JavaScript:alert(1)\">b</a><a href=\"data:text/html,x\">c</a>",
+			expectHTML: "<a href=\"https://example.com/?a=1&amp;b=2\" rel=\"nofollow noopener noreferrer\">a</a><a rel=\"nofollow noopener noreferrer\">b</a><a rel=\"nofollow noopener noreferrer\">c</a>",
+		},
+		{
+			name:       "sanitize-obfuscated_links",
+			html:       "<a href=\"&#106;avascript:alert(1)\">a</a><a href=\"JAVASCRIPT&colon;alert(1)\">b</a><a href=\"java&#9;script:alert(1)\">c</a><a href=\"&#x01;javascript:alert(1)\">d</a>",
+			expectHTML: "<a rel=\"nofollow noopener noreferrer\">a</a><a rel=\"nofollow noopener noreferrer\">b</a><a rel=\"nofollow noopener noreferrer\">c</a><a rel=\"nofollow noopener noreferrer\">d</a>",
+		},
+		{
+			name:       "sanitize-code_class",
+			html:       "<pre><code class=\"language-go\">x</code></pre><code class=\"evil\">y</code>",
+			expectHTML: "<pre><code class=\"language-go\">x</code></pre><code>y</code>",
+		},
+		{
+			name:       "sanitize-unbalanced",
+			html:       "<blockquote><p>halo</em></blockquote><strong>dunia",
+			expectHTML: "<blockquote><p>halo</p></blockquote><strong>dunia</strong>",
+		},
+		{
+			name:       "sanitize-comments_and_text",
+			html:       "<!-- x --><p>1 &lt; 2 & \"3\"</p>",
+			expectHTML: "<p>1 &lt; 2 &amp; &#34;3&#34;</p>",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectHTML, Markdown.Sanitize(v.html))
+		})
+	}
+}
+
+func (s *SanitizeSuite) TestSanitizeMarkdown() {
+	src := "# Halo\n\n> *dunia* and [kamu](https://example.com \"x\")\n\n```go\nx\n```\n\n1. a\n2. b\n\n---"
+	rendered := markdown.Render(src)
+
+	// The rendered markdown is kept as it is, apart from the rel of links.
+	s.Equal("<h1>Halo</h1>\n<blockquote>\n<p><em>dunia</em> and <a href=\"https://example.com\" title=\"x\" rel=\"nofollow noopener noreferrer\">kamu</a></p>\n</blockquote>\n<pre><code class=\"language-go\">x\n</code></pre>\n<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n<hr>\n", Markdown.Sanitize(rendered))
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Memory is an inverted index kept in process. It is lost on restart, which
+// makes it suited to tests and single instance development setups.
+type Memory struct {
+	mu       sync.RWMutex
+	owners   map[uuid.UUID]uuid.UUID
+	postings map[string]map[uuid.UUID]float64
+	terms    map[uuid.UUID][]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		owners:   make(map[uuid.UUID]uuid.UUID),
+		postings: make(map[string]map[uuid.UUID]float64),
+		terms:    make(map[uuid.UUID][]string),
+	}
+}
+
+func (m *Memory) Index(doc Document) error {
+	weights := make(map[string]float64)
+	for _, term := range Terms(doc.Title) {
+		weights[term] += TitleWeight
+	}
+	for _, term := range Terms(doc.Content) {
+		weights[term]++
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(doc.ID)
+	m.owners[doc.ID] = doc.Owner
+	for term, weight := range weights {
+		if m.postings[term] == nil {
+			m.postings[term] = make(map[uuid.UUID]float64)
+		}
+		m.postings[term][doc.ID] = weight
+		m.terms[doc.ID] = append(m.terms[doc.ID], term)
+	}
+	return nil
+}
+
+func (m *Memory) Remove(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(id)
+	return nil
+}
+
+func (m *Memory) remove(id uuid.UUID) {
+	for _, term := range m.terms[id] {
+		delete(m.postings[term], id)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	delete(m.terms, id)
+	delete(m.owners, id)
+}
+
+// Search scores documents by the weight of every query term in them times
+// how rare the term is.
+func (m *Memory) Search(owner uuid.UUID, query string, limit int) ([]Hit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make(map[uuid.UUID]float64)
+	seen := make(map[string]bool)
+	for _, term := range Terms(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := m.postings[term]
+		idf := math.Log(1 + float64(len(m.owners))/float64(len(postings)))
+		for id, weight := range postings {
+			if m.owners[id] == owner {
+				scores[id] += weight * idf
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.String() < hits[j].ID.String()
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
@@ -0,0 +1,161 @@
+// Package search finds pocket messages by the words of their titles and
+// contents.
+//
+// Indexes only hold terms, so the snippets shown with a result are built
+// from the message itself with Highlight.
+package search
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MinTermLength is the length of the shortest indexed term, in characters.
+	MinTermLength = 2
+	// TitleWeight is how much more a term in the title counts than one in the content.
+	TitleWeight = 2
+)
+
+type SearchIndex interface {
+	// Index adds doc or replaces the document with the same id.
+	Index(doc Document) error
+	// Remove drops the document with the given id; unknown ids are ignored.
+	Remove(id uuid.UUID) error
+	// Search returns up to limit documents of owner matching any term of
+	// query, best first.
+	Search(owner uuid.UUID, query string, limit int) ([]Hit, error)
+}
+
+// Document is the searchable text of a pocket message.
+type Document struct {
+	ID      uuid.UUID
+	Owner   uuid.UUID
+	Title   string
+	Content string
+}
+
+type Hit struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// Terms splits text into lower case words, in order and with repetitions.
+func Terms(text string) []string {
+	var terms []string
+	for _, span := range spans(text) {
+		terms = append(terms, span.term)
+	}
+	return terms
+}
+
+// HashTerms replaces every term with a keyed hash, so an index can rank
+// documents without storing their words.
+func HashTerms(key []byte, terms []string) []string {
+	hashed := make([]string, len(terms))
+	for i, term := range terms {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(term))
+		hashed[i] = hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	return hashed
+}
+
+// Highlight returns an HTML escaped excerpt of text of about width
+// characters around the first word matching terms, each matching word
+// wrapped in <mark>. It returns "" when no word matches.
+func Highlight(text string, terms []string, width int) string {
+	want := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		want[term] = true
+	}
+
+	var matches []span
+	for _, s := range spans(text) {
+		if want[s.term] {
+			matches = append(matches, s)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	start := matches[0].start - width/3
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	end := start + width
+	if end < matches[0].end {
+		end = matches[0].end
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start >= end {
+			break
+		}
+		if m.start < pos {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// span is a word of a text, positioned in runes.
+type span struct {
+	term       string
+	start, end int
+}
+
+func spans(text string) []span {
+	var result []span
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		if j-i >= MinTermLength {
+			result = append(result, span{term: strings.ToLower(string(runes[i:j])), start: i, end: j})
+		}
+		i = j
+	}
+	return result
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
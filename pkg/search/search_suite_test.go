@@ -0,0 +1,153 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchSuite struct {
+	suite.Suite
+}
+
+func TestSuiteSearch(t *testing.T) {
+	suite.Run(t, new(SearchSuite))
+}
+
+func (s *SearchSuite) SetupSuite() {}
+
+func (s *SearchSuite) TearDownSuite() {}
+
+// Terms
+func (s *SearchSuite) TestTerms() {
+	testCase := []struct {
+		name        string
+		text        string
+		expectTerms []string
+	}{
+		{
+			name:        "terms-words",
+			text:        "Halo, Dunia! halo",
+			expectTerms: []string{"halo", "dunia", "halo"},
+		},
+		{
+			name:        "terms-unicode",
+			text:        "Café über 2022",
+			expectTerms: []string{"café", "über", "2022"},
+		},
+		{
+			name:        "terms-skip_short",
+			text:        "a b cd",
+			expectTerms: []string{"cd"},
+		},
+		{
+			name: "terms-empty",
+			text: "?!",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectTerms, Terms(v.text))
+		})
+	}
+}
+
+// HashTerms
+func (s *SearchSuite) TestHashTerms() {
+	hashed := HashTerms([]byte("key"), []string{"halo", "dunia", "halo"})
+	s.Len(hashed, 3)
+	s.Len(hashed[0], 16)
+	s.Equal(hashed[0], hashed[2])
+	s.NotEqual(hashed[0], hashed[1])
+	s.NotEqual(hashed, HashTerms([]byte("other key"), []string{"halo", "dunia", "halo"}))
+}
+
+// Highlight
+func (s *SearchSuite) TestHighlight() {
+	testCase := []struct {
+		name         string
+		text         string
+		terms        []string
+		width        int
+		expectResult string
+	}{
+		{
+			name:         "highlight-whole_text",
+			text:         "halo kamu, halo dunia",
+			terms:        []string{"halo"},
+			width:        100,
+			expectResult: "<mark>halo</mark> kamu, <mark>halo</mark> dunia",
+		},
+		{
+			name:         "highlight-case_insensitive",
+			text:         "Halo Kamu",
+			terms:        []string{"kamu"},
+			width:        100,
+			expectResult: "Halo <mark>Kamu</mark>",
+		},
+		{
+			name:         "highlight-excerpt",
+			text:         "one two three four five six seven eight nine ten",
+			terms:        []string{"six"},
+			width:        12,
+			expectResult: "…five <mark>six</mark> seven…",
+		},
+		{
+			name:         "highlight-escape",
+			text:         "<b>kamu</b> & aku",
+			terms:        []string{"kamu"},
+			width:        100,
+			expectResult: "&lt;b&gt;<mark>kamu</mark>&lt;/b&gt; &amp; aku",
+		},
+		{
+			name:         "highlight-word_only",
+			text:         "kamukamu",
+			terms:        []string{"kamu"},
+			width:        100,
+			expectResult: "",
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectResult, Highlight(v.text, v.terms, v.width))
+		})
+	}
+}
+
+// Memory
+func (s *SearchSuite) TestMemory() {
+	owner := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	inTitle := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	inContent := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	other := uuid.MustParse("00000000-0000-0000-0000-000000000004")
+
+	m := NewMemory()
+	s.NoError(m.Index(Document{ID: inTitle, Owner: owner, Title: "resep kue", Content: "tepung dan gula"}))
+	s.NoError(m.Index(Document{ID: inContent, Owner: owner, Title: "belanja", Content: "beli kue dan susu"}))
+	s.NoError(m.Index(Document{ID: other, Owner: uuid.New(), Title: "kue", Content: "kue"}))
+
+	hits, err := m.Search(owner, "Kue", 10)
+	s.NoError(err)
+	if s.Len(hits, 2) {
+		s.Equal(inTitle, hits[0].ID)
+		s.Equal(inContent, hits[1].ID)
+		s.Greater(hits[0].Score, hits[1].Score)
+	}
+
+	hits, err = m.Search(owner, "kue", 1)
+	s.NoError(err)
+	s.Len(hits, 1)
+
+	// Reindexing replaces the old terms.
+	s.NoError(m.Index(Document{ID: inTitle, Owner: owner, Title: "resep roti", Content: "tepung"}))
+	hits, err = m.Search(owner, "kue", 10)
+	s.NoError(err)
+	s.Len(hits, 1)
+
+	s.NoError(m.Remove(inContent))
+	s.NoError(m.Remove(uuid.New()))
+	hits, err = m.Search(owner, "kue", 10)
+	s.NoError(err)
+	s.Empty(hits)
+}
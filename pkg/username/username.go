@@ -0,0 +1,94 @@
+// Package username normalizes usernames and checks them against the sign-up
+// policy. Usernames are compared case-insensitively, so they are stored in
+// their normalized form.
+package username
+
+import (
+	"fmt"
+	"pocket-message/pkg/apperr"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMinLength = 3
+	DefaultMaxLength = 32
+	DefaultPattern   = `^[a-z0-9][a-z0-9._-]*$`
+	DefaultReserved  = "admin,administrator,root,system,support,api,me"
+)
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Pattern is matched against the normalized username.
+	Pattern  *regexp.Regexp
+	Reserved map[string]bool
+}
+
+func DefaultPolicy() Policy {
+	p, _ := Parse("", "", "", DefaultReserved)
+	return p
+}
+
+// Parse builds a policy from its configuration strings. Empty values select
+// the defaults; reserved is a comma separated list.
+func Parse(minLength, maxLength, pattern, reserved string) (Policy, error) {
+	p := Policy{MinLength: DefaultMinLength, MaxLength: DefaultMaxLength, Reserved: map[string]bool{}}
+
+	var err error
+	if minLength != "" {
+		p.MinLength, err = strconv.Atoi(minLength)
+		if err != nil {
+			return Policy{}, fmt.Errorf("error, username min length: %w", err)
+		}
+	}
+	if maxLength != "" {
+		p.MaxLength, err = strconv.Atoi(maxLength)
+		if err != nil {
+			return Policy{}, fmt.Errorf("error, username max length: %w", err)
+		}
+	}
+	if p.MinLength < 1 || p.MaxLength < p.MinLength {
+		return Policy{}, fmt.Errorf("error, username length should be between 1 and max, got %d to %d", p.MinLength, p.MaxLength)
+	}
+
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	p.Pattern, err = regexp.Compile(pattern)
+	if err != nil {
+		return Policy{}, fmt.Errorf("error, username pattern: %w", err)
+	}
+
+	for _, name := range strings.Split(reserved, ",") {
+		name = Normalize(name)
+		if name != "" {
+			p.Reserved[name] = true
+		}
+	}
+	return p, nil
+}
+
+// Normalize returns the form a username is stored and looked up in.
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Check normalizes name and validates it against the policy.
+func (p Policy) Check(name string) (string, error) {
+	name = Normalize(name)
+
+	n := utf8.RuneCountInString(name)
+	if n < p.MinLength || n > p.MaxLength {
+		return "", apperr.Validation(fmt.Sprintf("error, username should be %d to %d characters long", p.MinLength, p.MaxLength))
+	}
+	if p.Pattern != nil && !p.Pattern.MatchString(name) {
+		return "", apperr.Validation("error, username contains characters that are not allowed")
+	}
+	if p.Reserved[name] {
+		return "", apperr.Validation("error, username is reserved")
+	}
+	return name, nil
+}
@@ -0,0 +1,104 @@
+package username
+
+import (
+	"pocket-message/pkg/apperr"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UsernameSuite struct {
+	suite.Suite
+	policy Policy
+}
+
+func TestSuiteUsername(t *testing.T) {
+	suite.Run(t, new(UsernameSuite))
+}
+
+func (s *UsernameSuite) SetupSuite() {
+	s.policy = DefaultPolicy()
+}
+
+func (s *UsernameSuite) TearDownSuite() {}
+
+// Check
+func (s *UsernameSuite) TestCheck() {
+	testCase := []struct {
+		name        string
+		username    string
+		expectName  string
+		expectError error
+	}{
+		{
+			name:       "check-normal",
+			username:   "nobita.nobi_99",
+			expectName: "nobita.nobi_99",
+		},
+		{
+			name:       "check-normalized",
+			username:   "  Nobita ",
+			expectName: "nobita",
+		},
+		{
+			name:        "check-error_too_short",
+			username:    "ab",
+			expectError: apperr.Validation("error, username should be 3 to 32 characters long"),
+		},
+		{
+			name:        "check-error_too_long",
+			username:    "abcdefghijklmnopqrstuvwxyz0123456",
+			expectError: apperr.Validation("error, username should be 3 to 32 characters long"),
+		},
+		{
+			name:        "check-error_charset",
+			username:    "nobi ta",
+			expectError: apperr.Validation("error, username contains characters that are not allowed"),
+		},
+		{
+			name:        "check-error_leading_symbol",
+			username:    ".nobita",
+			expectError: apperr.Validation("error, username contains characters that are not allowed"),
+		},
+		{
+			name:        "check-error_reserved",
+			username:    "Admin",
+			expectError: apperr.Validation("error, username is reserved"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			name, err := s.policy.Check(v.username)
+			s.Equal(v.expectError, err)
+			s.Equal(v.expectName, name)
+		})
+	}
+}
+
+// Parse
+func (s *UsernameSuite) TestParse() {
+	p, err := Parse("5", "8", `^[a-z]+$`, " Bot, ,staff")
+	s.Require().NoError(err)
+	s.Equal(5, p.MinLength)
+	s.Equal(8, p.MaxLength)
+	s.Equal(map[string]bool{"bot": true, "staff": true}, p.Reserved)
+
+	_, err = p.Check("nobita1")
+	s.Equal(apperr.Validation("error, username contains characters that are not allowed"), err)
+	_, err = p.Check("nobi")
+	s.Equal(apperr.Validation("error, username should be 5 to 8 characters long"), err)
+	_, err = p.Check("Staff")
+	s.Equal(apperr.Validation("error, username is reserved"), err)
+}
+func (s *UsernameSuite) TestParseError() {
+	for _, v := range [][3]string{
+		{"x", "", ""},
+		{"", "x", ""},
+		{"10", "5", ""},
+		{"0", "", ""},
+		{"", "", "("},
+	} {
+		_, err := Parse(v[0], v[1], v[2], "")
+		s.Error(err, v)
+	}
+}
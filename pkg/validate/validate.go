@@ -0,0 +1,174 @@
+// Package validate checks request structs against the rules in their
+// `validate` tags and reports every invalid field at once.
+//
+// Rules are separated by commas:
+//
+//	required    the field should not be empty
+//	min=N       strings have at least N characters, numbers are at least N
+//	max=N       strings have at most N characters, numbers are at most N
+//	maxbytes=N  strings are at most N bytes long
+//	oneof=a b   the field is one of the listed values
+//	slug        strings can be used as a custom random id
+//	duration    strings are a positive Go duration, e.g. "24h"
+//	future      times, or pointers to them, are after now
+//
+// Only required applies to empty fields. Fields are named after their json
+// tag, or their form tag when they have none.
+package validate
+
+import (
+	"fmt"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/randomid"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator is the echo.Validator of the API.
+type Validator struct{}
+
+func (Validator) Validate(i interface{}) error {
+	return Struct(i)
+}
+
+// Struct checks v, a struct or a pointer to one. Invalid fields are
+// reported in an apperr.Invalid error.
+func Struct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []apperr.FieldError
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name := fieldName(rt.Field(i))
+		message := check(name, rv.Field(i), tag)
+		if message != "" {
+			fields = append(fields, apperr.FieldError{Field: name, Message: message})
+		}
+	}
+	if len(fields) > 0 {
+		return apperr.Invalid(fields)
+	}
+	return nil
+}
+
+// check returns the message of the first rule of tag that value breaks.
+func check(name string, value reflect.Value, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		if key == "required" {
+			if value.IsZero() {
+				return fmt.Sprintf("error, %s should not be empty", name)
+			}
+			continue
+		}
+		if value.IsZero() {
+			continue
+		}
+
+		switch key {
+		case "min":
+			n := number(rule, arg)
+			if length(value) < n {
+				return fmt.Sprintf("error, %s should be at least %d%s", name, n, unit(value))
+			}
+		case "max":
+			n := number(rule, arg)
+			if length(value) > n {
+				return fmt.Sprintf("error, %s should be at most %d%s", name, n, unit(value))
+			}
+		case "maxbytes":
+			n := number(rule, arg)
+			if len(value.String()) > n {
+				return fmt.Sprintf("error, %s should be at most %d bytes", name, n)
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !contains(allowed, fmt.Sprint(value.Interface())) {
+				return fmt.Sprintf("error, %s should be %s", name, list(allowed))
+			}
+		case "slug":
+			if !randomid.ValidSlug(value.String()) {
+				return fmt.Sprintf("error, %s should be 3 to 64 letters, digits, '-' or '_'", name)
+			}
+		case "duration":
+			d, err := time.ParseDuration(value.String())
+			if err != nil || d <= 0 {
+				return fmt.Sprintf("error, %s should be a positive duration", name)
+			}
+		case "future":
+			t, ok := reflect.Indirect(value).Interface().(time.Time)
+			if !ok {
+				panic(fmt.Sprintf("validate: rule %q needs a time", rule))
+			}
+			if !t.After(time.Now()) {
+				return fmt.Sprintf("error, %s should be in the future", name)
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", rule))
+		}
+	}
+	return ""
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return strings.ToLower(f.Name)
+}
+
+// length is the number of characters of a string or the value of a number.
+func length(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int())
+	}
+	panic(fmt.Sprintf("validate: no length for %s", value.Kind()))
+}
+
+func unit(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return " characters"
+	}
+	return ""
+}
+
+func number(rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic(fmt.Sprintf("validate: rule %q needs a number", rule))
+	}
+	return n
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// list joins values the way error messages do, "a, b or c".
+func list(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
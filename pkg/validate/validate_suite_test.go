@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"pocket-message/pkg/apperr"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidateSuite struct {
+	suite.Suite
+}
+
+func TestSuiteValidate(t *testing.T) {
+	suite.Run(t, new(ValidateSuite))
+}
+
+func (s *ValidateSuite) SetupSuite() {}
+
+func (s *ValidateSuite) TearDownSuite() {}
+
+type request struct {
+	Name   string `json:"name" validate:"required,min=2,max=5"`
+	Bio    string `form:"bio" validate:"maxbytes=8"`
+	Kind   string `json:"kind,omitempty" validate:"oneof=a b c"`
+	Count  int    `json:"count" validate:"max=3"`
+	Ignore string `json:"ignore"`
+
+	Slug string     `json:"slug" validate:"slug"`
+	Wait string     `json:"wait" validate:"duration"`
+	At   *time.Time `json:"at" validate:"future"`
+}
+
+// Struct
+func (s *ValidateSuite) TestStruct() {
+	later, earlier := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+	testCase := []struct {
+		name        string
+		value       interface{}
+		expectError error
+	}{
+		{
+			name:  "struct-normal",
+			value: request{Name: "udin", Bio: "halo", Kind: "b", Count: 3, Ignore: strings.Repeat("a", 100)},
+		},
+		{
+			name:  "struct-pointer",
+			value: &request{Name: "udin"},
+		},
+		{
+			name:  "struct-not_a_struct",
+			value: "udin",
+		},
+		{
+			name:  "struct-error_required",
+			value: request{},
+			expectError: apperr.Invalid([]apperr.FieldError{
+				{Field: "name", Message: "error, name should not be empty"},
+			}),
+		},
+		{
+			name:  "struct-error_every_field",
+			value: request{Name: "u", Bio: "héhéhé", Kind: "d", Count: 4},
+			expectError: apperr.Invalid([]apperr.FieldError{
+				{Field: "name", Message: "error, name should be at least 2 characters"},
+				{Field: "bio", Message: "error, bio should be at most 8 bytes"},
+				{Field: "kind", Message: "error, kind should be a, b or c"},
+				{Field: "count", Message: "error, count should be at most 3"},
+			}),
+		},
+		{
+			name:  "struct-custom_rules",
+			value: request{Name: "udin", Slug: "my-Birthday", Wait: "90m", At: &later},
+		},
+		{
+			name:  "struct-error_custom_rules",
+			value: request{Name: "udin", Slug: "my birthday", Wait: "-1h", At: &earlier},
+			expectError: apperr.Invalid([]apperr.FieldError{
+				{Field: "slug", Message: "error, slug should be 3 to 64 letters, digits, '-' or '_'"},
+				{Field: "wait", Message: "error, wait should be a positive duration"},
+				{Field: "at", Message: "error, at should be in the future"},
+			}),
+		},
+		{
+			name:  "struct-max_counts_characters",
+			value: request{Name: "héhéh"},
+		},
+		{
+			name:  "struct-error_max",
+			value: request{Name: "udin12"},
+			expectError: apperr.Invalid([]apperr.FieldError{
+				{Field: "name", Message: "error, name should be at most 5 characters"},
+			}),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.Equal(v.expectError, Struct(v.value))
+		})
+	}
+}
+func (s *ValidateSuite) TestStructUnknownRule() {
+	s.Panics(func() {
+		_ = Struct(struct {
+			Name string `validate:"email"`
+		}{Name: "udin"})
+	})
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"pocket-message/models"
+	"pocket-message/pkg/keyring"
+)
+
+// encryptMessage replaces the title and content of pm with their ciphertext
+// under a fresh data key. Without a keyring pm is stored in plain text.
+func (db GormSql) encryptMessage(pm *models.PocketMessage) error {
+	if db.Keyring == nil {
+		pm.KeyID, pm.DataKey = "", ""
+		return nil
+	}
+
+	dk, err := db.Keyring.NewDataKey()
+	if err != nil {
+		return err
+	}
+	pm.Title, err = dk.Encrypt(pm.Title)
+	if err != nil {
+		return err
+	}
+	pm.Content, err = dk.Encrypt(pm.Content)
+	if err != nil {
+		return err
+	}
+
+	pm.KeyID, pm.DataKey = dk.KeyID, dk.Wrapped
+	return nil
+}
+
+// decryptFields decrypts fields in place. Rows without a key id were stored
+// before encryption was enabled and are returned as they are.
+func (db GormSql) decryptFields(keyID, dataKey string, fields ...*string) error {
+	if keyID == "" {
+		return nil
+	}
+	if db.Keyring == nil {
+		return keyring.ErrUnknownKey
+	}
+
+	dk, err := db.Keyring.OpenDataKey(keyID, dataKey)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		*f, err = dk.Decrypt(*f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReencryptPocketMessages brings every pocket message, including soft deleted
+// ones, and every revision under the active key: data keys wrapped by an
+// older key are re-wrapped and plain text rows are encrypted. It returns the
+// number of rows updated.
+func (db GormSql) ReencryptPocketMessages(batchSize int) (int64, error) {
+	if db.Keyring == nil {
+		return 0, keyring.ErrUnknownKey
+	}
+
+	updated, err := db.reencryptMessages(batchSize)
+	if err != nil {
+		return updated, err
+	}
+	n, err := db.reencryptRevisions(batchSize)
+	return updated + n, err
+}
+
+func (db GormSql) reencryptMessages(batchSize int) (int64, error) {
+	var updated int64
+	var lastID uint
+	for {
+		var msgs []models.PocketMessage
+		err := db.DB.Unscoped().
+			Where("id > ? AND (key_id IS NULL OR key_id <> ?)", lastID, db.Keyring.ActiveID()).
+			Order("id").Limit(batchSize).
+			Find(&msgs).Error
+		if err != nil {
+			return updated, err
+		}
+		if len(msgs) == 0 {
+			return updated, nil
+		}
+
+		for _, pm := range msgs {
+			lastID = pm.ID
+
+			err = db.reencrypt(&pm)
+			if err != nil {
+				return updated, err
+			}
+
+			err = db.DB.Unscoped().Model(&models.PocketMessage{}).Where("id = ?", pm.ID).
+				UpdateColumns(map[string]interface{}{
+					"title":    pm.Title,
+					"content":  pm.Content,
+					"key_id":   pm.KeyID,
+					"data_key": pm.DataKey,
+				}).Error
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
+
+func (db GormSql) reencryptRevisions(batchSize int) (int64, error) {
+	var updated int64
+	var lastID uint
+	for {
+		var revs []models.PocketMessageRevision
+		err := db.DB.
+			Where("id > ? AND (key_id IS NULL OR key_id <> ?)", lastID, db.Keyring.ActiveID()).
+			Order("id").Limit(batchSize).
+			Find(&revs).Error
+		if err != nil {
+			return updated, err
+		}
+		if len(revs) == 0 {
+			return updated, nil
+		}
+
+		for _, rev := range revs {
+			lastID = rev.ID
+
+			pm := models.PocketMessage{Title: rev.Title, Content: rev.Content, KeyID: rev.KeyID, DataKey: rev.DataKey}
+			err = db.reencrypt(&pm)
+			if err != nil {
+				return updated, err
+			}
+
+			err = db.DB.Model(&models.PocketMessageRevision{}).Where("id = ?", rev.ID).
+				UpdateColumns(map[string]interface{}{
+					"title":    pm.Title,
+					"content":  pm.Content,
+					"key_id":   pm.KeyID,
+					"data_key": pm.DataKey,
+				}).Error
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
+
+// reencrypt encrypts a plain text pm or re-wraps its data key under the
+// active key.
+func (db GormSql) reencrypt(pm *models.PocketMessage) error {
+	if pm.KeyID == "" {
+		return db.encryptMessage(pm)
+	}
+
+	dk, err := db.Keyring.Rewrap(pm.KeyID, pm.DataKey)
+	if err != nil {
+		return err
+	}
+	pm.KeyID, pm.DataKey = dk.KeyID, dk.Wrapped
+	return nil
+}
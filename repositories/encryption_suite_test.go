@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/keyring"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type EncryptedGormSuite struct {
+	suite.Suite
+	mock    sqlmock.Sqlmock
+	repo    Database
+	keyring *keyring.Keyring
+}
+
+func TestSuiteEncryptedGorm(t *testing.T) {
+	suite.Run(t, new(EncryptedGormSuite))
+}
+
+func (s *EncryptedGormSuite) SetupSuite() {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		s.Error(err)
+	}
+
+	gDB, err := gorm.Open(mysql.New(mysql.Config{
+		SkipInitializeWithVersion: true,
+		Conn:                      db,
+	}), &gorm.Config{})
+	if err != nil {
+		s.Error(err)
+	}
+
+	kr, err := keyring.Parse("k1:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keyring.KeySize)), "")
+	if err != nil {
+		s.Error(err)
+	}
+
+	s.repo = NewEncryptedGorm(gDB, kr)
+	s.mock = mock
+	s.keyring = kr
+}
+
+func (s *EncryptedGormSuite) TearDownSuite() {}
+
+// NotPlain matches any value except the given plain text.
+type NotPlain string
+
+func (n NotPlain) Match(v driver.Value) bool {
+	str, ok := v.(string)
+	return ok && str != "" && str != string(n)
+}
+
+// SaveNewPocketMessage
+func (s *EncryptedGormSuite) TestSaveNewPocketMessage() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`format`,`user_uuid`,`burn_after_read`,`encrypted`,`key_id`,`data_key`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", NotPlain("testJudul"), NotPlain("testContent"), "", "00000000-0000-0000-0000-000000000000", false, false, "k1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+		WithArgs(uuid.Nil, 1, NotPlain("testJudul"), NotPlain("testContent"), "", false, "k1", sqlmock.AnyArg(), AnyTime{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+
+	err := s.repo.SaveNewPocketMessage(models.PocketMessage{
+		UUID:     uuid.Nil,
+		Title:    "testJudul",
+		Content:  "testContent",
+		UserUUID: uuid.Nil,
+	})
+	s.NoError(err)
+}
+
+// GetPocketMessageByRandomID
+func (s *EncryptedGormSuite) TestGetPocketMessageByRandomID() {
+	dk, err := s.keyring.NewDataKey()
+	s.NoError(err)
+	title, err := dk.Encrypt("superman mencari jodoh")
+	s.NoError(err)
+	content, err := dk.Encrypt("tapi boong")
+	s.NoError(err)
+
+	testCase := []struct {
+		name       string
+		row        []driver.Value
+		expectBody dto.PocketMessageWithRandomID
+	}{
+		{
+			name: "get_pocket_message_by_random_id-encrypted",
+			row:  []driver.Value{title, content, "asdfghjkl", dk.KeyID, dk.Wrapped},
+			expectBody: dto.PocketMessageWithRandomID{
+				Title:    "superman mencari jodoh",
+				Content:  "tapi boong",
+				RandomID: "asdfghjkl",
+				KeyID:    dk.KeyID,
+				DataKey:  dk.Wrapped,
+			},
+		},
+		{
+			name: "get_pocket_message_by_random_id-legacy_plain_text",
+			row:  []driver.Value{"superman mencari jodoh", "tapi boong", "asdfghjkl", "", ""},
+			expectBody: dto.PocketMessageWithRandomID{
+				Title:    "superman mencari jodoh",
+				Content:  "tapi boong",
+				RandomID: "asdfghjkl",
+			},
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			expectRow := s.mock.NewRows([]string{"title", "content", "random_id", "key_id", "data_key"}).
+				AddRow(v.row...)
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_messages.UUID")).
+				WithArgs("asdfghjkl").
+				WillReturnRows(expectRow)
+
+			result, err := s.repo.GetPocketMessageByRandomID("asdfghjkl")
+			s.NoError(err)
+			s.Equal(v.expectBody, result)
+		})
+	}
+}
+
+// ReencryptPocketMessages
+func (s *EncryptedGormSuite) TestReencryptPocketMessages() {
+	expectRow := s.mock.NewRows([]string{"id", "uuid", "title", "content", "key_id", "data_key"}).
+		AddRow(7, "00000000-0000-0000-0000-000000000000", "waw", "super", "", "")
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE id > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY id LIMIT 10")).
+		WithArgs(0, "k1").
+		WillReturnRows(expectRow)
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `content`=?,`data_key`=?,`key_id`=?,`title`=? WHERE id = ?")).
+		WithArgs(NotPlain("super"), sqlmock.AnyArg(), "k1", NotPlain("waw"), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE id > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY id LIMIT 10")).
+		WithArgs(7, "k1").
+		WillReturnRows(s.mock.NewRows([]string{"id"}))
+
+	// Revisions are brought under the active key the same way.
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_message_revisions` WHERE id > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY id LIMIT 10")).
+		WithArgs(0, "k1").
+		WillReturnRows(s.mock.NewRows([]string{"id", "number", "title", "content", "key_id", "data_key"}).
+			AddRow(3, 1, "waw", "super", "", ""))
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_revisions` SET `content`=?,`data_key`=?,`key_id`=?,`title`=? WHERE id = ?")).
+		WithArgs(NotPlain("super"), sqlmock.AnyArg(), "k1", NotPlain("waw"), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_message_revisions` WHERE id > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY id LIMIT 10")).
+		WithArgs(3, "k1").
+		WillReturnRows(s.mock.NewRows([]string{"id"}))
+
+	n, err := s.repo.(*GormSql).ReencryptPocketMessages(10)
+	s.NoError(err)
+	s.Equal(int64(2), n)
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"errors"
+	"pocket-message/pkg/apperr"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// Error codes of a unique index violation in each supported database.
+const (
+	mysqlDuplicateEntry     = 1062
+	postgresUniqueViolation = "23505"
+)
+
+// translateError turns storage errors the API has to answer differently into
+// typed errors. The original error stays reachable through errors.Is.
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.Wrap(apperr.KindNotFound, "not_found", err)
+	}
+	if isDuplicate(err) {
+		return apperr.Wrap(apperr.KindConflict, "conflict", err)
+	}
+	return err
+}
+
+func isDuplicate(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlDuplicateEntry
+	}
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code == postgresUniqueViolation
+	}
+	var se sqlite3.Error
+	if errors.As(err, &se) {
+		return se.ExtendedCode == sqlite3.ErrConstraintUnique || se.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
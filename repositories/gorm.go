@@ -1,8 +1,12 @@
 package repositories
 
 import (
+	"fmt"
 	"pocket-message/dto"
 	"pocket-message/models"
+	"pocket-message/pkg/keyring"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -10,6 +14,8 @@ import (
 
 type GormSql struct {
 	DB *gorm.DB
+	// Keyring encrypts pocket message titles and contents at rest; nil stores them in plain text.
+	Keyring *keyring.Keyring
 }
 
 func NewGorm(db *gorm.DB) Database {
@@ -18,19 +24,34 @@ func NewGorm(db *gorm.DB) Database {
 	}
 }
 
+func NewEncryptedGorm(db *gorm.DB, kr *keyring.Keyring) Database {
+	return &GormSql{
+		DB:      db,
+		Keyring: kr,
+	}
+}
+
 // User
 func (db GormSql) SaveNewUser(user models.User) error {
 	result := db.DB.Create(&user)
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error)
 	}
 	return nil
 }
-func (db GormSql) Login(user models.User) (models.User, error) {
-	err := db.DB.Where("username = ? AND password = ?",
-		user.Username, user.Password).First(&user).Error
+func (db GormSql) GetUserByUsername(username string) (models.User, error) {
+	var user models.User
+	err := db.DB.Where("username = ?", username).First(&user).Error
 	if err != nil {
-		return models.User{}, err
+		return models.User{}, translateError(err)
+	}
+	return user, nil
+}
+func (db GormSql) GetUserByUUID(id uuid.UUID) (models.User, error) {
+	var user models.User
+	err := db.DB.Where("uuid = ?", id).First(&user).Error
+	if err != nil {
+		return models.User{}, translateError(err)
 	}
 	return user, nil
 }
@@ -38,12 +59,12 @@ func (db GormSql) UpdateUsername(user models.User) error {
 	err := db.DB.Model(&user).Where("uuid = ?", user.UUID).
 		Update("username", user.Username).Error
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	return nil
 }
 func (db GormSql) UpdatePassword(user models.User) error {
-	err := db.DB.Model(&user).Where("username = ?", user.Username).
+	err := db.DB.Model(&user).Where("uuid = ?", user.UUID).
 		Update("password", user.Password).Error
 	if err != nil {
 		return err
@@ -51,68 +72,703 @@ func (db GormSql) UpdatePassword(user models.User) error {
 
 	return nil
 }
+func (db GormSql) SavePasswordResetToken(token models.PasswordResetToken) error {
+	err := db.DB.Create(&token).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+func (db GormSql) UsePasswordResetToken(tokenHash string, now time.Time) (models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Claiming the token with a conditional update keeps it single-use under concurrency.
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("token_hash = ? AND used_at IS NULL AND expired_at > ?", tokenHash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		err := tx.Where("token_hash = ?", tokenHash).First(&token).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.PasswordResetToken{}).
+			Where("user_uuid = ? AND used_at IS NULL", token.UserUUID).
+			Update("used_at", now).Error
+	})
+	if err != nil {
+		return models.PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+// Session
+func (db GormSql) SaveSession(session models.Session) error {
+	err := db.DB.Create(&session).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db GormSql) GetSessionByUUID(id uuid.UUID) (models.Session, error) {
+	var session models.Session
+	err := db.DB.Where("uuid = ?", id).First(&session).Error
+	if err != nil {
+		return models.Session{}, translateError(err)
+	}
+	return session, nil
+}
+
+func (db GormSql) GetSessionByRefreshTokenHash(tokenHash string) (models.Session, error) {
+	var session models.Session
+	err := db.DB.Where("refresh_token_hash = ?", tokenHash).First(&session).Error
+	if err != nil {
+		return models.Session{}, translateError(err)
+	}
+	return session, nil
+}
+
+func (db GormSql) RotateSession(id uuid.UUID, next models.Session, now time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one of two concurrent refreshes with the same token may win.
+		result := tx.Model(&models.Session{}).
+			Where("uuid = ? AND revoked_at IS NULL AND expired_at > ?", id, now).
+			Updates(map[string]interface{}{"rotated_at": now, "revoked_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Create(&next).Error
+	})
+}
+
+func (db GormSql) RevokeSession(id uuid.UUID, now time.Time) error {
+	return db.DB.Model(&models.Session{}).
+		Where("uuid = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now).Error
+}
+
+func (db GormSql) RevokeSessionFamily(familyID uuid.UUID, now time.Time) error {
+	return db.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func (db GormSql) RevokeUserSessions(userUUID uuid.UUID, now time.Time) error {
+	return db.DB.Model(&models.Session{}).
+		Where("user_uuid = ? AND revoked_at IS NULL", userUUID).
+		Update("revoked_at", now).Error
+}
+
+// RevokeOtherUserSessions revokes every session of a user except keep.
+func (db GormSql) RevokeOtherUserSessions(userUUID, keep uuid.UUID, now time.Time) error {
+	return db.DB.Model(&models.Session{}).
+		Where("user_uuid = ? AND uuid <> ? AND revoked_at IS NULL", userUUID, keep).
+		Update("revoked_at", now).Error
+}
 
 // Pocket Message
 func (db GormSql) SaveNewPocketMessage(pm models.PocketMessage) error {
-	err := db.DB.Save(&pm).Error
+	err := db.encryptMessage(&pm)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(&pm).Error
+		if err != nil {
+			return err
+		}
+
+		rev := models.RevisionOf(pm, 1)
+		return tx.Create(&rev).Error
+	})
 }
 func (db GormSql) SaveNewRandomID(rid models.PocketMessageRandomID) error {
 	err := db.DB.Save(&rid).Error
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+// SaveNewPocketMessageWithRandomID saves a new message and its first random
+// id in one transaction, so neither is left behind when the other fails.
+func (db GormSql) SaveNewPocketMessageWithRandomID(pm models.PocketMessage, rid models.PocketMessageRandomID) error {
+	err := db.encryptMessage(&pm)
 	if err != nil {
 		return err
 	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&rid).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Save(&pm).Error
+		if err != nil {
+			return err
+		}
+
+		rev := models.RevisionOf(pm, 1)
+		return tx.Create(&rev).Error
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+func (db GormSql) GetRandomIDsByPocketMessageUUID(msgID uuid.UUID) ([]models.PocketMessageRandomID, error) {
+	var rids []models.PocketMessageRandomID
+	err := db.DB.Where("pocket_message_uuid = ?", msgID).Order("id").Find(&rids).Error
+	if err != nil {
+		return nil, err
+	}
+	return rids, nil
+}
+func (db GormSql) DeleteRandomID(msgID uuid.UUID, rid string) error {
+	result := db.DB.Unscoped().Where("pocket_message_uuid = ? AND random_id = ?", msgID, rid).Delete(&models.PocketMessageRandomID{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
 	return nil
 }
 func (db GormSql) GetPocketMessageByRandomID(rid string) (dto.PocketMessageWithRandomID, error) {
 	var result dto.PocketMessageWithRandomID
 	err := db.DB.Model(&models.PocketMessage{}).
-		Select("pocket_messages.UUID, pocket_messages.title, pocket_messages.content,pocket_message_random_id.visit, pocket_message_random_id.random_id").
+		Select("pocket_messages.UUID, pocket_messages.title, pocket_messages.content, pocket_messages.format, pocket_message_random_id.visit, pocket_message_random_id.random_id, pocket_messages.burn_after_read, pocket_messages.encrypted, pocket_message_random_id.max_visit, pocket_message_random_id.expired_at, pocket_message_random_id.passphrase_hash, pocket_message_random_id.failed_attempts, pocket_message_random_id.locked_until, pocket_messages.key_id, pocket_messages.data_key").
 		Joins("LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid").
 		Where("pocket_message_random_id.random_id = ?", rid).
 		First(&result).Error
+	if err != nil {
+		return dto.PocketMessageWithRandomID{}, translateError(err)
+	}
+
+	err = db.decryptFields(result.KeyID, result.DataKey, &result.Title, &result.Content)
 	if err != nil {
 		return dto.PocketMessageWithRandomID{}, err
 	}
 	return result, nil
 }
+
+// UpdateVisitCount counts a visit of the random id. The visit limit is
+// checked by the same statement, so concurrent readers can not exceed it;
+// a random id that reached its limit is reported as not found.
 func (db GormSql) UpdateVisitCount(rid dto.PocketMessageWithRandomID) error {
-	err := db.DB.Model(&models.PocketMessageRandomID{}).Where("pocket_message_uuid = ?", rid.UUID).Update("visit", rid.Visit+1).Error
+	result := db.DB.Model(&models.PocketMessageRandomID{}).
+		Where("random_id = ? AND (max_visit = 0 OR visit < max_visit)", rid.RandomID).
+		Update("visit", gorm.Expr("visit + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+func (db GormSql) SaveMessageView(view models.MessageView) error {
+	return db.DB.Create(&view).Error
+}
+func (db GormSql) GetMessageViews(msgID uuid.UUID, from, to time.Time) ([]models.MessageView, error) {
+	var views []models.MessageView
+	err := db.DB.Select("random_id", "viewed_at", "ip_hash").
+		Where("pocket_message_uuid = ? AND viewed_at >= ? AND viewed_at < ?", msgID, from, to).
+		Order("viewed_at").
+		Find(&views).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return views, nil
+}
+func (db GormSql) RecordFailedPassphraseAttempt(rid string, maxAttempts int, lockedUntil time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PocketMessageRandomID{}).Where("random_id = ?", rid).
+			Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+		if err != nil {
+			return err
+		}
 
+		return tx.Model(&models.PocketMessageRandomID{}).Where("random_id = ? AND failed_attempts >= ?", rid, maxAttempts).
+			Updates(map[string]interface{}{
+				"failed_attempts": 0,
+				"locked_until":    lockedUntil,
+			}).Error
+	})
+}
+func (db GormSql) ResetPassphraseAttempts(rid string) error {
+	err := db.DB.Model(&models.PocketMessageRandomID{}).Where("random_id = ?", rid).
+		Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"locked_until":    nil,
+		}).Error
+	if err != nil {
+		return err
+	}
 	return nil
 }
+func (db GormSql) BurnPocketMessage(rid dto.PocketMessageWithRandomID) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the reader that actually removes the random id may see the content,
+		// so concurrent readers of the same link can never both succeed.
+		result := tx.Unscoped().Where("random_id = ?", rid.RandomID).Delete(&models.PocketMessageRandomID{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return translateError(gorm.ErrRecordNotFound)
+		}
+
+		err := deleteMessageRows(tx, []uuid.UUID{rid.UUID})
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.PocketMessage{}, "uuid = ?", rid.UUID).Error
+	})
+}
+
+// DeleteExpiredPocketMessages deletes expired share links and the messages
+// whose last link expired with them. It returns the number of deleted messages.
+// Messages in the trash are left to the trash purge, so they can be restored
+// until their retention ends.
+func (db GormSql) DeleteExpiredPocketMessages(now time.Time) (int64, error) {
+	const expired = "(expired_at <= ? OR (max_visit > 0 AND visit >= max_visit))"
+
+	var deleted int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		live := tx.Model(&models.PocketMessage{}).Select("uuid")
+		var msgIDs []uuid.UUID
+		err := tx.Model(&models.PocketMessageRandomID{}).
+			Where(expired+" AND pocket_message_uuid IN (?)", now, live).
+			Pluck("pocket_message_uuid", &msgIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(msgIDs) == 0 {
+			return nil
+		}
+
+		err = tx.Unscoped().Where(expired+" AND pocket_message_uuid IN ?", now, msgIDs).Delete(&models.PocketMessageRandomID{}).Error
+		if err != nil {
+			return err
+		}
+
+		// Messages still shared through another link stay.
+		remaining := tx.Model(&models.PocketMessageRandomID{}).
+			Select("pocket_message_uuid").
+			Where("pocket_message_uuid IN ?", msgIDs)
+		var unshared []uuid.UUID
+		err = tx.Model(&models.PocketMessage{}).
+			Where("uuid IN ? AND uuid NOT IN (?)", msgIDs, remaining).
+			Pluck("uuid", &unshared).Error
+		if err != nil {
+			return err
+		}
+		if len(unshared) == 0 {
+			return nil
+		}
+
+		err = deleteMessageRows(tx, unshared)
+		if err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("uuid IN ?", unshared).Delete(&models.PocketMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+func (db GormSql) GetPocketMessageByUUID(msgID uuid.UUID) (models.PocketMessage, error) {
+	var pm models.PocketMessage
+	err := db.DB.Where("uuid = ?", msgID).First(&pm).Error
+	if err != nil {
+		return models.PocketMessage{}, translateError(err)
+	}
+
+	err = db.decryptFields(pm.KeyID, pm.DataKey, &pm.Title, &pm.Content)
+	if err != nil {
+		return models.PocketMessage{}, err
+	}
+	return pm, nil
+}
+
+// GetPocketMessagesAfter returns up to limit pocket messages with an id
+// above afterID, in id order.
+func (db GormSql) GetPocketMessagesAfter(afterID uint, limit int) ([]models.PocketMessage, error) {
+	var pms []models.PocketMessage
+	err := db.DB.Where("id > ?", afterID).Order("id").Limit(limit).Find(&pms).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pms {
+		err = db.decryptFields(pms[i].KeyID, pms[i].DataKey, &pms[i].Title, &pms[i].Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pms, nil
+}
+
+// UpdatePocketMessage saves newMsg as the next revision of the message.
+// Two concurrent updates can not both take the same revision number, so
+// the later one fails with a conflict.
 func (db GormSql) UpdatePocketMessage(newMsg models.PocketMessage) error {
-	err := db.DB.Model(&newMsg).Where("uuid = ?", newMsg.UUID).Updates(models.PocketMessage{
-		Title:   newMsg.Title,
-		Content: newMsg.Content,
-	}).Error
+	err := db.encryptMessage(&newMsg)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var last int
+		err := tx.Model(&models.PocketMessageRevision{}).
+			Select("COALESCE(MAX(number), 0)").
+			Where("pocket_message_uuid = ?", newMsg.UUID).
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		if last == 0 {
+			// The message was saved before revisions were recorded, so its
+			// current content becomes the first revision.
+			var current models.PocketMessage
+			err = tx.Where("uuid = ?", newMsg.UUID).First(&current).Error
+			if err != nil {
+				return translateError(err)
+			}
+			first := models.RevisionOf(current, 1)
+			err = tx.Create(&first).Error
+			if err != nil {
+				return translateError(err)
+			}
+			last = 1
+		}
+
+		result := tx.Model(&newMsg).Where("uuid = ?", newMsg.UUID).Select("title", "content", "format", "encrypted", "key_id", "data_key").Updates(models.PocketMessage{
+			Title:     newMsg.Title,
+			Content:   newMsg.Content,
+			Format:    newMsg.Format,
+			Encrypted: newMsg.Encrypted,
+			KeyID:     newMsg.KeyID,
+			DataKey:   newMsg.DataKey,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		// A trashed or missing message gets no revision.
+		if result.RowsAffected == 0 {
+			return translateError(gorm.ErrRecordNotFound)
+		}
+
+		rev := models.RevisionOf(newMsg, last+1)
+		return translateError(tx.Create(&rev).Error)
+	})
 }
+
+// DeletePocketMessage moves a pocket message to the trash of its owner. Its
+// share links and revisions are kept for a restore, but the links stop
+// resolving while the message is trashed.
 func (db GormSql) DeletePocketMessage(msgID uuid.UUID) error {
-	err := db.DB.Unscoped().Delete(&models.PocketMessage{}, "uuid = ?", msgID).Error
+	return db.DB.Where("uuid = ?", msgID).Delete(&models.PocketMessage{}).Error
+}
+
+// GetTrashedPocketMessages lists the pocket messages of a user that are in
+// the trash, most recently deleted first.
+func (db GormSql) GetTrashedPocketMessages(userUUID uuid.UUID) ([]models.PocketMessage, error) {
+	var pms []models.PocketMessage
+	err := db.DB.Unscoped().
+		Where("user_uuid = ? AND deleted_at IS NOT NULL", userUUID).
+		Order("deleted_at DESC").
+		Find(&pms).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pms {
+		err = db.decryptFields(pms[i].KeyID, pms[i].DataKey, &pms[i].Title, &pms[i].Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pms, nil
+}
+func (db GormSql) GetTrashedPocketMessage(msgID uuid.UUID) (models.PocketMessage, error) {
+	var pm models.PocketMessage
+	err := db.DB.Unscoped().Where("uuid = ? AND deleted_at IS NOT NULL", msgID).First(&pm).Error
+	if err != nil {
+		return models.PocketMessage{}, translateError(err)
+	}
+
+	err = db.decryptFields(pm.KeyID, pm.DataKey, &pm.Title, &pm.Content)
+	if err != nil {
+		return models.PocketMessage{}, err
+	}
+	return pm, nil
+}
+func (db GormSql) RestorePocketMessage(msgID uuid.UUID) error {
+	result := db.DB.Unscoped().Model(&models.PocketMessage{}).
+		Where("uuid = ? AND deleted_at IS NOT NULL", msgID).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// PurgePocketMessage permanently deletes a trashed pocket message together
+// with its share links, revisions and views.
+func (db GormSql) PurgePocketMessage(msgID uuid.UUID) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("uuid = ? AND deleted_at IS NOT NULL", msgID).Delete(&models.PocketMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return translateError(gorm.ErrRecordNotFound)
+		}
+		return deleteMessageRows(tx, []uuid.UUID{msgID})
+	})
+}
+
+// PurgeTrashedPocketMessages permanently deletes the pocket messages trashed
+// at or before the given time. It returns the number of deleted messages.
+func (db GormSql) PurgeTrashedPocketMessages(before time.Time) (int64, error) {
+	var deleted int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var msgIDs []uuid.UUID
+		err := tx.Unscoped().Model(&models.PocketMessage{}).
+			Where("deleted_at <= ?", before).
+			Pluck("uuid", &msgIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(msgIDs) == 0 {
+			return nil
+		}
+
+		err = deleteMessageRows(tx, msgIDs)
+		if err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("uuid IN ?", msgIDs).Delete(&models.PocketMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// deleteMessageRows deletes what refers to the given pocket messages. Every
+// permanent delete of a message goes through it, so nothing is left behind.
+func deleteMessageRows(tx *gorm.DB, msgIDs []uuid.UUID) error {
+	err := tx.Unscoped().Where("pocket_message_uuid IN ?", msgIDs).Delete(&models.PocketMessageRandomID{}).Error
 	if err != nil {
 		return err
 	}
+	err = tx.Where("pocket_message_uuid IN ?", msgIDs).Delete(&models.PocketMessageRevision{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("pocket_message_uuid IN ?", msgIDs).Delete(&models.MessageView{}).Error
+	if err != nil {
+		return err
+	}
+	// MigrateDB only creates the search table on MySQL.
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Where("pocket_message_uuid IN ?", msgIDs).Delete(&models.MessageSearch{}).Error
+	}
 	return nil
 }
-func (db GormSql) GetPocketMessageByUserUUID(uuid uuid.UUID) ([]dto.OwnedMessage, error) {
-	var result []dto.OwnedMessage
-	err := db.DB.Model(&models.PocketMessage{}).
-		Select("pocket_message_random_id.random_id, pocket_messages.title, pocket_messages.content, pocket_message_random_id.visit").
-		Joins("LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid").
-		Where("pocket_messages.user_uuid = ?", uuid).
-		Scan(&result).Error
+func (db GormSql) GetPocketMessageRevisions(msgID uuid.UUID) ([]models.PocketMessageRevision, error) {
+	var revs []models.PocketMessageRevision
+	err := db.DB.Where("pocket_message_uuid = ?", msgID).Order("number").Find(&revs).Error
 	if err != nil {
 		return nil, err
 	}
+
+	for i := range revs {
+		err = db.decryptFields(revs[i].KeyID, revs[i].DataKey, &revs[i].Title, &revs[i].Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return revs, nil
+}
+func (db GormSql) GetPocketMessageRevision(msgID uuid.UUID, n int) (models.PocketMessageRevision, error) {
+	var rev models.PocketMessageRevision
+	err := db.DB.Where("pocket_message_uuid = ? AND number = ?", msgID, n).First(&rev).Error
+	if err != nil {
+		return models.PocketMessageRevision{}, translateError(err)
+	}
+
+	err = db.decryptFields(rev.KeyID, rev.DataKey, &rev.Title, &rev.Content)
+	if err != nil {
+		return models.PocketMessageRevision{}, err
+	}
+	return rev, nil
+}
+
+// GetPocketMessageByUserUUID returns up to q.Limit+1 messages of the user,
+// so callers can tell whether there is a next page. Titles may be encrypted,
+// so the title filter is applied after decryption, fetching further batches
+// until the page is full.
+func (db GormSql) GetPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) ([]dto.OwnedMessage, error) {
+	now := time.Now()
+	var result []dto.OwnedMessage
+	for {
+		var batch []dto.OwnedMessage
+		err := db.ownedPage(uuid, q, now).Scan(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range batch {
+			err = db.decryptFields(batch[i].KeyID, batch[i].DataKey, &batch[i].Title, &batch[i].Content)
+			if err != nil {
+				return nil, err
+			}
+			if titleContains(batch[i].Title, q.Title) {
+				result = append(result, batch[i])
+			}
+		}
+
+		if len(result) > q.Limit || len(batch) <= q.Limit {
+			break
+		}
+		last := batch[len(batch)-1].CursorOf(q.Sort, q.Desc)
+		q.After = &last
+	}
+
+	if len(result) > q.Limit+1 {
+		result = result[:q.Limit+1]
+	}
 	return result, nil
 }
+
+// CountPocketMessageByUserUUID counts the messages of the user matching the
+// filters of q. A title filter decrypts every title of the user.
+func (db GormSql) CountPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) (int64, error) {
+	now := time.Now()
+	if q.Title == "" {
+		var total int64
+		err := db.DB.Table("(?) AS owned", db.ownedMessages(uuid, q, now)).Count(&total).Error
+		if err != nil {
+			return 0, err
+		}
+		return total, nil
+	}
+
+	var rows []dto.OwnedMessage
+	err := db.ownedMessages(uuid, q, now).Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for i := range rows {
+		err = db.decryptFields(rows[i].KeyID, rows[i].DataKey, &rows[i].Title)
+		if err != nil {
+			return 0, err
+		}
+		if titleContains(rows[i].Title, q.Title) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// visitSum is the total visits of a message over its share links.
+const visitSum = "COALESCE(SUM(pocket_message_random_id.visit), 0)"
+
+// ownedMessages selects the messages of a user, one row per message, with
+// every filter of q except the title.
+func (db GormSql) ownedMessages(userUUID uuid.UUID, q dto.OwnedMessageQuery, now time.Time) *gorm.DB {
+	tx := db.DB.Model(&models.PocketMessage{}).
+		Select("pocket_messages.id, pocket_messages.uuid, pocket_messages.title, pocket_messages.content, pocket_messages.encrypted, pocket_messages.key_id, pocket_messages.data_key, pocket_messages.created_at, pocket_messages.updated_at, MIN(pocket_message_random_id.random_id) AS random_id, "+visitSum+" AS visit, COUNT(pocket_message_random_id.id) AS links").
+		Joins("LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid AND pocket_message_random_id.deleted_at IS NULL").
+		Where("pocket_messages.user_uuid = ?", userUUID).
+		// PostgreSQL only lets the other columns be selected ungrouped when
+		// the whole primary key is grouped.
+		Group("pocket_messages.id, pocket_messages.uuid")
+
+	if q.CreatedFrom != nil {
+		tx = tx.Where("pocket_messages.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		tx = tx.Where("pocket_messages.created_at < ?", *q.CreatedTo)
+	}
+	// open counts the links that can be opened, links all of them.
+	const open = "SUM(CASE WHEN (pocket_message_random_id.expired_at IS NULL OR pocket_message_random_id.expired_at > ?) AND (pocket_message_random_id.max_visit = 0 OR pocket_message_random_id.visit < pocket_message_random_id.max_visit) THEN 1 ELSE 0 END)"
+	const links = "COUNT(pocket_message_random_id.id)"
+	switch q.Status {
+	case dto.StatusActive:
+		tx = tx.Having(open+" > 0", now)
+	case dto.StatusExpired:
+		tx = tx.Having(links+" > 0 AND "+open+" = 0", now)
+	case dto.StatusRevoked:
+		tx = tx.Having(links + " = 0")
+	}
+	return tx
+}
+
+// ownedPage adds the sort order, cursor and limit of q to ownedMessages.
+func (db GormSql) ownedPage(userUUID uuid.UUID, q dto.OwnedMessageQuery, now time.Time) *gorm.DB {
+	tx := db.ownedMessages(userUUID, q, now)
+
+	column := "pocket_messages.created_at"
+	switch q.Sort {
+	case dto.SortUpdated:
+		column = "pocket_messages.updated_at"
+	case dto.SortVisits:
+		column = visitSum
+	}
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if q.After != nil {
+		var value interface{} = q.After.Time
+		if q.Sort == dto.SortVisits {
+			value = q.After.Visit
+		}
+		after := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND pocket_messages.id %[2]s ?))", column, cmp)
+		if q.Sort == dto.SortVisits {
+			tx = tx.Having(after, value, value, q.After.ID)
+		} else {
+			tx = tx.Where(after, value, value, q.After.ID)
+		}
+	}
+
+	return tx.Order(column + " " + dir).Order("pocket_messages.id " + dir).Limit(q.Limit + 1)
+}
+
+func titleContains(title, substr string) bool {
+	return strings.Contains(strings.ToLower(title), strings.ToLower(substr))
+}
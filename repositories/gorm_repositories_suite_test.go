@@ -5,11 +5,14 @@ import (
 	"errors"
 	"pocket-message/dto"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/search"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/mysql"
@@ -111,9 +114,22 @@ func (s *GormSuite) TestSaveNewUserError() {
 		})
 	}
 }
+func (s *GormSuite) TestSaveNewUserErrorDuplicate() {
+	duplicate := &mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry 'aku' for key 'username'"}
 
-// Login
-func (s *GormSuite) TestLogin() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `users` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`username`,`password`,`pocket_message`) VALUES (?,?,?,?,?,?,(NULL))")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "aku", "akuGantenk").
+		WillReturnError(duplicate)
+	s.mock.ExpectRollback()
+
+	err := s.repo.SaveNewUser(models.User{Username: "aku", Password: "akuGantenk"})
+	s.ErrorIs(err, duplicate)
+	s.Equal(apperr.KindConflict, apperr.As(err).Kind)
+}
+
+// GetUserByUsername
+func (s *GormSuite) TestGetUserByUsername() {
 	testCase := []struct {
 		name        string
 		body        models.User
@@ -121,7 +137,7 @@ func (s *GormSuite) TestLogin() {
 		expectError error
 	}{
 		{
-			name: "login-normal",
+			name: "get_user_by_username-normal",
 			body: models.User{
 				Username: "userTest",
 				Password: "passwordTest",
@@ -139,17 +155,17 @@ func (s *GormSuite) TestLogin() {
 			expectRow := s.mock.NewRows([]string{"uuid", "username", "password"}).
 				AddRow("00000000-0000-0000-0000-000000000000", "userTest", "passwordTest")
 
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE (username = ? AND password = ?) AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1")).
-				WithArgs("userTest", "passwordTest").
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE username = ? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1")).
+				WithArgs("userTest").
 				WillReturnRows(expectRow)
 
-			result, err := s.repo.Login(v.body)
+			result, err := s.repo.GetUserByUsername(v.body.Username)
 			s.Equal(v.expectError, err)
 			s.Equal(v.expectBody, result)
 		})
 	}
 }
-func (s *GormSuite) TestLoginError() {
+func (s *GormSuite) TestGetUserByUsernameError() {
 	testCase := []struct {
 		name        string
 		body        models.User
@@ -157,7 +173,7 @@ func (s *GormSuite) TestLoginError() {
 		expectError error
 	}{
 		{
-			name: "login-error",
+			name: "get_user_by_username-error",
 			body: models.User{
 				Username: "userTest",
 				Password: "passwordTest",
@@ -168,11 +184,11 @@ func (s *GormSuite) TestLoginError() {
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE (username = ? AND password = ?) AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1")).
-				WithArgs("userTest", "passwordTest").
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE username = ? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1")).
+				WithArgs("userTest").
 				WillReturnError(errors.New("record not found"))
 
-			result, err := s.repo.Login(v.body)
+			result, err := s.repo.GetUserByUsername(v.body.Username)
 			s.Equal(v.expectError, err)
 			s.Equal(v.expectBody, result)
 		})
@@ -258,8 +274,8 @@ func (s *GormSuite) TestUpdatePassword() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `password`=?,`updated_at`=? WHERE username = ? AND `users`.`deleted_at` IS NULL")).
-				WithArgs("passwordTest79", AnyTime{}, "userTest69").
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `password`=?,`updated_at`=? WHERE uuid = ? AND `users`.`deleted_at` IS NULL")).
+				WithArgs("passwordTest79", AnyTime{}, uuid.Nil).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			s.mock.ExpectCommit()
 
@@ -286,8 +302,8 @@ func (s *GormSuite) TestUpdatePasswordError() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `password`=?,`updated_at`=? WHERE username = ? AND `users`.`deleted_at` IS NULL")).
-				WithArgs("passwordTest79", AnyTime{}, "userTest69").
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `password`=?,`updated_at`=? WHERE uuid = ? AND `users`.`deleted_at` IS NULL")).
+				WithArgs("passwordTest79", AnyTime{}, uuid.Nil).
 				WillReturnError(errors.New("record not found"))
 			s.mock.ExpectRollback()
 
@@ -297,7 +313,158 @@ func (s *GormSuite) TestUpdatePasswordError() {
 	}
 }
 
+// GetUserByUUID
+func (s *GormSuite) TestGetUserByUUID() {
+	expectRow := s.mock.NewRows([]string{"uuid", "username", "password"}).
+		AddRow("00000000-0000-0000-0000-000000000000", "userTest", "passwordTest")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE uuid = ? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(expectRow)
+
+	result, err := s.repo.GetUserByUUID(uuid.Nil)
+	s.NoError(err)
+	s.Equal(models.User{UUID: uuid.Nil, Username: "userTest", Password: "passwordTest"}, result)
+}
+
+// SavePasswordResetToken
+func (s *GormSuite) TestSavePasswordResetToken() {
+	expiredAt := time.Date(2022, time.November, 1, 0, 30, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `password_reset_tokens` (`created_at`,`updated_at`,`deleted_at`,`token_hash`,`user_uuid`,`expired_at`,`used_at`) VALUES (?,?,?,?,?,?,?)")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, "abc", "00000000-0000-0000-0000-000000000000", expiredAt, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+
+	err := s.repo.SavePasswordResetToken(models.PasswordResetToken{
+		TokenHash: "abc",
+		UserUUID:  uuid.Nil,
+		ExpiredAt: expiredAt,
+	})
+	s.NoError(err)
+}
+
+// UsePasswordResetToken
+func (s *GormSuite) TestUsePasswordResetToken() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	expectRow := s.mock.NewRows([]string{"id", "token_hash", "user_uuid"}).
+		AddRow(1, "abc", "00000000-0000-0000-0000-000000000000")
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `password_reset_tokens` SET `used_at`=?,`updated_at`=? WHERE (token_hash = ? AND used_at IS NULL AND expired_at > ?) AND `password_reset_tokens`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, "abc", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `password_reset_tokens` WHERE token_hash = ? AND `password_reset_tokens`.`deleted_at` IS NULL ORDER BY `password_reset_tokens`.`id` LIMIT 1")).
+		WithArgs("abc").
+		WillReturnRows(expectRow)
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `password_reset_tokens` SET `used_at`=?,`updated_at`=? WHERE (user_uuid = ? AND used_at IS NULL) AND `password_reset_tokens`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+
+	result, err := s.repo.UsePasswordResetToken("abc", now)
+	s.NoError(err)
+	s.Equal(uuid.Nil, result.UserUUID)
+	s.Equal("abc", result.TokenHash)
+}
+func (s *GormSuite) TestUsePasswordResetTokenErrorUsed() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `password_reset_tokens` SET `used_at`=?,`updated_at`=? WHERE (token_hash = ? AND used_at IS NULL AND expired_at > ?) AND `password_reset_tokens`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, "abc", now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectRollback()
+
+	_, err := s.repo.UsePasswordResetToken("abc", now)
+	s.Equal(gorm.ErrRecordNotFound, err)
+}
+
 // NewPocketMessage
+func (s *GormSuite) TestGetSessionByRefreshTokenHash() {
+	expectRow := s.mock.NewRows([]string{"id", "uuid", "refresh_token_hash"}).
+		AddRow(1, "00000000-0000-0000-0000-000000000001", "abc")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `sessions` WHERE refresh_token_hash = ? AND `sessions`.`deleted_at` IS NULL ORDER BY `sessions`.`id` LIMIT 1")).
+		WithArgs("abc").
+		WillReturnRows(expectRow)
+
+	result, err := s.repo.GetSessionByRefreshTokenHash("abc")
+	s.NoError(err)
+	s.Equal(uuid.MustParse("00000000-0000-0000-0000-000000000001"), result.UUID)
+}
+func (s *GormSuite) TestRotateSession() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	next := models.Session{
+		UUID:             uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+		UserUUID:         uuid.Nil,
+		FamilyID:         uuid.Nil,
+		RefreshTokenHash: "def",
+		ExpiredAt:        now.Add(time.Hour),
+	}
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `sessions` SET `revoked_at`=?,`rotated_at`=?,`updated_at`=? WHERE (uuid = ? AND revoked_at IS NULL AND expired_at > ?) AND `sessions`.`deleted_at` IS NULL")).
+		WithArgs(now, now, AnyTime{}, uuid.MustParse("00000000-0000-0000-0000-000000000001"), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `sessions` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`user_uuid`,`family_id`,`refresh_token_hash`,`expired_at`,`rotated_at`,`revoked_at`) VALUES (?,?,?,?,?,?,?,?,?,?)")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, next.UUID, uuid.Nil, uuid.Nil, "def", next.ExpiredAt, nil, nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	s.mock.ExpectCommit()
+
+	err := s.repo.RotateSession(uuid.MustParse("00000000-0000-0000-0000-000000000001"), next, now)
+	s.NoError(err)
+}
+func (s *GormSuite) TestRotateSessionErrorAlreadyRotated() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `sessions` SET `revoked_at`=?,`rotated_at`=?,`updated_at`=? WHERE (uuid = ? AND revoked_at IS NULL AND expired_at > ?) AND `sessions`.`deleted_at` IS NULL")).
+		WithArgs(now, now, AnyTime{}, uuid.Nil, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectRollback()
+
+	err := s.repo.RotateSession(uuid.Nil, models.Session{}, now)
+	s.Equal(gorm.ErrRecordNotFound, err)
+}
+func (s *GormSuite) TestRevokeSessionFamily() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `sessions` SET `revoked_at`=?,`updated_at`=? WHERE (family_id = ? AND revoked_at IS NULL) AND `sessions`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mock.ExpectCommit()
+
+	err := s.repo.RevokeSessionFamily(uuid.Nil, now)
+	s.NoError(err)
+}
+func (s *GormSuite) TestRevokeUserSessions() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `sessions` SET `revoked_at`=?,`updated_at`=? WHERE (user_uuid = ? AND revoked_at IS NULL) AND `sessions`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, uuid.Nil).
+		WillReturnError(errors.New("db error"))
+	s.mock.ExpectRollback()
+
+	err := s.repo.RevokeUserSessions(uuid.Nil, now)
+	s.Equal(errors.New("db error"), err)
+}
+func (s *GormSuite) TestRevokeOtherUserSessions() {
+	now := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	keep := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `sessions` SET `revoked_at`=?,`updated_at`=? WHERE (user_uuid = ? AND uuid <> ? AND revoked_at IS NULL) AND `sessions`.`deleted_at` IS NULL")).
+		WithArgs(now, AnyTime{}, uuid.Nil, keep).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectCommit()
+
+	err := s.repo.RevokeOtherUserSessions(uuid.Nil, keep, now)
+	s.NoError(err)
+}
 func (s *GormSuite) TestNewPocketMessage() {
 	testCase := []struct {
 		name        string
@@ -318,8 +485,11 @@ func (s *GormSuite) TestNewPocketMessage() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`user_uuid`) VALUES (?,?,?,?,?,?,?)")).
-				WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "testJudul", "testContent", "00000000-0000-0000-0000-000000000000").
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`format`,`user_uuid`,`burn_after_read`,`encrypted`,`key_id`,`data_key`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+				WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "testJudul", "testContent", "", "00000000-0000-0000-0000-000000000000", false, false, "", "").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+				WithArgs(uuid.Nil, 1, "testJudul", "testContent", "", false, "", "", AnyTime{}).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			s.mock.ExpectCommit()
 
@@ -348,8 +518,8 @@ func (s *GormSuite) TestNewPocketMessageError() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`user_uuid`) VALUES (?,?,?,?,?,?,?)")).
-				WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "testJudul", "testContent", "00000000-0000-0000-0000-000000000000").
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`format`,`user_uuid`,`burn_after_read`,`encrypted`,`key_id`,`data_key`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+				WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "testJudul", "testContent", "", "00000000-0000-0000-0000-000000000000", false, false, "", "").
 				WillReturnError(errors.New("database error"))
 			s.mock.ExpectRollback()
 
@@ -379,8 +549,8 @@ func (s *GormSuite) TestSaveNewRandomID() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_random_id` (`created_at`,`updated_at`,`deleted_at`,`random_id`,`visit`,`pocket_message_uuid`) VALUES (?,?,?,?,?,?)")).
-				WithArgs(AnyTime{}, AnyTime{}, nil, "asdfghjkl", 0, "00000000-0000-0000-0000-000000000000").
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_random_id` (`created_at`,`updated_at`,`deleted_at`,`random_id`,`label`,`visit`,`max_visit`,`expired_at`,`pocket_message_uuid`,`passphrase_hash`,`failed_attempts`,`locked_until`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+				WithArgs(AnyTime{}, AnyTime{}, nil, "asdfghjkl", "", 0, 0, nil, "00000000-0000-0000-0000-000000000000", "", 0, nil).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			s.mock.ExpectCommit()
 
@@ -408,8 +578,8 @@ func (s *GormSuite) TestSaveNewRandomIDError() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_random_id` (`created_at`,`updated_at`,`deleted_at`,`random_id`,`visit`,`pocket_message_uuid`) VALUES (?,?,?,?,?,?)")).
-				WithArgs(AnyTime{}, AnyTime{}, nil, "asdfghjkl", 0, "00000000-0000-0000-0000-000000000000").
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_random_id` (`created_at`,`updated_at`,`deleted_at`,`random_id`,`label`,`visit`,`max_visit`,`expired_at`,`pocket_message_uuid`,`passphrase_hash`,`failed_attempts`,`locked_until`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+				WithArgs(AnyTime{}, AnyTime{}, nil, "asdfghjkl", "", 0, 0, nil, "00000000-0000-0000-0000-000000000000", "", 0, nil).
 				WillReturnError(errors.New("database error"))
 			s.mock.ExpectRollback()
 
@@ -419,6 +589,83 @@ func (s *GormSuite) TestSaveNewRandomIDError() {
 	}
 }
 
+// SaveNewPocketMessageWithRandomID
+func (s *GormSuite) TestSaveNewPocketMessageWithRandomIDError() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_random_id` (`created_at`,`updated_at`,`deleted_at`,`random_id`,`label`,`visit`,`max_visit`,`expired_at`,`pocket_message_uuid`,`passphrase_hash`,`failed_attempts`,`locked_until`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, "asdfghjkl", "", 0, 0, nil, "00000000-0000-0000-0000-000000000000", "", 0, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_messages` (`created_at`,`updated_at`,`deleted_at`,`uuid`,`title`,`content`,`format`,`user_uuid`,`burn_after_read`,`encrypted`,`key_id`,`data_key`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")).
+		WithArgs(AnyTime{}, AnyTime{}, nil, "00000000-0000-0000-0000-000000000000", "testJudul", "testContent", "", "00000000-0000-0000-0000-000000000000", false, false, "", "").
+		WillReturnError(errors.New("database error"))
+	s.mock.ExpectRollback()
+
+	err := s.repo.SaveNewPocketMessageWithRandomID(
+		models.PocketMessage{Title: "testJudul", Content: "testContent"},
+		models.PocketMessageRandomID{RandomID: "asdfghjkl"},
+	)
+	s.Equal(errors.New("database error"), err)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+
+// GetRandomIDsByPocketMessageUUID
+func (s *GormSuite) TestGetRandomIDsByPocketMessageUUID() {
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	rows := s.mock.NewRows([]string{"random_id", "label", "visit", "max_visit", "pocket_message_uuid"}).
+		AddRow("asdfghjkl", "family", 2, 0, msgID.String()).
+		AddRow("qwertyuio", "friends", 0, 5, msgID.String())
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_message_random_id` WHERE pocket_message_uuid = ? AND `pocket_message_random_id`.`deleted_at` IS NULL ORDER BY id")).
+		WithArgs(msgID).
+		WillReturnRows(rows)
+
+	rids, err := s.repo.GetRandomIDsByPocketMessageUUID(msgID)
+	s.NoError(err)
+	s.Len(rids, 2)
+	s.Equal("family", rids[0].Label)
+	s.Equal(5, rids[1].MaxVisit)
+}
+
+// DeleteRandomID
+func (s *GormSuite) TestDeleteRandomID() {
+	testCase := []struct {
+		name         string
+		randomID     string
+		rowsAffected int64
+		expectError  error
+	}{
+		{
+			name:         "delete_random_id-normal",
+			randomID:     "asdfghjkl",
+			rowsAffected: 1,
+			expectError:  nil,
+		},
+		{
+			name:         "delete_random_id-error_not_found",
+			randomID:     "missing",
+			rowsAffected: 0,
+			expectError:  gorm.ErrRecordNotFound,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_message_random_id` WHERE pocket_message_uuid = ? AND random_id = ?")).
+				WithArgs(uuid.Nil, v.randomID).
+				WillReturnResult(sqlmock.NewResult(0, v.rowsAffected))
+			s.mock.ExpectCommit()
+
+			err := s.repo.DeleteRandomID(uuid.Nil, v.randomID)
+			if v.expectError != nil {
+				s.ErrorIs(err, v.expectError)
+				s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+				return
+			}
+			s.NoError(err)
+		})
+	}
+}
+
 // GetPocketMessageByRandomID
 func (s *GormSuite) TestGetPocketMessageByRandomID() {
 	testCase := []struct {
@@ -432,7 +679,8 @@ func (s *GormSuite) TestGetPocketMessageByRandomID() {
 			randomID: "asdfghjkl",
 			expectBody: dto.PocketMessageWithRandomID{
 				Title:    "superman mencari jodoh",
-				Content:  "tapi boong",
+				Content:  "tapi **boong**",
+				Format:   models.FormatMarkdown,
 				Visit:    0,
 				RandomID: "asdfghjkl",
 			},
@@ -441,10 +689,10 @@ func (s *GormSuite) TestGetPocketMessageByRandomID() {
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			expectRow := s.mock.NewRows([]string{"title", "content", "visit", "random_id"}).
-				AddRow("superman mencari jodoh", "tapi boong", 0, "asdfghjkl")
+			expectRow := s.mock.NewRows([]string{"title", "content", "format", "visit", "random_id"}).
+				AddRow("superman mencari jodoh", "tapi **boong**", "markdown", 0, "asdfghjkl")
 
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_messages.UUID, pocket_messages.title, pocket_messages.content,pocket_message_random_id.visit, pocket_message_random_id.random_id FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_message_random_id.random_id = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_messages.UUID, pocket_messages.title, pocket_messages.content, pocket_messages.format, pocket_message_random_id.visit, pocket_message_random_id.random_id, pocket_messages.burn_after_read, pocket_messages.encrypted, pocket_message_random_id.max_visit, pocket_message_random_id.expired_at, pocket_message_random_id.passphrase_hash, pocket_message_random_id.failed_attempts, pocket_message_random_id.locked_until, pocket_messages.key_id, pocket_messages.data_key FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_message_random_id.random_id = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
 				WithArgs("asdfghjkl").
 				WillReturnRows(expectRow)
 
@@ -470,7 +718,7 @@ func (s *GormSuite) TestGetPocketMessageByRandomIDError() {
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_messages.UUID, pocket_messages.title, pocket_messages.content,pocket_message_random_id.visit, pocket_message_random_id.random_id FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_message_random_id.random_id = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_messages.UUID, pocket_messages.title, pocket_messages.content, pocket_messages.format, pocket_message_random_id.visit, pocket_message_random_id.random_id, pocket_messages.burn_after_read, pocket_messages.encrypted, pocket_message_random_id.max_visit, pocket_message_random_id.expired_at, pocket_message_random_id.passphrase_hash, pocket_message_random_id.failed_attempts, pocket_message_random_id.locked_until, pocket_messages.key_id, pocket_messages.data_key FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_message_random_id.random_id = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
 				WithArgs("asdfghjkl").
 				WillReturnError(errors.New("record not found"))
 
@@ -491,8 +739,9 @@ func (s *GormSuite) TestUpdateVisitCount() {
 		{
 			name: "update_visit_count-normal",
 			body: dto.PocketMessageWithRandomID{
-				UUID:  uuid.Nil,
-				Visit: 0,
+				UUID:     uuid.Nil,
+				RandomID: "asdfghjkl",
+				Visit:    0,
 			},
 			expectError: nil,
 		},
@@ -500,8 +749,8 @@ func (s *GormSuite) TestUpdateVisitCount() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `visit`=?,`updated_at`=? WHERE pocket_message_uuid = ? AND `pocket_message_random_id`.`deleted_at` IS NULL")).
-				WithArgs(v.body.Visit+1, AnyTime{}, uuid.Nil).
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `visit`=visit + 1,`updated_at`=? WHERE (random_id = ? AND (max_visit = 0 OR visit < max_visit)) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "asdfghjkl").
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			s.mock.ExpectCommit()
 
@@ -519,8 +768,9 @@ func (s *GormSuite) TestUpdateVisitCountError() {
 		{
 			name: "update_username-error",
 			body: dto.PocketMessageWithRandomID{
-				UUID:  uuid.Nil,
-				Visit: 0,
+				UUID:     uuid.Nil,
+				RandomID: "asdfghjkl",
+				Visit:    0,
 			},
 			expectError: errors.New("record not found"),
 		},
@@ -528,8 +778,8 @@ func (s *GormSuite) TestUpdateVisitCountError() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `visit`=?,`updated_at`=? WHERE pocket_message_uuid = ? AND `pocket_message_random_id`.`deleted_at` IS NULL")).
-				WithArgs(v.body.Visit+1, AnyTime{}, uuid.Nil).
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `visit`=visit + 1,`updated_at`=? WHERE (random_id = ? AND (max_visit = 0 OR visit < max_visit)) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "asdfghjkl").
 				WillReturnError(errors.New("record not found"))
 			s.mock.ExpectRollback()
 
@@ -538,8 +788,291 @@ func (s *GormSuite) TestUpdateVisitCountError() {
 		})
 	}
 }
+func (s *GormSuite) TestUpdateVisitCountErrorLimitReached() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `visit`=visit + 1,`updated_at`=? WHERE (random_id = ? AND (max_visit = 0 OR visit < max_visit)) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+		WithArgs(AnyTime{}, "asdfghjkl").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+
+	err := s.repo.UpdateVisitCount(dto.PocketMessageWithRandomID{RandomID: "asdfghjkl", Visit: 4, MaxVisit: 5})
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+}
+
+// MessageViews
+func (s *GormSuite) TestSaveMessageView() {
+	viewedAt := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `message_views` (`pocket_message_uuid`,`random_id`,`viewed_at`,`ip_hash`,`user_agent`,`referrer`) VALUES (?,?,?,?,?,?)")).
+		WithArgs(uuid.Nil, "asdfghjkl", viewedAt, "hash", "curl/7.86.0", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+
+	err := s.repo.SaveMessageView(models.MessageView{
+		PocketMessageUUID: uuid.Nil,
+		RandomID:          "asdfghjkl",
+		ViewedAt:          viewedAt,
+		IPHash:            "hash",
+		UserAgent:         "curl/7.86.0",
+	})
+	s.NoError(err)
+}
+func (s *GormSuite) TestGetMessageViews() {
+	from := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	rows := s.mock.NewRows([]string{"random_id", "viewed_at", "ip_hash"}).
+		AddRow("asdfghjkl", from.Add(time.Hour), "hash")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `random_id`,`viewed_at`,`ip_hash` FROM `message_views` WHERE pocket_message_uuid = ? AND viewed_at >= ? AND viewed_at < ? ORDER BY viewed_at")).
+		WithArgs(uuid.Nil, from, to).
+		WillReturnRows(rows)
+
+	views, err := s.repo.GetMessageViews(uuid.Nil, from, to)
+	s.NoError(err)
+	s.Equal([]models.MessageView{{RandomID: "asdfghjkl", ViewedAt: from.Add(time.Hour), IPHash: "hash"}}, views)
+}
+
+// RecordFailedPassphraseAttempt
+func (s *GormSuite) TestRecordFailedPassphraseAttempt() {
+	lockedUntil := time.Date(2022, time.November, 1, 0, 15, 0, 0, time.UTC)
+	testCase := []struct {
+		name        string
+		randomID    string
+		expectError error
+	}{
+		{
+			name:        "record_failed_passphrase_attempt-normal",
+			randomID:    "asdfghjk",
+			expectError: nil,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `failed_attempts`=failed_attempts + 1,`updated_at`=? WHERE random_id = ? AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "asdfghjk").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `failed_attempts`=?,`locked_until`=?,`updated_at`=? WHERE (random_id = ? AND failed_attempts >= ?) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(0, lockedUntil, AnyTime{}, "asdfghjk", 5).
+				WillReturnResult(sqlmock.NewResult(1, 0))
+			s.mock.ExpectCommit()
+
+			err := s.repo.RecordFailedPassphraseAttempt(v.randomID, 5, lockedUntil)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+func (s *GormSuite) TestRecordFailedPassphraseAttemptError() {
+	testCase := []struct {
+		name        string
+		randomID    string
+		expectError error
+	}{
+		{
+			name:        "record_failed_passphrase_attempt-error",
+			randomID:    "asdfghjk",
+			expectError: errors.New("database error"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `failed_attempts`=failed_attempts + 1,`updated_at`=? WHERE random_id = ? AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "asdfghjk").
+				WillReturnError(errors.New("database error"))
+			s.mock.ExpectRollback()
+
+			err := s.repo.RecordFailedPassphraseAttempt(v.randomID, 5, time.Now())
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+// ResetPassphraseAttempts
+func (s *GormSuite) TestResetPassphraseAttempts() {
+	testCase := []struct {
+		name        string
+		randomID    string
+		expectError error
+	}{
+		{
+			name:        "reset_passphrase_attempts-normal",
+			randomID:    "asdfghjk",
+			expectError: nil,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_message_random_id` SET `failed_attempts`=?,`locked_until`=?,`updated_at`=? WHERE random_id = ? AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(0, nil, AnyTime{}, "asdfghjk").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectCommit()
+
+			err := s.repo.ResetPassphraseAttempts(v.randomID)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+// BurnPocketMessage
+func (s *GormSuite) TestBurnPocketMessage() {
+	testCase := []struct {
+		name        string
+		body        dto.PocketMessageWithRandomID
+		expectError error
+	}{
+		{
+			name: "burn_pocket_message-normal",
+			body: dto.PocketMessageWithRandomID{
+				UUID:     uuid.Nil,
+				RandomID: "asdfghjk",
+			},
+			expectError: nil,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_message_random_id` WHERE random_id = ?")).
+				WithArgs("asdfghjk").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.expectDeleteMessageRows(uuid.Nil)
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid = ?")).
+				WithArgs(uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectCommit()
+
+			err := s.repo.BurnPocketMessage(v.body)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+func (s *GormSuite) TestBurnPocketMessageAlreadyBurned() {
+	testCase := []struct {
+		name        string
+		body        dto.PocketMessageWithRandomID
+		expectError error
+	}{
+		{
+			name: "burn_pocket_message-already_burned",
+			body: dto.PocketMessageWithRandomID{
+				UUID:     uuid.Nil,
+				RandomID: "asdfghjk",
+			},
+			expectError: gorm.ErrRecordNotFound,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_message_random_id` WHERE random_id = ?")).
+				WithArgs("asdfghjk").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			s.mock.ExpectRollback()
+
+			err := s.repo.BurnPocketMessage(v.body)
+			s.ErrorIs(err, v.expectError)
+			s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+		})
+	}
+}
+
+// DeleteExpiredPocketMessages
+func (s *GormSuite) TestDeleteExpiredPocketMessages() {
+	testCase := []struct {
+		name         string
+		now          time.Time
+		expectResult int64
+		expectError  error
+	}{
+		{
+			name:         "delete_expired_pocket_messages-normal",
+			now:          time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC),
+			expectResult: 1,
+			expectError:  nil,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			expectRow := s.mock.NewRows([]string{"pocket_message_uuid"}).
+				AddRow("00000000-0000-0000-0000-000000000000")
+
+			s.mock.ExpectBegin()
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `pocket_message_uuid` FROM `pocket_message_random_id` WHERE ((expired_at <= ? OR (max_visit > 0 AND visit >= max_visit)) AND pocket_message_uuid IN (SELECT `uuid` FROM `pocket_messages` WHERE `pocket_messages`.`deleted_at` IS NULL)) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(v.now).
+				WillReturnRows(expectRow)
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_message_random_id` WHERE (expired_at <= ? OR (max_visit > 0 AND visit >= max_visit)) AND pocket_message_uuid IN (?)")).
+				WithArgs(v.now, uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `uuid` FROM `pocket_messages` WHERE (uuid IN (?) AND uuid NOT IN (SELECT `pocket_message_uuid` FROM `pocket_message_random_id` WHERE pocket_message_uuid IN (?) AND `pocket_message_random_id`.`deleted_at` IS NULL)) AND `pocket_messages`.`deleted_at` IS NULL")).
+				WithArgs(uuid.Nil, uuid.Nil).
+				WillReturnRows(s.mock.NewRows([]string{"uuid"}).AddRow("00000000-0000-0000-0000-000000000000"))
+			s.expectDeleteMessageRows(uuid.Nil)
+			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid IN (?)")).
+				WithArgs(uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			s.mock.ExpectCommit()
+
+			result, err := s.repo.DeleteExpiredPocketMessages(v.now)
+			s.Equal(v.expectError, err)
+			s.Equal(v.expectResult, result)
+		})
+	}
+}
+func (s *GormSuite) TestDeleteExpiredPocketMessagesError() {
+	testCase := []struct {
+		name         string
+		now          time.Time
+		expectResult int64
+		expectError  error
+	}{
+		{
+			name:         "delete_expired_pocket_messages-error",
+			now:          time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC),
+			expectResult: 0,
+			expectError:  errors.New("database error"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.mock.ExpectBegin()
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `pocket_message_uuid` FROM `pocket_message_random_id` WHERE ((expired_at <= ? OR (max_visit > 0 AND visit >= max_visit)) AND pocket_message_uuid IN (SELECT `uuid` FROM `pocket_messages` WHERE `pocket_messages`.`deleted_at` IS NULL)) AND `pocket_message_random_id`.`deleted_at` IS NULL")).
+				WithArgs(v.now).
+				WillReturnError(errors.New("database error"))
+			s.mock.ExpectRollback()
+
+			result, err := s.repo.DeleteExpiredPocketMessages(v.now)
+			s.Equal(v.expectError, err)
+			s.Equal(v.expectResult, result)
+		})
+	}
+}
 
 // UpdatePocketMessage
+func (s *GormSuite) TestGetPocketMessageByUUID() {
+	expectRow := s.mock.NewRows([]string{"uuid", "title", "content", "user_uuid"}).
+		AddRow("00000000-0000-0000-0000-000000000001", "halo", "dunia", "00000000-0000-0000-0000-000000000002")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+		WithArgs(uuid.MustParse("00000000-0000-0000-0000-000000000001")).
+		WillReturnRows(expectRow)
+
+	result, err := s.repo.GetPocketMessageByUUID(uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+	s.NoError(err)
+	s.Equal("halo", result.Title)
+	s.Equal(uuid.MustParse("00000000-0000-0000-0000-000000000002"), result.OwnerUUID())
+}
+func (s *GormSuite) TestGetPocketMessageByUUIDError() {
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+		WithArgs(uuid.Nil).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err := s.repo.GetPocketMessageByUUID(uuid.Nil)
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+}
 func (s *GormSuite) TestUpdatePocketMessage() {
 	testCase := []struct {
 		name        string
@@ -559,8 +1092,14 @@ func (s *GormSuite) TestUpdatePocketMessage() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
-				WithArgs(AnyTime{}, "super idol", "super", uuid.Nil).
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(number), 0) FROM `pocket_message_revisions` WHERE pocket_message_uuid = ?")).
+				WithArgs(uuid.Nil).
+				WillReturnRows(s.mock.NewRows([]string{"number"}).AddRow(2))
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=?,`format`=?,`encrypted`=?,`key_id`=?,`data_key`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "super idol", "super", "", false, "", "", uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+				WithArgs(uuid.Nil, 3, "super idol", "super", "", false, "", "", AnyTime{}).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			s.mock.ExpectCommit()
 
@@ -588,8 +1127,11 @@ func (s *GormSuite) TestUpdatePocketMessageError() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
-				WithArgs(AnyTime{}, "super idol", "super", uuid.Nil).
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(number), 0) FROM `pocket_message_revisions` WHERE pocket_message_uuid = ?")).
+				WithArgs(uuid.Nil).
+				WillReturnRows(s.mock.NewRows([]string{"number"}).AddRow(2))
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=?,`format`=?,`encrypted`=?,`key_id`=?,`data_key`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, "super idol", "super", "", false, "", "", uuid.Nil).
 				WillReturnError(errors.New("record not found"))
 			s.mock.ExpectRollback()
 
@@ -598,6 +1140,107 @@ func (s *GormSuite) TestUpdatePocketMessageError() {
 		})
 	}
 }
+func (s *GormSuite) TestUpdatePocketMessageErrorTrashed() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(number), 0) FROM `pocket_message_revisions` WHERE pocket_message_uuid = ?")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"number"}).AddRow(2))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=?,`format`=?,`encrypted`=?,`key_id`=?,`data_key`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+		WithArgs(AnyTime{}, "super idol", "super", "", false, "", "", uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectRollback()
+
+	err := s.repo.UpdatePocketMessage(models.PocketMessage{UUID: uuid.Nil, Title: "super idol", Content: "super"})
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+
+func (s *GormSuite) TestUpdatePocketMessageFirstEdit() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(number), 0) FROM `pocket_message_revisions` WHERE pocket_message_uuid = ?")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"number"}).AddRow(0))
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"uuid", "title", "content"}).AddRow(uuid.Nil, "old title", "old content"))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+		WithArgs(uuid.Nil, 1, "old title", "old content", "", false, "", "", AnyTime{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `updated_at`=?,`title`=?,`content`=?,`format`=?,`encrypted`=?,`key_id`=?,`data_key`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+		WithArgs(AnyTime{}, "super idol", "super", "", false, "", "", uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+		WithArgs(uuid.Nil, 2, "super idol", "super", "", false, "", "", AnyTime{}).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	s.mock.ExpectCommit()
+
+	err := s.repo.UpdatePocketMessage(models.PocketMessage{UUID: uuid.Nil, Title: "super idol", Content: "super"})
+	s.NoError(err)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *GormSuite) TestUpdatePocketMessageErrorConcurrentEdit() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(number), 0) FROM `pocket_message_revisions` WHERE pocket_message_uuid = ?")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"number"}).AddRow(2))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET")).
+		WithArgs(AnyTime{}, "super idol", "super", "", false, "", "", uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pocket_message_revisions` (`pocket_message_uuid`,`number`,`title`,`content`,`format`,`encrypted`,`key_id`,`data_key`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?)")).
+		WithArgs(uuid.Nil, 3, "super idol", "super", "", false, "", "", AnyTime{}).
+		WillReturnError(&mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	s.mock.ExpectRollback()
+
+	err := s.repo.UpdatePocketMessage(models.PocketMessage{UUID: uuid.Nil, Title: "super idol", Content: "super"})
+	s.Equal(apperr.KindConflict, apperr.As(err).Kind)
+}
+
+// GetPocketMessageRevisions
+func (s *GormSuite) TestGetPocketMessageRevisions() {
+	created := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_message_revisions` WHERE pocket_message_uuid = ? ORDER BY number")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"id", "pocket_message_uuid", "number", "title", "content", "created_at"}).
+			AddRow(1, uuid.Nil, 1, "halo", "dunia", created).
+			AddRow(2, uuid.Nil, 2, "halo", "kamu", created))
+
+	result, err := s.repo.GetPocketMessageRevisions(uuid.Nil)
+	s.NoError(err)
+	s.Equal([]models.PocketMessageRevision{
+		{ID: 1, PocketMessageUUID: uuid.Nil, Number: 1, Title: "halo", Content: "dunia", CreatedAt: created},
+		{ID: 2, PocketMessageUUID: uuid.Nil, Number: 2, Title: "halo", Content: "kamu", CreatedAt: created},
+	}, result)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_message_revisions` WHERE pocket_message_uuid = ? ORDER BY number")).
+		WithArgs(uuid.Nil).
+		WillReturnError(errors.New("database error"))
+
+	result, err = s.repo.GetPocketMessageRevisions(uuid.Nil)
+	s.Equal(errors.New("database error"), err)
+	s.Nil(result)
+}
+
+// GetPocketMessageRevision
+func (s *GormSuite) TestGetPocketMessageRevision() {
+	sql := "SELECT * FROM `pocket_message_revisions` WHERE pocket_message_uuid = ? AND number = ? ORDER BY `pocket_message_revisions`.`id` LIMIT 1"
+	s.mock.ExpectQuery(regexp.QuoteMeta(sql)).
+		WithArgs(uuid.Nil, 2).
+		WillReturnRows(s.mock.NewRows([]string{"id", "number", "title", "content"}).AddRow(2, 2, "halo", "kamu"))
+
+	result, err := s.repo.GetPocketMessageRevision(uuid.Nil, 2)
+	s.NoError(err)
+	s.Equal(2, result.Number)
+	s.Equal("kamu", result.Content)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(sql)).
+		WithArgs(uuid.Nil, 9).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err = s.repo.GetPocketMessageRevision(uuid.Nil, 9)
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+}
 
 // DeletePocketMessage
 func (s *GormSuite) TestDeletePocketMessage() {
@@ -615,9 +1258,9 @@ func (s *GormSuite) TestDeletePocketMessage() {
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid = ?")).
-				WithArgs(uuid.Nil).
-				WillReturnResult(sqlmock.NewResult(1, 1))
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `deleted_at`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(0, 1))
 			s.mock.ExpectCommit()
 
 			err := s.repo.DeletePocketMessage(v.id)
@@ -634,15 +1277,15 @@ func (s *GormSuite) TestDeletePocketMessageError() {
 		{
 			name:        "delete_pocket_message-error",
 			id:          uuid.Nil,
-			expectError: errors.New("record not found"),
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
 			s.mock.ExpectBegin()
-			s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid = ?")).
-				WithArgs(uuid.Nil).
-				WillReturnError(errors.New("record not found"))
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `deleted_at`=? WHERE uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
+				WithArgs(AnyTime{}, uuid.Nil).
+				WillReturnError(errors.New("database error"))
 			s.mock.ExpectRollback()
 
 			err := s.repo.DeletePocketMessage(v.id)
@@ -651,66 +1294,336 @@ func (s *GormSuite) TestDeletePocketMessageError() {
 	}
 }
 
-// GetPocketMessageByUserUUID
-func (s *GormSuite) TestGetPocketMessageByUserUUID() {
+// Trash
+func (s *GormSuite) TestGetTrashedPocketMessages() {
+	deleted := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	expectRow := s.mock.NewRows([]string{"uuid", "title", "content", "user_uuid", "deleted_at"}).
+		AddRow("00000000-0000-0000-0000-000000000001", "halo", "dunia", "00000000-0000-0000-0000-000000000002", deleted)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE user_uuid = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC")).
+		WithArgs(uuid.MustParse("00000000-0000-0000-0000-000000000002")).
+		WillReturnRows(expectRow)
+
+	result, err := s.repo.GetTrashedPocketMessages(uuid.MustParse("00000000-0000-0000-0000-000000000002"))
+	s.NoError(err)
+	s.Len(result, 1)
+	s.Equal("halo", result[0].Title)
+	s.Equal(deleted, result[0].DeletedAt.Time)
+}
+func (s *GormSuite) TestGetTrashedPocketMessage() {
+	expectRow := s.mock.NewRows([]string{"uuid", "title", "content", "user_uuid"}).
+		AddRow("00000000-0000-0000-0000-000000000001", "halo", "dunia", "00000000-0000-0000-0000-000000000002")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE uuid = ? AND deleted_at IS NOT NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+		WithArgs(uuid.MustParse("00000000-0000-0000-0000-000000000001")).
+		WillReturnRows(expectRow)
+
+	result, err := s.repo.GetTrashedPocketMessage(uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+	s.NoError(err)
+	s.Equal("halo", result.Title)
+}
+func (s *GormSuite) TestGetTrashedPocketMessageError() {
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE uuid = ? AND deleted_at IS NOT NULL ORDER BY `pocket_messages`.`id` LIMIT 1")).
+		WithArgs(uuid.Nil).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err := s.repo.GetTrashedPocketMessage(uuid.Nil)
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+}
+func (s *GormSuite) TestRestorePocketMessage() {
 	testCase := []struct {
-		name        string
-		id          uuid.UUID
-		expectBody  []dto.OwnedMessage
-		expectError error
+		name         string
+		rowsAffected int64
+		expectKind   apperr.Kind
 	}{
 		{
-			name: "login-normal",
-			id:   uuid.Nil,
-			expectBody: []dto.OwnedMessage{
-				{
-					RandomID: "asdfghjkl",
-					Title:    "waw",
-					Content:  "super",
-					Visit:    100,
-				},
-			},
-			expectError: nil,
+			name:         "restore_pocket_message-normal",
+			rowsAffected: 1,
+		},
+		{
+			name:         "restore_pocket_message-not_trashed",
+			rowsAffected: 0,
+			expectKind:   apperr.KindNotFound,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			expectRow := s.mock.NewRows([]string{"random_id", "title", "content", "visit"}).
-				AddRow("asdfghjkl", "waw", "super", 100)
-
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_message_random_id.random_id, pocket_messages.title, pocket_messages.content, pocket_message_random_id.visit FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_messages.user_uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
-				WithArgs(v.id).
-				WillReturnRows(expectRow)
+			s.mock.ExpectBegin()
+			s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `pocket_messages` SET `deleted_at`=?,`updated_at`=? WHERE uuid = ? AND deleted_at IS NOT NULL")).
+				WithArgs(nil, AnyTime{}, uuid.Nil).
+				WillReturnResult(sqlmock.NewResult(0, v.rowsAffected))
+			s.mock.ExpectCommit()
 
-			result, err := s.repo.GetPocketMessageByUserUUID(v.id)
-			s.Equal(v.expectError, err)
-			s.Equal(v.expectBody, result)
+			err := s.repo.RestorePocketMessage(uuid.Nil)
+			if v.expectKind == "" {
+				s.NoError(err)
+				return
+			}
+			s.Equal(v.expectKind, apperr.As(err).Kind)
 		})
 	}
 }
-func (s *GormSuite) TestGetPocketMessageByUserUUIDError() {
+func (s *GormSuite) TestPurgePocketMessage() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid = ? AND deleted_at IS NOT NULL")).
+		WithArgs(uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.expectDeleteMessageRows(uuid.Nil)
+	s.mock.ExpectCommit()
+
+	err := s.repo.PurgePocketMessage(uuid.Nil)
+	s.NoError(err)
+}
+func (s *GormSuite) TestPurgePocketMessageNotTrashed() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid = ? AND deleted_at IS NOT NULL")).
+		WithArgs(uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectRollback()
+
+	err := s.repo.PurgePocketMessage(uuid.Nil)
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Equal(apperr.KindNotFound, apperr.As(err).Kind)
+}
+func (s *GormSuite) TestPurgeTrashedPocketMessages() {
+	before := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `uuid` FROM `pocket_messages` WHERE deleted_at <= ?")).
+		WithArgs(before).
+		WillReturnRows(s.mock.NewRows([]string{"uuid"}).AddRow("00000000-0000-0000-0000-000000000000"))
+	s.expectDeleteMessageRows(uuid.Nil)
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pocket_messages` WHERE uuid IN (?)")).
+		WithArgs(uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	n, err := s.repo.PurgeTrashedPocketMessages(before)
+	s.NoError(err)
+	s.Equal(int64(1), n)
+}
+func (s *GormSuite) TestPurgeTrashedPocketMessagesNone() {
+	before := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `uuid` FROM `pocket_messages` WHERE deleted_at <= ?")).
+		WithArgs(before).
+		WillReturnRows(s.mock.NewRows([]string{"uuid"}))
+	s.mock.ExpectCommit()
+
+	n, err := s.repo.PurgeTrashedPocketMessages(before)
+	s.NoError(err)
+	s.Equal(int64(0), n)
+}
+
+// expectDeleteMessageRows expects deleteMessageRows to delete what refers to
+// the pocket message.
+func (s *GormSuite) expectDeleteMessageRows(msgID uuid.UUID) {
+	for _, table := range []string{"pocket_message_random_id", "pocket_message_revisions", "message_views", "message_search"} {
+		s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `" + table + "` WHERE pocket_message_uuid IN (?)")).
+			WithArgs(msgID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+// GetPocketMessageByUserUUID
+const ownedMessagesSQL = "SELECT pocket_messages.id, pocket_messages.uuid, pocket_messages.title, pocket_messages.content, pocket_messages.encrypted, pocket_messages.key_id, pocket_messages.data_key, pocket_messages.created_at, pocket_messages.updated_at, MIN(pocket_message_random_id.random_id) AS random_id, COALESCE(SUM(pocket_message_random_id.visit), 0) AS visit, COUNT(pocket_message_random_id.id) AS links FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid AND pocket_message_random_id.deleted_at IS NULL WHERE pocket_messages.user_uuid = ?"
+
+var ownedMessageColumns = []string{"id", "uuid", "title", "content", "encrypted", "key_id", "data_key", "created_at", "updated_at", "random_id", "visit", "links"}
+
+func (s *GormSuite) TestGetPocketMessageByUserUUID() {
+	created := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
 	testCase := []struct {
-		name        string
-		id          uuid.UUID
-		expectBody  []dto.OwnedMessage
-		expectError error
+		name       string
+		query      dto.OwnedMessageQuery
+		sql        string
+		args       []driver.Value
+		expectBody []dto.OwnedMessage
 	}{
 		{
-			name:        "login-error",
-			id:          uuid.Nil,
-			expectBody:  nil,
-			expectError: errors.New("record not found"),
+			name:  "get_pocket_message_by_user_uuid-created_desc",
+			query: dto.OwnedMessageQuery{Sort: dto.SortCreated, Desc: true, Limit: 20},
+			sql:   ownedMessagesSQL + " AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid ORDER BY pocket_messages.created_at DESC,pocket_messages.id DESC LIMIT 21",
+			args:  []driver.Value{uuid.Nil},
+			expectBody: []dto.OwnedMessage{
+				{ID: 7, UUID: msgID, RandomID: "asdfghjkl", Title: "waw", Content: "super", Visit: 100, Links: 2, CreatedAt: created, UpdatedAt: created},
+			},
+		},
+		{
+			name: "get_pocket_message_by_user_uuid-visits_asc_after_cursor_expired",
+			query: dto.OwnedMessageQuery{
+				Sort: dto.SortVisits, Limit: 20, Status: dto.StatusExpired,
+				After: &dto.OwnedMessageCursor{Sort: dto.SortVisits, Visit: 50, ID: 3},
+			},
+			sql:  ownedMessagesSQL + " AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid HAVING (COUNT(pocket_message_random_id.id) > 0 AND SUM(CASE WHEN (pocket_message_random_id.expired_at IS NULL OR pocket_message_random_id.expired_at > ?) AND (pocket_message_random_id.max_visit = 0 OR pocket_message_random_id.visit < pocket_message_random_id.max_visit) THEN 1 ELSE 0 END) = 0) AND ((COALESCE(SUM(pocket_message_random_id.visit), 0) > ? OR (COALESCE(SUM(pocket_message_random_id.visit), 0) = ? AND pocket_messages.id > ?))) ORDER BY COALESCE(SUM(pocket_message_random_id.visit), 0) ASC,pocket_messages.id ASC LIMIT 21",
+			args: []driver.Value{uuid.Nil, AnyTime{}, 50, 50, 3},
+			expectBody: []dto.OwnedMessage{
+				{ID: 7, UUID: msgID, RandomID: "asdfghjkl", Title: "waw", Content: "super", Visit: 100, Links: 2, CreatedAt: created, UpdatedAt: created},
+			},
+		},
+		{
+			name:  "get_pocket_message_by_user_uuid-updated_desc_created_range",
+			query: dto.OwnedMessageQuery{Sort: dto.SortUpdated, Desc: true, Limit: 20, CreatedFrom: &created, CreatedTo: &created},
+			sql:   ownedMessagesSQL + " AND pocket_messages.created_at >= ? AND pocket_messages.created_at < ? AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid ORDER BY pocket_messages.updated_at DESC,pocket_messages.id DESC LIMIT 21",
+			args:  []driver.Value{uuid.Nil, created, created},
+			expectBody: []dto.OwnedMessage{
+				{ID: 7, UUID: msgID, RandomID: "asdfghjkl", Title: "waw", Content: "super", Visit: 100, Links: 2, CreatedAt: created, UpdatedAt: created},
+			},
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT pocket_message_random_id.random_id, pocket_messages.title, pocket_messages.content, pocket_message_random_id.visit FROM `pocket_messages` LEFT JOIN pocket_message_random_id ON pocket_messages.uuid = pocket_message_random_id.pocket_message_uuid WHERE pocket_messages.user_uuid = ? AND `pocket_messages`.`deleted_at` IS NULL")).
-				WithArgs(v.id).
-				WillReturnError(errors.New("record not found"))
+			expectRow := s.mock.NewRows(ownedMessageColumns).
+				AddRow(7, msgID, "waw", "super", false, "", "", created, created, "asdfghjkl", 100, 2)
 
-			result, err := s.repo.GetPocketMessageByUserUUID(v.id)
-			s.Equal(v.expectError, err)
+			s.mock.ExpectQuery(regexp.QuoteMeta(v.sql)).
+				WithArgs(v.args...).
+				WillReturnRows(expectRow)
+
+			result, err := s.repo.GetPocketMessageByUserUUID(uuid.Nil, v.query)
+			s.NoError(err)
 			s.Equal(v.expectBody, result)
+			s.NoError(s.mock.ExpectationsWereMet())
 		})
 	}
 }
+func (s *GormSuite) TestGetPocketMessageByUserUUIDTitle() {
+	created := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	page := ownedMessagesSQL + " AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid ORDER BY pocket_messages.created_at DESC,pocket_messages.id DESC LIMIT 2"
+	next := ownedMessagesSQL + " AND ((pocket_messages.created_at < ? OR (pocket_messages.created_at = ? AND pocket_messages.id < ?))) AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid ORDER BY pocket_messages.created_at DESC,pocket_messages.id DESC LIMIT 2"
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(page)).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows(ownedMessageColumns).
+			AddRow(9, uuid.Nil, "groceries", "milk", false, "", "", created, created, "a", 0, 1).
+			AddRow(8, uuid.Nil, "Happy Birthday", "cake", false, "", "", created, created, "b", 0, 1))
+	s.mock.ExpectQuery(regexp.QuoteMeta(next)).
+		WithArgs(uuid.Nil, created, created, 8).
+		WillReturnRows(s.mock.NewRows(ownedMessageColumns).
+			AddRow(6, uuid.Nil, "birthday list", "gifts", false, "", "", created, created, "c", 0, 1))
+
+	result, err := s.repo.GetPocketMessageByUserUUID(uuid.Nil, dto.OwnedMessageQuery{Sort: dto.SortCreated, Desc: true, Limit: 1, Title: "BIRTHDAY"})
+	s.NoError(err)
+	s.Len(result, 2)
+	s.Equal("Happy Birthday", result[0].Title)
+	s.Equal("birthday list", result[1].Title)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *GormSuite) TestGetPocketMessageByUserUUIDError() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(ownedMessagesSQL)).
+		WithArgs(uuid.Nil).
+		WillReturnError(errors.New("record not found"))
+
+	result, err := s.repo.GetPocketMessageByUserUUID(uuid.Nil, dto.OwnedMessageQuery{Sort: dto.SortCreated, Desc: true, Limit: 20})
+	s.Equal(errors.New("record not found"), err)
+	s.Nil(result)
+}
+
+// CountPocketMessageByUserUUID
+func (s *GormSuite) TestCountPocketMessageByUserUUID() {
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM (" + ownedMessagesSQL + " AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid) AS owned")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"count"}).AddRow(42))
+
+	total, err := s.repo.CountPocketMessageByUserUUID(uuid.Nil, dto.OwnedMessageQuery{})
+	s.NoError(err)
+	s.Equal(int64(42), total)
+}
+func (s *GormSuite) TestCountPocketMessageByUserUUIDTitle() {
+	created := time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+	s.mock.ExpectQuery(regexp.QuoteMeta(ownedMessagesSQL + " AND `pocket_messages`.`deleted_at` IS NULL GROUP BY pocket_messages.id, pocket_messages.uuid")).
+		WithArgs(uuid.Nil).
+		WillReturnRows(s.mock.NewRows(ownedMessageColumns).
+			AddRow(9, uuid.Nil, "groceries", "milk", false, "", "", created, created, "a", 0, 1).
+			AddRow(8, uuid.Nil, "Happy Birthday", "cake", false, "", "", created, created, "b", 0, 1))
+
+	total, err := s.repo.CountPocketMessageByUserUUID(uuid.Nil, dto.OwnedMessageQuery{Title: "birthday"})
+	s.NoError(err)
+	s.Equal(int64(1), total)
+}
+func (s *GormSuite) TestCountPocketMessageByUserUUIDError() {
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM (")).
+		WithArgs(uuid.Nil).
+		WillReturnError(errors.New("record not found"))
+
+	total, err := s.repo.CountPocketMessageByUserUUID(uuid.Nil, dto.OwnedMessageQuery{})
+	s.Equal(errors.New("record not found"), err)
+	s.Zero(total)
+}
+
+// FullTextIndex
+func (s *GormSuite) TestFullTextIndexIndex() {
+	index := NewFullTextIndex(s.repo.(*GormSql).DB, []byte("key"))
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	hashed := search.HashTerms([]byte("key"), []string{"halo", "dunia"})
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `message_search` (`pocket_message_uuid`,`user_uuid`,`title_terms`,`content_terms`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `user_uuid`=VALUES(`user_uuid`),`title_terms`=VALUES(`title_terms`),`content_terms`=VALUES(`content_terms`)")).
+		WithArgs(msgID, uuid.Nil, hashed[0]+" "+hashed[1], "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+
+	err := index.Index(search.Document{ID: msgID, Owner: uuid.Nil, Title: "Halo, dunia!"})
+	s.NoError(err)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *GormSuite) TestFullTextIndexRemove() {
+	index := NewFullTextIndex(s.repo.(*GormSql).DB, []byte("key"))
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `message_search` WHERE pocket_message_uuid = ?")).
+		WithArgs(uuid.Nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	err := index.Remove(uuid.Nil)
+	s.NoError(err)
+	s.NoError(s.mock.ExpectationsWereMet())
+}
+func (s *GormSuite) TestFullTextIndexSearch() {
+	index := NewFullTextIndex(s.repo.(*GormSql).DB, []byte("key"))
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	query := search.HashTerms([]byte("key"), []string{"kamu"})[0]
+	sql := "SELECT pocket_message_uuid, MATCH(title_terms) AGAINST(?) * ? + MATCH(content_terms) AGAINST(?) AS score FROM `message_search` WHERE user_uuid = ?  HAVING score > 0 ORDER BY score DESC,pocket_message_uuid LIMIT 10"
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(sql)).
+		WithArgs(query, search.TitleWeight, query, uuid.Nil).
+		WillReturnRows(s.mock.NewRows([]string{"pocket_message_uuid", "score"}).AddRow(msgID, 1.5))
+
+	hits, err := index.Search(uuid.Nil, "Kamu", 10)
+	s.NoError(err)
+	s.Equal([]search.Hit{{ID: msgID, Score: 1.5}}, hits)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(sql)).
+		WithArgs(query, search.TitleWeight, query, uuid.Nil).
+		WillReturnError(errors.New("database error"))
+
+	hits, err = index.Search(uuid.Nil, "kamu", 10)
+	s.Equal(errors.New("database error"), err)
+	s.Nil(hits)
+}
+
+// GetPocketMessagesAfter
+func (s *GormSuite) TestGetPocketMessagesAfter() {
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE id > ? AND `pocket_messages`.`deleted_at` IS NULL ORDER BY id LIMIT 2")).
+		WithArgs(5).
+		WillReturnRows(s.mock.NewRows([]string{"id", "uuid", "title", "content"}).AddRow(6, msgID, "halo", "dunia"))
+
+	result, err := s.repo.GetPocketMessagesAfter(5, 2)
+	s.NoError(err)
+	if s.Len(result, 1) {
+		s.Equal(uint(6), result[0].ID)
+		s.Equal("halo", result[0].Title)
+	}
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pocket_messages` WHERE id > ?")).
+		WithArgs(6).
+		WillReturnError(errors.New("database error"))
+
+	result, err = s.repo.GetPocketMessagesAfter(6, 2)
+	s.Equal(errors.New("database error"), err)
+	s.Nil(result)
+}
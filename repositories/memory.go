@@ -0,0 +1,770 @@
+package repositories
+
+import (
+	"errors"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Memory is a Database kept in the memory of the process, for tests and
+// demos without a database server. It answers like GormSql: trashed messages
+// stay until they are purged, unique columns report conflicts and missing
+// rows report gorm.ErrRecordNotFound. Nothing is stored at rest, so messages
+// are not encrypted, and everything is lost on restart.
+type Memory struct {
+	mu sync.RWMutex
+	// lastID numbers the rows of every table, like an auto increment column.
+	lastID      uint
+	users       []models.User
+	resetTokens []models.PasswordResetToken
+	sessions    []models.Session
+	messages    []models.PocketMessage
+	randomIDs   []models.PocketMessageRandomID
+	views       []models.MessageView
+	revisions   []models.PocketMessageRevision
+}
+
+func NewMemory() Database {
+	return &Memory{}
+}
+
+// errDuplicate is the conflict GormSql reports for a unique index violation.
+var errDuplicate = apperr.Wrap(apperr.KindConflict, "conflict", errors.New("error, duplicate entry"))
+
+func (db *Memory) nextID() uint {
+	db.lastID++
+	return db.lastID
+}
+
+// created stamps a new row the way gorm does on insert.
+func (db *Memory) created(m *gorm.Model, now time.Time) {
+	m.ID = db.nextID()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = now
+	}
+}
+
+// User
+func (db *Memory) SaveNewUser(user models.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, u := range db.users {
+		if u.Username == user.Username || u.UUID == user.UUID {
+			return errDuplicate
+		}
+	}
+	db.created(&user.Model, time.Now())
+	user.PocketMessage = nil
+	db.users = append(db.users, user)
+	return nil
+}
+func (db *Memory) GetUserByUsername(username string) (models.User, error) {
+	return db.findUser(func(u models.User) bool { return u.Username == username })
+}
+func (db *Memory) GetUserByUUID(id uuid.UUID) (models.User, error) {
+	return db.findUser(func(u models.User) bool { return u.UUID == id })
+}
+func (db *Memory) findUser(match func(models.User) bool) (models.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, u := range db.users {
+		if !u.DeletedAt.Valid && match(u) {
+			return u, nil
+		}
+	}
+	return models.User{}, translateError(gorm.ErrRecordNotFound)
+}
+func (db *Memory) UpdateUsername(user models.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, u := range db.users {
+		if u.Username == user.Username && u.UUID != user.UUID {
+			return errDuplicate
+		}
+	}
+	db.updateUser(user.UUID, func(u *models.User) { u.Username = user.Username })
+	return nil
+}
+func (db *Memory) UpdatePassword(user models.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.updateUser(user.UUID, func(u *models.User) { u.Password = user.Password })
+	return nil
+}
+func (db *Memory) updateUser(id uuid.UUID, update func(*models.User)) {
+	for i := range db.users {
+		if db.users[i].UUID == id && !db.users[i].DeletedAt.Valid {
+			update(&db.users[i])
+			db.users[i].UpdatedAt = time.Now()
+		}
+	}
+}
+func (db *Memory) SavePasswordResetToken(token models.PasswordResetToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, t := range db.resetTokens {
+		if t.TokenHash == token.TokenHash {
+			return errDuplicate
+		}
+	}
+	db.created(&token.Model, time.Now())
+	db.resetTokens = append(db.resetTokens, token)
+	return nil
+}
+func (db *Memory) UsePasswordResetToken(tokenHash string, now time.Time) (models.PasswordResetToken, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, t := range db.resetTokens {
+		if t.TokenHash != tokenHash || t.UsedAt != nil || !t.ExpiredAt.After(now) || t.DeletedAt.Valid {
+			continue
+		}
+		// Using one token uses every other token of its user.
+		for j := range db.resetTokens {
+			if db.resetTokens[j].UserUUID == t.UserUUID && db.resetTokens[j].UsedAt == nil {
+				db.resetTokens[j].UsedAt = &now
+			}
+		}
+		return db.resetTokens[i], nil
+	}
+	return models.PasswordResetToken{}, gorm.ErrRecordNotFound
+}
+
+// Session
+func (db *Memory) SaveSession(session models.Session) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.saveSession(session)
+}
+func (db *Memory) saveSession(session models.Session) error {
+	for _, s := range db.sessions {
+		if s.UUID == session.UUID || s.RefreshTokenHash == session.RefreshTokenHash {
+			return errDuplicate
+		}
+	}
+	db.created(&session.Model, time.Now())
+	db.sessions = append(db.sessions, session)
+	return nil
+}
+func (db *Memory) GetSessionByUUID(id uuid.UUID) (models.Session, error) {
+	return db.findSession(func(s models.Session) bool { return s.UUID == id })
+}
+func (db *Memory) GetSessionByRefreshTokenHash(tokenHash string) (models.Session, error) {
+	return db.findSession(func(s models.Session) bool { return s.RefreshTokenHash == tokenHash })
+}
+func (db *Memory) findSession(match func(models.Session) bool) (models.Session, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, s := range db.sessions {
+		if !s.DeletedAt.Valid && match(s) {
+			return s, nil
+		}
+	}
+	return models.Session{}, translateError(gorm.ErrRecordNotFound)
+}
+func (db *Memory) RotateSession(id uuid.UUID, next models.Session, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, s := range db.sessions {
+		if s.UUID != id || !s.Active(now) || s.DeletedAt.Valid {
+			continue
+		}
+		err := db.saveSession(next)
+		if err != nil {
+			return err
+		}
+		db.sessions[i].RotatedAt = &now
+		db.sessions[i].RevokedAt = &now
+		return nil
+	}
+	return gorm.ErrRecordNotFound
+}
+func (db *Memory) RevokeSession(id uuid.UUID, now time.Time) error {
+	return db.revokeSessions(func(s models.Session) bool { return s.UUID == id }, now)
+}
+func (db *Memory) RevokeSessionFamily(familyID uuid.UUID, now time.Time) error {
+	return db.revokeSessions(func(s models.Session) bool { return s.FamilyID == familyID }, now)
+}
+func (db *Memory) RevokeUserSessions(userUUID uuid.UUID, now time.Time) error {
+	return db.revokeSessions(func(s models.Session) bool { return s.UserUUID == userUUID }, now)
+}
+func (db *Memory) RevokeOtherUserSessions(userUUID, keep uuid.UUID, now time.Time) error {
+	return db.revokeSessions(func(s models.Session) bool { return s.UserUUID == userUUID && s.UUID != keep }, now)
+}
+func (db *Memory) revokeSessions(match func(models.Session) bool, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, s := range db.sessions {
+		if s.RevokedAt == nil && !s.DeletedAt.Valid && match(s) {
+			db.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// Pocket Message
+func (db *Memory) SaveNewPocketMessage(pm models.PocketMessage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.hasMessage(pm.UUID) {
+		return errDuplicate
+	}
+	db.saveNewPocketMessage(pm)
+	return nil
+}
+func (db *Memory) hasMessage(msgID uuid.UUID) bool {
+	for _, m := range db.messages {
+		if m.UUID == msgID {
+			return true
+		}
+	}
+	return false
+}
+func (db *Memory) saveNewPocketMessage(pm models.PocketMessage) {
+	db.created(&pm.Model, time.Now())
+	pm.KeyID, pm.DataKey = "", ""
+	db.messages = append(db.messages, pm)
+	db.saveRevision(models.RevisionOf(pm, 1))
+}
+func (db *Memory) saveRevision(rev models.PocketMessageRevision) {
+	rev.ID = db.nextID()
+	rev.CreatedAt = time.Now()
+	db.revisions = append(db.revisions, rev)
+}
+func (db *Memory) SaveNewRandomID(rid models.PocketMessageRandomID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.hasRandomID(rid.RandomID) {
+		return errDuplicate
+	}
+	db.saveNewRandomID(rid)
+	return nil
+}
+func (db *Memory) SaveNewPocketMessageWithRandomID(pm models.PocketMessage, rid models.PocketMessageRandomID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.hasMessage(pm.UUID) || db.hasRandomID(rid.RandomID) {
+		return errDuplicate
+	}
+	db.saveNewPocketMessage(pm)
+	db.saveNewRandomID(rid)
+	return nil
+}
+func (db *Memory) hasRandomID(rid string) bool {
+	for _, r := range db.randomIDs {
+		if r.RandomID == rid {
+			return true
+		}
+	}
+	return false
+}
+func (db *Memory) saveNewRandomID(rid models.PocketMessageRandomID) {
+	db.created(&rid.Model, time.Now())
+	// The stored times are copied, so the caller can not change them later.
+	rid.ExpiredAt = copyTime(rid.ExpiredAt)
+	rid.LockedUntil = copyTime(rid.LockedUntil)
+	db.randomIDs = append(db.randomIDs, rid)
+}
+func (db *Memory) GetRandomIDsByPocketMessageUUID(msgID uuid.UUID) ([]models.PocketMessageRandomID, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var rids []models.PocketMessageRandomID
+	for _, r := range db.randomIDs {
+		if r.PocketMessageUUID == msgID && !r.DeletedAt.Valid {
+			r.ExpiredAt = copyTime(r.ExpiredAt)
+			r.LockedUntil = copyTime(r.LockedUntil)
+			rids = append(rids, r)
+		}
+	}
+	return rids, nil
+}
+func (db *Memory) DeleteRandomID(msgID uuid.UUID, rid string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	n := db.deleteRandomIDs(func(r models.PocketMessageRandomID) bool {
+		return r.PocketMessageUUID == msgID && r.RandomID == rid
+	})
+	if n == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+func (db *Memory) GetPocketMessageByRandomID(rid string) (dto.PocketMessageWithRandomID, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	r, ok := db.findRandomID(rid)
+	if ok {
+		pm, ok := db.findMessage(r.PocketMessageUUID, false)
+		if ok {
+			return dto.PocketMessageWithRandomID{
+				UUID:           pm.UUID,
+				Title:          pm.Title,
+				Content:        pm.Content,
+				Format:         pm.Format,
+				Visit:          r.Visit,
+				RandomID:       r.RandomID,
+				BurnAfterRead:  pm.BurnAfterRead,
+				Encrypted:      pm.Encrypted,
+				MaxVisit:       r.MaxVisit,
+				ExpiredAt:      copyTime(r.ExpiredAt),
+				PassphraseHash: r.PassphraseHash,
+				FailedAttempts: r.FailedAttempts,
+				LockedUntil:    copyTime(r.LockedUntil),
+			}, nil
+		}
+	}
+	return dto.PocketMessageWithRandomID{}, translateError(gorm.ErrRecordNotFound)
+}
+
+// UpdateVisitCount counts a visit of the random id unless it reached its
+// visit limit, which is reported as not found.
+func (db *Memory) UpdateVisitCount(rid dto.PocketMessageWithRandomID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, r := range db.randomIDs {
+		if r.RandomID == rid.RandomID && !r.DeletedAt.Valid && (r.MaxVisit == 0 || r.Visit < r.MaxVisit) {
+			db.randomIDs[i].Visit++
+			db.randomIDs[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return translateError(gorm.ErrRecordNotFound)
+}
+func (db *Memory) SaveMessageView(view models.MessageView) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	view.ID = db.nextID()
+	db.views = append(db.views, view)
+	return nil
+}
+func (db *Memory) GetMessageViews(msgID uuid.UUID, from, to time.Time) ([]models.MessageView, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var views []models.MessageView
+	for _, v := range db.views {
+		if v.PocketMessageUUID == msgID && !v.ViewedAt.Before(from) && v.ViewedAt.Before(to) {
+			views = append(views, models.MessageView{RandomID: v.RandomID, ViewedAt: v.ViewedAt, IPHash: v.IPHash})
+		}
+	}
+	sort.SliceStable(views, func(i, j int) bool { return views[i].ViewedAt.Before(views[j].ViewedAt) })
+	return views, nil
+}
+func (db *Memory) RecordFailedPassphraseAttempt(rid string, maxAttempts int, lockedUntil time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, r := range db.randomIDs {
+		if r.RandomID != rid || r.DeletedAt.Valid {
+			continue
+		}
+		db.randomIDs[i].FailedAttempts++
+		if db.randomIDs[i].FailedAttempts >= maxAttempts {
+			db.randomIDs[i].FailedAttempts = 0
+			db.randomIDs[i].LockedUntil = &lockedUntil
+		}
+	}
+	return nil
+}
+func (db *Memory) ResetPassphraseAttempts(rid string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, r := range db.randomIDs {
+		if r.RandomID == rid && !r.DeletedAt.Valid {
+			db.randomIDs[i].FailedAttempts = 0
+			db.randomIDs[i].LockedUntil = nil
+		}
+	}
+	return nil
+}
+
+// BurnPocketMessage deletes the message read through rid. Only the first of
+// concurrent readers removes the random id, the others get not found.
+func (db *Memory) BurnPocketMessage(rid dto.PocketMessageWithRandomID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	n := db.deleteRandomIDs(func(r models.PocketMessageRandomID) bool { return r.RandomID == rid.RandomID })
+	if n == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	db.purge(func(pm models.PocketMessage) bool { return pm.UUID == rid.UUID })
+	return nil
+}
+
+// DeleteExpiredPocketMessages deletes expired share links and the messages
+// whose last link expired with them. It returns the number of deleted messages.
+// Messages in the trash are left to the trash purge.
+func (db *Memory) DeleteExpiredPocketMessages(now time.Time) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	expired := make(map[uuid.UUID]bool)
+	db.deleteRandomIDs(func(r models.PocketMessageRandomID) bool {
+		if r.DeletedAt.Valid {
+			return false
+		}
+		if _, live := db.findMessage(r.PocketMessageUUID, false); !live {
+			return false
+		}
+		if (r.ExpiredAt != nil && !r.ExpiredAt.After(now)) || (r.MaxVisit > 0 && r.Visit >= r.MaxVisit) {
+			expired[r.PocketMessageUUID] = true
+			return true
+		}
+		return false
+	})
+	// Messages still shared through another link stay.
+	for _, r := range db.randomIDs {
+		delete(expired, r.PocketMessageUUID)
+	}
+
+	return db.purge(func(pm models.PocketMessage) bool { return expired[pm.UUID] && !pm.DeletedAt.Valid }), nil
+}
+func (db *Memory) GetPocketMessageByUUID(msgID uuid.UUID) (models.PocketMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	pm, ok := db.findMessage(msgID, false)
+	if !ok {
+		return models.PocketMessage{}, translateError(gorm.ErrRecordNotFound)
+	}
+	return pm, nil
+}
+
+// GetPocketMessagesAfter returns up to limit pocket messages with an id
+// above afterID, in id order.
+func (db *Memory) GetPocketMessagesAfter(afterID uint, limit int) ([]models.PocketMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var pms []models.PocketMessage
+	for _, pm := range db.messages {
+		if len(pms) == limit {
+			break
+		}
+		if pm.ID > afterID && !pm.DeletedAt.Valid {
+			pms = append(pms, pm)
+		}
+	}
+	return pms, nil
+}
+
+// UpdatePocketMessage saves newMsg as the next revision of the message.
+func (db *Memory) UpdatePocketMessage(newMsg models.PocketMessage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	last := 0
+	for _, rev := range db.revisions {
+		if rev.PocketMessageUUID == newMsg.UUID && rev.Number > last {
+			last = rev.Number
+		}
+	}
+
+	found := false
+	for i, pm := range db.messages {
+		if pm.UUID != newMsg.UUID || pm.DeletedAt.Valid {
+			continue
+		}
+		found = true
+		if last == 0 {
+			db.saveRevision(models.RevisionOf(pm, 1))
+			last = 1
+		}
+		db.messages[i].Title = newMsg.Title
+		db.messages[i].Content = newMsg.Content
+		db.messages[i].Format = newMsg.Format
+		db.messages[i].Encrypted = newMsg.Encrypted
+		db.messages[i].UpdatedAt = time.Now()
+	}
+	if !found {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+
+	newMsg.KeyID, newMsg.DataKey = "", ""
+	db.saveRevision(models.RevisionOf(newMsg, last+1))
+	return nil
+}
+
+// DeletePocketMessage moves a pocket message to the trash of its owner.
+func (db *Memory) DeletePocketMessage(msgID uuid.UUID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	now := time.Now()
+	for i, pm := range db.messages {
+		if pm.UUID == msgID && !pm.DeletedAt.Valid {
+			db.messages[i].DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+// GetTrashedPocketMessages lists the pocket messages of a user that are in
+// the trash, most recently deleted first.
+func (db *Memory) GetTrashedPocketMessages(userUUID uuid.UUID) ([]models.PocketMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var pms []models.PocketMessage
+	for _, pm := range db.messages {
+		if pm.UserUUID == userUUID && pm.DeletedAt.Valid {
+			pms = append(pms, pm)
+		}
+	}
+	sort.SliceStable(pms, func(i, j int) bool { return pms[i].DeletedAt.Time.After(pms[j].DeletedAt.Time) })
+	return pms, nil
+}
+func (db *Memory) GetTrashedPocketMessage(msgID uuid.UUID) (models.PocketMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	pm, ok := db.findMessage(msgID, true)
+	if !ok {
+		return models.PocketMessage{}, translateError(gorm.ErrRecordNotFound)
+	}
+	return pm, nil
+}
+func (db *Memory) RestorePocketMessage(msgID uuid.UUID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, pm := range db.messages {
+		if pm.UUID == msgID && pm.DeletedAt.Valid {
+			db.messages[i].DeletedAt = gorm.DeletedAt{}
+			return nil
+		}
+	}
+	return translateError(gorm.ErrRecordNotFound)
+}
+
+// PurgePocketMessage permanently deletes a trashed pocket message together
+// with its share links, revisions and views.
+func (db *Memory) PurgePocketMessage(msgID uuid.UUID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	n := db.purge(func(pm models.PocketMessage) bool { return pm.UUID == msgID && pm.DeletedAt.Valid })
+	if n == 0 {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// PurgeTrashedPocketMessages permanently deletes the pocket messages trashed
+// at or before the given time. It returns the number of deleted messages.
+func (db *Memory) PurgeTrashedPocketMessages(before time.Time) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.purge(func(pm models.PocketMessage) bool {
+		return pm.DeletedAt.Valid && !pm.DeletedAt.Time.After(before)
+	}), nil
+}
+
+// purge permanently deletes the matching pocket messages with their share
+// links, revisions and views.
+func (db *Memory) purge(match func(models.PocketMessage) bool) int64 {
+	ids := make(map[uuid.UUID]bool)
+	for _, pm := range db.messages {
+		if match(pm) {
+			ids[pm.UUID] = true
+		}
+	}
+	db.deleteRandomIDs(func(r models.PocketMessageRandomID) bool { return ids[r.PocketMessageUUID] })
+	db.deleteRevisions(ids)
+	views := db.views[:0]
+	for _, v := range db.views {
+		if !ids[v.PocketMessageUUID] {
+			views = append(views, v)
+		}
+	}
+	db.views = views
+	return db.deleteMessages(func(pm models.PocketMessage) bool { return ids[pm.UUID] })
+}
+func (db *Memory) GetPocketMessageRevisions(msgID uuid.UUID) ([]models.PocketMessageRevision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var revs []models.PocketMessageRevision
+	for _, rev := range db.revisions {
+		if rev.PocketMessageUUID == msgID {
+			revs = append(revs, rev)
+		}
+	}
+	sort.SliceStable(revs, func(i, j int) bool { return revs[i].Number < revs[j].Number })
+	return revs, nil
+}
+func (db *Memory) GetPocketMessageRevision(msgID uuid.UUID, n int) (models.PocketMessageRevision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, rev := range db.revisions {
+		if rev.PocketMessageUUID == msgID && rev.Number == n {
+			return rev, nil
+		}
+	}
+	return models.PocketMessageRevision{}, translateError(gorm.ErrRecordNotFound)
+}
+
+// GetPocketMessageByUserUUID returns up to q.Limit+1 messages of the user,
+// so callers can tell whether there is a next page.
+func (db *Memory) GetPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) ([]dto.OwnedMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	owned := db.ownedMessages(uuid, q, time.Now())
+
+	// less orders the listing by the sorted value, then by id.
+	less := func(a, b dto.OwnedMessage) bool {
+		ca, cb := a.CursorOf(q.Sort, false), b.CursorOf(q.Sort, false)
+		switch {
+		case q.Sort == dto.SortVisits && ca.Visit != cb.Visit:
+			return ca.Visit < cb.Visit
+		case !ca.Time.Equal(cb.Time):
+			return ca.Time.Before(cb.Time)
+		}
+		return a.ID < b.ID
+	}
+	if q.Desc {
+		asc := less
+		less = func(a, b dto.OwnedMessage) bool { return asc(b, a) }
+	}
+	sort.Slice(owned, func(i, j int) bool { return less(owned[i], owned[j]) })
+
+	result := make([]dto.OwnedMessage, 0, q.Limit+1)
+	for _, m := range owned {
+		if q.After != nil {
+			after := dto.OwnedMessage{ID: q.After.ID, Visit: q.After.Visit, CreatedAt: q.After.Time, UpdatedAt: q.After.Time}
+			if !less(after, m) {
+				continue
+			}
+		}
+		result = append(result, m)
+		if len(result) > q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// CountPocketMessageByUserUUID counts the messages of the user matching the
+// filters of q.
+func (db *Memory) CountPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return int64(len(db.ownedMessages(uuid, q, time.Now()))), nil
+}
+
+// ownedMessages summarizes the messages of a user with the share links they
+// have left, keeping the ones matching the filters of q.
+func (db *Memory) ownedMessages(userUUID uuid.UUID, q dto.OwnedMessageQuery, now time.Time) []dto.OwnedMessage {
+	var owned []dto.OwnedMessage
+	for _, pm := range db.messages {
+		if pm.UserUUID != userUUID || pm.DeletedAt.Valid ||
+			(q.CreatedFrom != nil && pm.CreatedAt.Before(*q.CreatedFrom)) ||
+			(q.CreatedTo != nil && !pm.CreatedAt.Before(*q.CreatedTo)) ||
+			!titleContains(pm.Title, q.Title) {
+			continue
+		}
+
+		m := dto.OwnedMessage{
+			ID:        pm.ID,
+			UUID:      pm.UUID,
+			Title:     pm.Title,
+			Content:   pm.Content,
+			Encrypted: pm.Encrypted,
+			CreatedAt: pm.CreatedAt,
+			UpdatedAt: pm.UpdatedAt,
+		}
+		open := 0
+		for _, r := range db.randomIDs {
+			if r.PocketMessageUUID != pm.UUID || r.DeletedAt.Valid {
+				continue
+			}
+			if m.Links == 0 || r.RandomID < m.RandomID {
+				m.RandomID = r.RandomID
+			}
+			m.Links++
+			m.Visit += r.Visit
+			if (r.ExpiredAt == nil || r.ExpiredAt.After(now)) && (r.MaxVisit == 0 || r.Visit < r.MaxVisit) {
+				open++
+			}
+		}
+		if q.Status != "" && q.Status != linkStatus(m.Links, open) {
+			continue
+		}
+		owned = append(owned, m)
+	}
+	return owned
+}
+
+// linkStatus is the status of a message with the given number of links, of
+// which open can be opened.
+func linkStatus(links, open int) string {
+	switch {
+	case links == 0:
+		return dto.StatusRevoked
+	case open == 0:
+		return dto.StatusExpired
+	}
+	return dto.StatusActive
+}
+
+func (db *Memory) findRandomID(rid string) (models.PocketMessageRandomID, bool) {
+	for _, r := range db.randomIDs {
+		if r.RandomID == rid && !r.DeletedAt.Valid {
+			return r, true
+		}
+	}
+	return models.PocketMessageRandomID{}, false
+}
+
+// findMessage finds a pocket message out of the trash, or in it when
+// trashed is set.
+func (db *Memory) findMessage(msgID uuid.UUID, trashed bool) (models.PocketMessage, bool) {
+	for _, pm := range db.messages {
+		if pm.UUID == msgID && pm.DeletedAt.Valid == trashed {
+			return pm, true
+		}
+	}
+	return models.PocketMessage{}, false
+}
+
+// The delete helpers remove rows for good and return how many they removed.
+func (db *Memory) deleteMessages(match func(models.PocketMessage) bool) int64 {
+	var n int64
+	kept := db.messages[:0]
+	for _, pm := range db.messages {
+		if match(pm) {
+			n++
+			continue
+		}
+		kept = append(kept, pm)
+	}
+	db.messages = kept
+	return n
+}
+func (db *Memory) deleteRandomIDs(match func(models.PocketMessageRandomID) bool) int64 {
+	var n int64
+	kept := db.randomIDs[:0]
+	for _, r := range db.randomIDs {
+		if match(r) {
+			n++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	db.randomIDs = kept
+	return n
+}
+func (db *Memory) deleteRevisions(msgIDs map[uuid.UUID]bool) {
+	kept := db.revisions[:0]
+	for _, rev := range db.revisions {
+		if !msgIDs[rev.PocketMessageUUID] {
+			kept = append(kept, rev)
+		}
+	}
+	db.revisions = kept
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -3,20 +3,51 @@ package repositories
 import (
 	"pocket-message/dto"
 	"pocket-message/models"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Database interface {
 	SaveNewUser(models.User) error
-	Login(models.User) (models.User, error)
+	GetUserByUsername(username string) (models.User, error)
 	UpdateUsername(models.User) error
 	UpdatePassword(models.User) error
+	GetUserByUUID(id uuid.UUID) (models.User, error)
+	SavePasswordResetToken(models.PasswordResetToken) error
+	UsePasswordResetToken(tokenHash string, now time.Time) (models.PasswordResetToken, error)
+	SaveSession(models.Session) error
+	GetSessionByUUID(id uuid.UUID) (models.Session, error)
+	GetSessionByRefreshTokenHash(tokenHash string) (models.Session, error)
+	RotateSession(id uuid.UUID, next models.Session, now time.Time) error
+	RevokeSession(id uuid.UUID, now time.Time) error
+	RevokeSessionFamily(familyID uuid.UUID, now time.Time) error
+	RevokeUserSessions(userUUID uuid.UUID, now time.Time) error
+	RevokeOtherUserSessions(userUUID, keep uuid.UUID, now time.Time) error
 	SaveNewPocketMessage(models.PocketMessage) error
 	SaveNewRandomID(models.PocketMessageRandomID) error
+	SaveNewPocketMessageWithRandomID(models.PocketMessage, models.PocketMessageRandomID) error
+	GetRandomIDsByPocketMessageUUID(msgID uuid.UUID) ([]models.PocketMessageRandomID, error)
+	DeleteRandomID(msgID uuid.UUID, rid string) error
 	GetPocketMessageByRandomID(rid string) (dto.PocketMessageWithRandomID, error)
 	UpdateVisitCount(rid dto.PocketMessageWithRandomID) error
+	SaveMessageView(models.MessageView) error
+	GetMessageViews(msgID uuid.UUID, from, to time.Time) ([]models.MessageView, error)
+	RecordFailedPassphraseAttempt(rid string, maxAttempts int, lockedUntil time.Time) error
+	ResetPassphraseAttempts(rid string) error
+	BurnPocketMessage(rid dto.PocketMessageWithRandomID) error
+	DeleteExpiredPocketMessages(now time.Time) (int64, error)
+	GetPocketMessageByUUID(msgID uuid.UUID) (models.PocketMessage, error)
+	GetPocketMessagesAfter(afterID uint, limit int) ([]models.PocketMessage, error)
 	UpdatePocketMessage(newMsg models.PocketMessage) error
 	DeletePocketMessage(msgID uuid.UUID) error
-	GetPocketMessageByUserUUID(uuid uuid.UUID) ([]dto.OwnedMessage, error)
+	GetTrashedPocketMessages(userUUID uuid.UUID) ([]models.PocketMessage, error)
+	GetTrashedPocketMessage(msgID uuid.UUID) (models.PocketMessage, error)
+	RestorePocketMessage(msgID uuid.UUID) error
+	PurgePocketMessage(msgID uuid.UUID) error
+	PurgeTrashedPocketMessages(before time.Time) (int64, error)
+	GetPocketMessageRevisions(msgID uuid.UUID) ([]models.PocketMessageRevision, error)
+	GetPocketMessageRevision(msgID uuid.UUID, n int) (models.PocketMessageRevision, error)
+	GetPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) ([]dto.OwnedMessage, error)
+	CountPocketMessageByUserUUID(uuid uuid.UUID, q dto.OwnedMessageQuery) (int64, error)
 }
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"pocket-message/models"
+	"pocket-message/pkg/search"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FullTextIndex is a search.SearchIndex over a MySQL FULLTEXT indexed table.
+type FullTextIndex struct {
+	DB *gorm.DB
+	// Key hashes the indexed terms.
+	Key []byte
+}
+
+func NewFullTextIndex(db *gorm.DB, key []byte) search.SearchIndex {
+	return &FullTextIndex{DB: db, Key: key}
+}
+
+func (idx FullTextIndex) Index(doc search.Document) error {
+	row := models.MessageSearch{
+		PocketMessageUUID: doc.ID,
+		UserUUID:          doc.Owner,
+		TitleTerms:        idx.terms(doc.Title),
+		ContentTerms:      idx.terms(doc.Content),
+	}
+	return idx.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
+func (idx FullTextIndex) Remove(id uuid.UUID) error {
+	return idx.DB.Where("pocket_message_uuid = ?", id).Delete(&models.MessageSearch{}).Error
+}
+func (idx FullTextIndex) Search(owner uuid.UUID, query string, limit int) ([]search.Hit, error) {
+	terms := idx.terms(query)
+	var rows []struct {
+		PocketMessageUUID uuid.UUID
+		Score             float64
+	}
+	err := idx.DB.Model(&models.MessageSearch{}).
+		Select("pocket_message_uuid, MATCH(title_terms) AGAINST(?) * ? + MATCH(content_terms) AGAINST(?) AS score", terms, search.TitleWeight, terms).
+		Where("user_uuid = ?", owner).
+		Having("score > 0").
+		Order("score DESC").
+		Order("pocket_message_uuid").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]search.Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = search.Hit{ID: row.PocketMessageUUID, Score: row.Score}
+	}
+	return hits, nil
+}
+
+func (idx FullTextIndex) terms(text string) string {
+	return strings.Join(search.HashTerms(idx.Key, search.Terms(text)), " ")
+}
@@ -4,37 +4,92 @@ import (
 	"pocket-message/configs"
 	"pocket-message/controllers"
 	mid "pocket-message/middleware"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/notifier"
+	"pocket-message/pkg/search"
+	"pocket-message/pkg/validate"
 	"pocket-message/repositories"
 	"pocket-message/services"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"gorm.io/gorm"
 )
 
-func Init(db *gorm.DB) *echo.Echo {
+// Init builds the API over repo, the storage chosen by cfg.Database.Driver.
+func Init(cfg *configs.Config, repo repositories.Database, index search.SearchIndex) (*echo.Echo, error) {
+	ks, err := cfg.KeySet()
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := cfg.Hasher()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := cfg.UsernamePolicy()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := cfg.RandomIDs()
+	if err != nil {
+		return nil, err
+	}
+
 	e := echo.New()
+	e.HTTPErrorHandler = mid.ErrorHandler
+	e.Validator = validate.Validator{}
 
 	e.Pre(middleware.RemoveTrailingSlash())
 	mid.LogMiddleware(e)
+	tokens := mid.NewTokens(ks, cfg.Auth.AccessTokenTTL)
 
-	repo := repositories.NewGorm(db)
-	userServ := services.NewUserServices(repo)
-	pmServ := services.NewPocketMessageServices(repo)
+	userServ := services.NewUserServices(repo, hasher, notifier.New(cfg.Notifier.File), policy, tokens, services.UserSettings{
+		RefreshTokenTTL:       cfg.Auth.RefreshTokenTTL,
+		PasswordResetTokenTTL: cfg.Auth.PasswordResetTokenTTL,
+	})
+	pmServ := services.NewPocketMessageServices(repo, authz.OwnerPolicy{}, ids, index, services.MessageSettings{
+		ViewIPSalt:             []byte(cfg.Messages.ViewIPSalt),
+		TrashRetention:         cfg.Messages.TrashRetention,
+		PassphraseMaxAttempts:  cfg.Messages.PassphraseMaxAttempts,
+		PassphraseLockDuration: cfg.Messages.PassphraseLockDuration,
+		RandomIDMaxAttempts:    cfg.Messages.RandomIDMaxAttempts,
+	})
 	uHandler := controllers.NewUserHandler(userServ)
 	pmHandler := controllers.NewPocketMessageHandler(pmServ)
+	keyHandler := controllers.NewKeyHandler(ks)
+
+	// Access tokens only work while their session is active, so logging out
+	// takes effect before the token expires.
+	auth := []echo.MiddlewareFunc{tokens.JWT(), mid.ActiveSession(repo), tokens.Principal()}
+
+	e.GET("/.well-known/jwks.json", keyHandler.JWKS)                                                        // host:port/.well-known/jwks.json
+	api := e.Group("/api")                                                                                  // host:port/api/...
+	v1 := api.Group("/v1")                                                                                  // host:port/api/v1/...
+	v1.POST("/signup", uHandler.SignUp)                                                                     // host:port/api/v1/signup
+	v1.POST("/login", uHandler.Login)                                                                       // host:port/api/v1/login
+	v1.POST("/token/refresh", uHandler.RefreshToken)                                                        // host:port/api/v1/token/refresh
+	v1.POST("/logout", uHandler.Logout, auth...)                                                            // host:port/api/v1/logout
+	v1.POST("/logout/all", uHandler.LogoutAll, auth...)                                                     // host:port/api/v1/logout/all
+	v1.POST("/users/reset-password/request", uHandler.RequestPasswordReset)                                 // host:port/api/v1/users/reset-password/request
+	v1.POST("/users/reset-password/confirm", uHandler.ResetPassword)                                        // host:port/api/v1/users/reset-password/confirm
+	v1.PUT("/users/change-password", uHandler.ChangePassword, auth...)                                      // host:port/api/v1/users/change-password
+	v1.PUT("/users/change-username", uHandler.UpdateUsername, auth...)                                      // host:port/api/v1/users/change-username
+	v1.POST("/pocket-messages", pmHandler.NewPocketMessage, auth...)                                        // host:port/api/v1/pocket-messages
+	v1.GET("/msg/:random_id", pmHandler.GetPocketMessageByRandomID)                                         // host:port/api/v1/msg/:random_id
+	v1.POST("/msg/:random_id", pmHandler.GetPocketMessageByRandomID)                                        // host:port/api/v1/msg/:random_id, sends the passphrase of the page
+	v1.PUT("/pocket-messages/:uuid", pmHandler.UpdatePocketMessage, auth...)                                // host:port/api/v1/pocket-messages/:uuid
+	v1.DELETE("/pocket-messages/:uuid", pmHandler.DeletePocketMessage, auth...)                             // host:port/api/v1/pocket-messages/:uuid
+	v1.GET("/pocket-messages", pmHandler.GetOwnedPocketMessage, auth...)                                    // host:port/api/v1/pocket-messages
+	v1.GET("/pocket-messages/search", pmHandler.SearchPocketMessages, auth...)                              // host:port/api/v1/pocket-messages/search
+	v1.POST("/pocket-messages/:uuid/links", pmHandler.CreateShareLink, auth...)                             // host:port/api/v1/pocket-messages/:uuid/links
+	v1.GET("/pocket-messages/:uuid/links", pmHandler.GetShareLinks, auth...)                                // host:port/api/v1/pocket-messages/:uuid/links
+	v1.DELETE("/pocket-messages/:uuid/links/:random_id", pmHandler.RevokeShareLink, auth...)                // host:port/api/v1/pocket-messages/:uuid/links/:random_id
+	v1.GET("/pocket-messages/:uuid/stats", pmHandler.GetPocketMessageStats, auth...)                        // host:port/api/v1/pocket-messages/:uuid/stats
+	v1.GET("/pocket-messages/:uuid/revisions", pmHandler.GetPocketMessageRevisions, auth...)                // host:port/api/v1/pocket-messages/:uuid/revisions
+	v1.GET("/pocket-messages/:uuid/revisions/diff", pmHandler.DiffPocketMessageRevisions, auth...)          // host:port/api/v1/pocket-messages/:uuid/revisions/diff
+	v1.POST("/pocket-messages/:uuid/revisions/:n/restore", pmHandler.RestorePocketMessageRevision, auth...) // host:port/api/v1/pocket-messages/:uuid/revisions/:n/restore
+	v1.GET("/pocket-messages/trash", pmHandler.GetTrashedPocketMessages, auth...)                           // host:port/api/v1/pocket-messages/trash
+	v1.POST("/pocket-messages/trash/:uuid/restore", pmHandler.RestorePocketMessage, auth...)                // host:port/api/v1/pocket-messages/trash/:uuid/restore
+	v1.DELETE("/pocket-messages/trash/:uuid", pmHandler.PurgePocketMessage, auth...)                        // host:port/api/v1/pocket-messages/trash/:uuid
 
-	api := e.Group("/api")                                                                                          // host:port/api/...
-	v1 := api.Group("/v1")                                                                                          // host:port/api/v1/...
-	v1.POST("/signup", uHandler.SignUp)                                                                             // host:port/api/v1/signup
-	v1.POST("/login", uHandler.Login)                                                                               // host:port/api/v1/login
-	v1.PUT("/users/reset-password", uHandler.UpdatePassword)                                                        // host:port/api/v1/users/reset-password
-	v1.PUT("/users/change-username", uHandler.UpdateUsername, middleware.JWT([]byte(configs.TokenSecret)))          // host:port/api/v1/users/change-username
-	v1.POST("/pocket-messages", pmHandler.NewPocketMessage, middleware.JWT([]byte(configs.TokenSecret)))            // host:port/api/v1/pocket-messages
-	v1.GET("/msg/:random_id", pmHandler.GetPocketMessageByRandomID)                                                 // host:port/api/v1/msg/:random_id
-	v1.PUT("/pocket-messages/:uuid", pmHandler.UpdatePocketMessage, middleware.JWT([]byte(configs.TokenSecret)))    // host:port/api/v1/pocket-messages/:uuid
-	v1.DELETE("/pocket-messages/:uuid", pmHandler.DeletePocketMessage, middleware.JWT([]byte(configs.TokenSecret))) // host:port/api/v1/pocket-messages/:uuid
-	v1.GET("/pocket-messages", pmHandler.GetOwnedPocketMessage, middleware.JWT([]byte(configs.TokenSecret)))        // host:port/api/v1/pocket-messages
-
-	return e
+	return e, nil
 }
@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pocket-message/configs"
+	"pocket-message/dto"
+	"pocket-message/pkg/search"
+	"pocket-message/repositories"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+// RoutesSuite drives the whole API over HTTP, with the in-memory database.
+type RoutesSuite struct {
+	suite.Suite
+	e *echo.Echo
+}
+
+func TestSuiteRoutes(t *testing.T) {
+	suite.Run(t, new(RoutesSuite))
+}
+
+func (s *RoutesSuite) SetupSuite() {}
+
+func (s *RoutesSuite) TearDownSuite() {}
+
+// SetupTest starts every test with an empty database.
+func (s *RoutesSuite) SetupTest() {
+	s.serve(repositories.NewMemory())
+}
+
+// serve builds the API over repo.
+func (s *RoutesSuite) serve(repo repositories.Database) {
+	cfg := configs.Default()
+	cfg.Database.Driver = "memory"
+	cfg.Search.Index = "memory"
+	cfg.Auth.TokenSecret = "rahasia"
+	cfg.Messages.ViewIPSalt = "garam"
+	cfg.Password.Cost = 4
+	s.Require().NoError(cfg.Validate())
+
+	e, err := Init(&cfg, repo, search.NewMemory())
+	s.Require().NoError(err)
+	s.e = e
+}
+
+// do sends body as JSON, with token as the bearer token when it is set, and
+// decodes the JSON response.
+func (s *RoutesSuite) do(method, path, token string, body interface{}) (int, map[string]interface{}) {
+	var payload bytes.Buffer
+	if body != nil {
+		s.Require().NoError(json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+
+	var res map[string]interface{}
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res), rec.Body.String())
+	return rec.Code, res
+}
+
+// login signs a user up and returns its access token.
+func (s *RoutesSuite) login(username string) string {
+	credentials := echo.Map{"username": username, "password": "rahasia123"}
+	code, res := s.do(http.MethodPost, "/api/v1/signup", "", credentials)
+	s.Require().Equal(http.StatusCreated, code, res)
+
+	code, res = s.do(http.MethodPost, "/api/v1/login", "", credentials)
+	s.Require().Equal(http.StatusOK, code, res)
+	return res["data"].(map[string]interface{})["token"].(string)
+}
+
+func (s *RoutesSuite) owned(token, query string) []interface{} {
+	code, res := s.do(http.MethodGet, "/api/v1/pocket-messages"+query, token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	data, _ := res["data"].([]interface{})
+	return data
+}
+
+// Users
+func (s *RoutesSuite) TestSignUpLogin() {
+	token := s.login("nobita")
+	s.NotEmpty(token)
+
+	code, res := s.do(http.MethodPost, "/api/v1/signup", "", echo.Map{"username": "Nobita", "password": "rahasia123"})
+	s.Equal(http.StatusConflict, code, res)
+
+	code, res = s.do(http.MethodPost, "/api/v1/login", "", echo.Map{"username": "nobita", "password": "salah"})
+	s.Equal(http.StatusUnauthorized, code, res)
+}
+func (s *RoutesSuite) TestLogout() {
+	token := s.login("nobita")
+	s.Empty(s.owned(token, ""))
+
+	code, res := s.do(http.MethodPost, "/api/v1/logout", token, nil)
+	s.Equal(http.StatusOK, code, res)
+
+	code, res = s.do(http.MethodGet, "/api/v1/pocket-messages", token, nil)
+	s.Equal(http.StatusUnauthorized, code, res)
+}
+func (s *RoutesSuite) TestUnauthenticated() {
+	code, res := s.do(http.MethodPost, "/api/v1/pocket-messages", "", echo.Map{"title": "judul", "content": "isi"})
+	s.Equal(http.StatusBadRequest, code, res)
+	code, res = s.do(http.MethodPost, "/api/v1/pocket-messages", "bukan.token.jwt", echo.Map{"title": "judul", "content": "isi"})
+	s.Equal(http.StatusUnauthorized, code, res)
+}
+
+// Pocket messages
+func (s *RoutesSuite) TestShareAndRead() {
+	token := s.login("nobita")
+	code, res := s.do(http.MethodPost, "/api/v1/pocket-messages", token, echo.Map{
+		"title": "rahasia", "content": "kita berdua", "slug": "rahasia-kita", "max_visit": 1,
+	})
+	s.Require().Equal(http.StatusCreated, code, res)
+
+	code, res = s.do(http.MethodGet, "/api/v1/msg/rahasia-kita", "", nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	data := res["data"].(map[string]interface{})
+	s.Equal("rahasia", data["title"])
+	s.Equal("kita berdua", data["content"])
+
+	// The link was read as many times as it may be.
+	code, res = s.do(http.MethodGet, "/api/v1/msg/rahasia-kita", "", nil)
+	s.Equal(http.StatusGone, code, res)
+	code, res = s.do(http.MethodGet, "/api/v1/msg/tidak-ada", "", nil)
+	s.Equal(http.StatusNotFound, code, res)
+
+	owned := s.owned(token, "")
+	if s.Len(owned, 1) {
+		s.Equal(float64(1), owned[0].(map[string]interface{})["visit"])
+	}
+}
+func (s *RoutesSuite) TestBurnAfterReadConcurrent() {
+	const readers = 10
+	repo := &readBarrier{Database: repositories.NewMemory()}
+	repo.wg.Add(readers)
+	s.serve(repo)
+
+	token := s.login("nobita")
+	code, res := s.do(http.MethodPost, "/api/v1/pocket-messages", token, echo.Map{
+		"title": "sekali", "content": "baca lalu bakar", "slug": "sekali-baca", "burn_after_read": true,
+	})
+	s.Require().Equal(http.StatusCreated, code, res)
+
+	codes := make([]int, readers)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/msg/sekali-baca", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			codes[i] = rec.Code
+		}(i)
+	}
+	wg.Wait()
+
+	// Every reader found the message, but only one of them gets it.
+	read := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			read++
+			continue
+		}
+		s.Contains([]int{http.StatusNotFound, http.StatusGone}, code)
+	}
+	s.Equal(1, read)
+}
+
+func (s *RoutesSuite) TestBrowserOpenDoesNotBurn() {
+	token := s.login("nobita")
+	code, res := s.do(http.MethodPost, "/api/v1/pocket-messages", token, echo.Map{
+		"title": "sekali", "content": "baca lalu bakar", "slug": "sekali-baca", "burn_after_read": true,
+	})
+	s.Require().Equal(http.StatusCreated, code, res)
+
+	browse := func(method string) (int, string) {
+		req := httptest.NewRequest(method, "/api/v1/msg/sekali-baca", nil)
+		req.Header.Set(echo.HeaderAccept, "text/html,application/xhtml+xml,*/*;q=0.8")
+		rec := httptest.NewRecorder()
+		s.e.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	// Link previews open the link as many times as they like.
+	for i := 0; i < 2; i++ {
+		code, body := browse(http.MethodGet)
+		s.Equal(http.StatusOK, code)
+		s.Contains(body, "Open message")
+		s.NotContains(body, "baca lalu bakar")
+	}
+
+	code, body := browse(http.MethodPost)
+	s.Equal(http.StatusOK, code)
+	s.Contains(body, "baca lalu bakar")
+
+	code, body = browse(http.MethodPost)
+	s.Equal(http.StatusNotFound, code)
+	s.NotContains(body, "baca lalu bakar")
+}
+
+// readBarrier holds every reader of a random id until all of them have read
+// it, so they race to burn the message.
+type readBarrier struct {
+	repositories.Database
+	wg sync.WaitGroup
+}
+
+func (r *readBarrier) GetPocketMessageByRandomID(rid string) (dto.PocketMessageWithRandomID, error) {
+	pm, err := r.Database.GetPocketMessageByRandomID(rid)
+	r.wg.Done()
+	r.wg.Wait()
+	return pm, err
+}
+
+func (s *RoutesSuite) TestOtherUsersMessages() {
+	owner := s.login("nobita")
+	s.do(http.MethodPost, "/api/v1/pocket-messages", owner, echo.Map{"title": "punyaku", "content": "isi"})
+	id := s.owned(owner, "")[0].(map[string]interface{})["uuid"].(string)
+
+	other := s.login("giant")
+	s.Empty(s.owned(other, ""))
+	code, res := s.do(http.MethodPut, "/api/v1/pocket-messages/"+id, other, echo.Map{"title": "punyaku", "content": "direbut"})
+	s.Equal(http.StatusForbidden, code, res)
+}
+func (s *RoutesSuite) TestEditTrashRestore() {
+	token := s.login("nobita")
+	for _, title := range []string{"pertama", "kedua"} {
+		code, res := s.do(http.MethodPost, "/api/v1/pocket-messages", token, echo.Map{"title": title, "content": "isi " + title})
+		s.Require().Equal(http.StatusCreated, code, res)
+	}
+	owned := s.owned(token, "?sort=created&order=asc")
+	s.Require().Len(owned, 2)
+	id := owned[0].(map[string]interface{})["uuid"].(string)
+
+	code, res := s.do(http.MethodPut, "/api/v1/pocket-messages/"+id, token, echo.Map{"title": "pertama", "content": "diubah"})
+	s.Require().Equal(http.StatusOK, code, res)
+	code, res = s.do(http.MethodGet, "/api/v1/pocket-messages/"+id+"/revisions", token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	s.Len(res["data"], 2)
+
+	code, res = s.do(http.MethodGet, "/api/v1/pocket-messages/search?q=diubah", token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	s.Len(res["data"], 1)
+
+	code, res = s.do(http.MethodDelete, "/api/v1/pocket-messages/"+id, token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	s.Len(s.owned(token, ""), 1)
+	code, res = s.do(http.MethodGet, "/api/v1/pocket-messages/trash", token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	s.Len(res["data"], 1)
+
+	code, res = s.do(http.MethodPost, "/api/v1/pocket-messages/trash/"+id+"/restore", token, nil)
+	s.Require().Equal(http.StatusOK, code, res)
+	s.Len(s.owned(token, ""), 2)
+}
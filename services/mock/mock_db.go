@@ -0,0 +1,167 @@
+package services
+
+import (
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/repositories"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MockDB is a Database, usually a seeded repositories.NewMemory(), whose
+// methods named in Errors fail with the given error instead of running.
+type MockDB struct {
+	repositories.Database
+	Errors map[string]error
+}
+
+// User
+func (db *MockDB) SaveNewUser(u models.User) error {
+	if err := db.Errors["SaveNewUser"]; err != nil {
+		return err
+	}
+	return db.Database.SaveNewUser(u)
+}
+func (db *MockDB) GetUserByUsername(username string) (models.User, error) {
+	if err := db.Errors["GetUserByUsername"]; err != nil {
+		return models.User{}, err
+	}
+	return db.Database.GetUserByUsername(username)
+}
+func (db *MockDB) UpdateUsername(u models.User) error {
+	if err := db.Errors["UpdateUsername"]; err != nil {
+		return err
+	}
+	return db.Database.UpdateUsername(u)
+}
+func (db *MockDB) UpdatePassword(u models.User) error {
+	if err := db.Errors["UpdatePassword"]; err != nil {
+		return err
+	}
+	return db.Database.UpdatePassword(u)
+}
+func (db *MockDB) SavePasswordResetToken(token models.PasswordResetToken) error {
+	if err := db.Errors["SavePasswordResetToken"]; err != nil {
+		return err
+	}
+	return db.Database.SavePasswordResetToken(token)
+}
+
+// Session
+func (db *MockDB) RotateSession(id uuid.UUID, next models.Session, now time.Time) error {
+	if err := db.Errors["RotateSession"]; err != nil {
+		return err
+	}
+	return db.Database.RotateSession(id, next, now)
+}
+func (db *MockDB) RevokeSession(id uuid.UUID, now time.Time) error {
+	if err := db.Errors["RevokeSession"]; err != nil {
+		return err
+	}
+	return db.Database.RevokeSession(id, now)
+}
+func (db *MockDB) RevokeUserSessions(userUUID uuid.UUID, now time.Time) error {
+	if err := db.Errors["RevokeUserSessions"]; err != nil {
+		return err
+	}
+	return db.Database.RevokeUserSessions(userUUID, now)
+}
+
+// PocketMessage
+func (db *MockDB) SaveNewPocketMessageWithRandomID(pm models.PocketMessage, rid models.PocketMessageRandomID) error {
+	if err := db.Errors["SaveNewPocketMessageWithRandomID"]; err != nil {
+		return err
+	}
+	return db.Database.SaveNewPocketMessageWithRandomID(pm, rid)
+}
+func (db *MockDB) GetRandomIDsByPocketMessageUUID(msgID uuid.UUID) ([]models.PocketMessageRandomID, error) {
+	if err := db.Errors["GetRandomIDsByPocketMessageUUID"]; err != nil {
+		return nil, err
+	}
+	return db.Database.GetRandomIDsByPocketMessageUUID(msgID)
+}
+func (db *MockDB) DeleteRandomID(msgID uuid.UUID, rid string) error {
+	if err := db.Errors["DeleteRandomID"]; err != nil {
+		return err
+	}
+	return db.Database.DeleteRandomID(msgID, rid)
+}
+func (db *MockDB) GetPocketMessageByRandomID(rid string) (dto.PocketMessageWithRandomID, error) {
+	if err := db.Errors["GetPocketMessageByRandomID"]; err != nil {
+		return dto.PocketMessageWithRandomID{}, err
+	}
+	return db.Database.GetPocketMessageByRandomID(rid)
+}
+func (db *MockDB) UpdateVisitCount(rid dto.PocketMessageWithRandomID) error {
+	if err := db.Errors["UpdateVisitCount"]; err != nil {
+		return err
+	}
+	return db.Database.UpdateVisitCount(rid)
+}
+func (db *MockDB) GetMessageViews(msgID uuid.UUID, from, to time.Time) ([]models.MessageView, error) {
+	if err := db.Errors["GetMessageViews"]; err != nil {
+		return nil, err
+	}
+	return db.Database.GetMessageViews(msgID, from, to)
+}
+func (db *MockDB) BurnPocketMessage(rid dto.PocketMessageWithRandomID) error {
+	if err := db.Errors["BurnPocketMessage"]; err != nil {
+		return err
+	}
+	return db.Database.BurnPocketMessage(rid)
+}
+func (db *MockDB) UpdatePocketMessage(newMsg models.PocketMessage) error {
+	if err := db.Errors["UpdatePocketMessage"]; err != nil {
+		return err
+	}
+	return db.Database.UpdatePocketMessage(newMsg)
+}
+func (db *MockDB) DeletePocketMessage(msgID uuid.UUID) error {
+	if err := db.Errors["DeletePocketMessage"]; err != nil {
+		return err
+	}
+	return db.Database.DeletePocketMessage(msgID)
+}
+func (db *MockDB) GetTrashedPocketMessages(userUUID uuid.UUID) ([]models.PocketMessage, error) {
+	if err := db.Errors["GetTrashedPocketMessages"]; err != nil {
+		return nil, err
+	}
+	return db.Database.GetTrashedPocketMessages(userUUID)
+}
+func (db *MockDB) RestorePocketMessage(msgID uuid.UUID) error {
+	if err := db.Errors["RestorePocketMessage"]; err != nil {
+		return err
+	}
+	return db.Database.RestorePocketMessage(msgID)
+}
+func (db *MockDB) PurgePocketMessage(msgID uuid.UUID) error {
+	if err := db.Errors["PurgePocketMessage"]; err != nil {
+		return err
+	}
+	return db.Database.PurgePocketMessage(msgID)
+}
+func (db *MockDB) GetPocketMessageRevisions(msgID uuid.UUID) ([]models.PocketMessageRevision, error) {
+	if err := db.Errors["GetPocketMessageRevisions"]; err != nil {
+		return nil, err
+	}
+	return db.Database.GetPocketMessageRevisions(msgID)
+}
+func (db *MockDB) GetPocketMessageRevision(msgID uuid.UUID, n int) (models.PocketMessageRevision, error) {
+	if err := db.Errors["GetPocketMessageRevision"]; err != nil {
+		return models.PocketMessageRevision{}, err
+	}
+	return db.Database.GetPocketMessageRevision(msgID, n)
+}
+func (db *MockDB) GetPocketMessageByUserUUID(id uuid.UUID, q dto.OwnedMessageQuery) ([]dto.OwnedMessage, error) {
+	if err := db.Errors["GetPocketMessageByUserUUID"]; err != nil {
+		return nil, err
+	}
+	return db.Database.GetPocketMessageByUserUUID(id, q)
+}
+func (db *MockDB) CountPocketMessageByUserUUID(id uuid.UUID, q dto.OwnedMessageQuery) (int64, error) {
+	if err := db.Errors["CountPocketMessageByUserUUID"]; err != nil {
+		return 0, err
+	}
+	return db.Database.CountPocketMessageByUserUUID(id, q)
+}
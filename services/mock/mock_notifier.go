@@ -0,0 +1,18 @@
+package services
+
+// MockNotifier remembers the last notification it was asked to send, or
+// fails with Err when it is set.
+type MockNotifier struct {
+	Recipient string
+	Subject   string
+	Body      string
+	Err       error
+}
+
+func (n *MockNotifier) Notify(recipient, subject, body string) error {
+	if n.Err != nil {
+		return n.Err
+	}
+	n.Recipient, n.Subject, n.Body = recipient, subject, body
+	return nil
+}
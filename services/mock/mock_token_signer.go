@@ -0,0 +1,12 @@
+package services
+
+import (
+	"github.com/google/uuid"
+)
+
+// MockTokenSigner issues tokens naming the session they belong to.
+type MockTokenSigner struct{}
+
+func (MockTokenSigner) SignSessionToken(userUUID uuid.UUID, username string, sessionID uuid.UUID) (string, error) {
+	return "token-" + sessionID.String(), nil
+}
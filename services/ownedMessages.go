@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"pocket-message/dto"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// OwnedMessagesDefaultLimit is the page size when no limit is given.
+	OwnedMessagesDefaultLimit = 20
+	// OwnedMessagesMaxLimit is the largest page a client can ask for.
+	OwnedMessagesMaxLimit = 100
+)
+
+// GetUserPocketMessage returns a page of the caller's pocket messages. The
+// next page is requested with the returned cursor and the same query.
+func (s *pmServices) GetUserPocketMessage(ctx context.Context, req dto.ListOwnedMessages) (dto.OwnedMessagePage, error) {
+	p, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return dto.OwnedMessagePage{}, err
+	}
+
+	q, err := ownedMessageQuery(req)
+	if err != nil {
+		return dto.OwnedMessagePage{}, err
+	}
+
+	result, err := s.Database.GetPocketMessageByUserUUID(p.UUID, q)
+	if err != nil {
+		return dto.OwnedMessagePage{}, err
+	}
+	total, err := s.ownedMessageTotal(p.UUID, q)
+	if err != nil {
+		return dto.OwnedMessagePage{}, err
+	}
+
+	page := dto.OwnedMessagePage{Messages: result, Total: total}
+	if len(result) > q.Limit {
+		page.Messages = result[:q.Limit]
+		next := page.Messages[q.Limit-1].CursorOf(q.Sort, q.Desc)
+		if q.Title != "" {
+			next.Total = &total
+		}
+		page.NextCursor = encodeCursor(next)
+	}
+	if page.Messages == nil {
+		page.Messages = []dto.OwnedMessage{}
+	}
+	return page, nil
+}
+
+// ownedMessageTotal counts the messages q selects. A title filter is
+// applied to decrypted titles, so its total is only counted on the first
+// page; the next ones take it from the cursor.
+func (s *pmServices) ownedMessageTotal(userUUID uuid.UUID, q dto.OwnedMessageQuery) (int64, error) {
+	if q.Title != "" && q.After != nil && q.After.Total != nil {
+		return *q.After.Total, nil
+	}
+	return s.Database.CountPocketMessageByUserUUID(userUUID, q)
+}
+
+// ownedMessageQuery validates a listing request. Messages are sorted by
+// creation time, newest first, unless sort and order say otherwise.
+func ownedMessageQuery(req dto.ListOwnedMessages) (dto.OwnedMessageQuery, error) {
+	q := dto.OwnedMessageQuery{
+		Sort:        dto.SortCreated,
+		Desc:        true,
+		Limit:       OwnedMessagesDefaultLimit,
+		Title:       req.Title,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		Status:      req.Status,
+	}
+
+	if req.Limit != 0 {
+		if req.Limit < 1 || req.Limit > OwnedMessagesMaxLimit {
+			return dto.OwnedMessageQuery{}, apperr.Validation(fmt.Sprintf("error, limit should be between 1 and %d", OwnedMessagesMaxLimit))
+		}
+		q.Limit = req.Limit
+	}
+
+	switch req.Sort {
+	case "":
+	case dto.SortCreated, dto.SortUpdated, dto.SortVisits:
+		q.Sort = req.Sort
+	default:
+		return dto.OwnedMessageQuery{}, apperr.Validation("error, sort should be created, updated or visits")
+	}
+
+	switch req.Order {
+	case "", "desc":
+	case "asc":
+		q.Desc = false
+	default:
+		return dto.OwnedMessageQuery{}, apperr.Validation("error, order should be asc or desc")
+	}
+
+	switch req.Status {
+	case "", dto.StatusActive, dto.StatusExpired, dto.StatusRevoked:
+	default:
+		return dto.OwnedMessageQuery{}, apperr.Validation("error, status should be active, expired or revoked")
+	}
+
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return dto.OwnedMessageQuery{}, apperr.Validation("error, created_from should be before created_to")
+	}
+
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor)
+		if err != nil || after.Sort != q.Sort || after.Desc != q.Desc {
+			return dto.OwnedMessageQuery{}, apperr.Validation("error, cursor invalid")
+		}
+		q.After = &after
+	}
+	return q, nil
+}
+
+func encodeCursor(cursor dto.OwnedMessageCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (dto.OwnedMessageCursor, error) {
+	var cursor dto.OwnedMessageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(b, &cursor)
+	return cursor, err
+}
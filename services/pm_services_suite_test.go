@@ -2,34 +2,125 @@ package services
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"net/http"
-	"net/http/httptest"
+	"io"
 	"pocket-message/dto"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/diff"
+	"pocket-message/pkg/e2e"
+	"pocket-message/pkg/randomid"
+	"pocket-message/pkg/search"
+	"pocket-message/repositories"
 	m "pocket-message/services/mock"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type PocketMessageSuite struct {
 	suite.Suite
+	repo    repositories.Database
+	db      *m.MockDB
 	service PocketMessageServices
+	index   *search.Memory
 }
 
+// The pocket messages seeded for every test. All but the ones of vtuberID
+// belong to uuid.Nil, the caller of most tests.
+var (
+	messageID   = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	burnID      = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	limitedID   = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	protectedID = uuid.MustParse("00000000-0000-0000-0000-000000000004")
+	trashedID   = uuid.MustParse("00000000-0000-0000-0000-000000000701")
+	donationID  = uuid.MustParse("00000000-0000-0000-0000-000000000102")
+	memberID    = uuid.MustParse("00000000-0000-0000-0000-000000000101")
+	vtuberID    = uuid.MustParse("00000000-0000-0000-0000-000000000100")
+	strangerID  = uuid.MustParse("00000000-0000-0000-0000-000000000008")
+)
+
+// viewsFrom is the start of the views of messageID: two views of
+// "asdfghjkl" by one reader on its first day and one of "qwertyuio" on its
+// third day.
+var viewsFrom = time.Date(2022, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+// passphraseHash is the bcrypt hash of "open sesame" used by the
+// "qwertyuio", "protected" and "locked" random ids.
+var passphraseHash = func() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("open sesame"), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(hash)
+}()
+
 func TestSuitePocketMessage(t *testing.T) {
 	suite.Run(t, new(PocketMessageSuite))
 }
 
-func (s *PocketMessageSuite) SetupSuite() {
-	service := NewPocketMessageServices(&m.MockGorm{})
-	s.service = service
+func (s *PocketMessageSuite) SetupSuite() {}
+
+func (s *PocketMessageSuite) SetupTest() {
+	s.repo = repositories.NewMemory()
+	s.db = &m.MockDB{Database: s.repo}
+	s.index = search.NewMemory()
+	s.service = NewPocketMessageServices(s.db, authz.OwnerPolicy{}, randomid.Default(), s.index, settings)
+
+	// messageID has three revisions.
+	s.save(models.PocketMessage{UUID: messageID, Title: "halo dunia", Content: "halo kamu"},
+		models.PocketMessageRandomID{RandomID: "asdfghjkl", Label: "family", Visit: 2},
+		models.PocketMessageRandomID{RandomID: "qwertyuio", Label: "friends", MaxVisit: 5, PassphraseHash: passphraseHash},
+	)
+	s.Require().NoError(s.repo.UpdatePocketMessage(models.PocketMessage{UUID: messageID, Title: "halo dunia", Content: "halo\nkamu", Format: models.FormatPlain}))
+	s.Require().NoError(s.repo.UpdatePocketMessage(models.PocketMessage{UUID: messageID, Title: "halo bumi", Content: "halo\nkamu", Format: models.FormatMarkdown}))
+	for _, view := range []models.MessageView{
+		{RandomID: "asdfghjkl", IPHash: "a", ViewedAt: viewsFrom.Add(time.Hour)},
+		{RandomID: "asdfghjkl", IPHash: "a", ViewedAt: viewsFrom.Add(2 * time.Hour)},
+		{RandomID: "qwertyuio", IPHash: "b", ViewedAt: viewsFrom.Add(50 * time.Hour)},
+	} {
+		view.PocketMessageUUID = messageID
+		s.Require().NoError(s.repo.SaveMessageView(view))
+	}
+
+	expiredAt := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lockedUntil := time.Now().Add(time.Hour)
+	s.save(models.PocketMessage{UUID: burnID, Title: "one time", Content: "sekali baca", BurnAfterRead: true},
+		models.PocketMessageRandomID{RandomID: "secret"},
+	)
+	s.save(models.PocketMessage{UUID: limitedID, Title: "terbatas", Content: "sebentar"},
+		models.PocketMessageRandomID{RandomID: "expired", ExpiredAt: &expiredAt},
+		models.PocketMessageRandomID{RandomID: "exhausted", Visit: 5, MaxVisit: 5},
+		models.PocketMessageRandomID{RandomID: "last-visit", Visit: 4, MaxVisit: 5},
+	)
+	s.save(models.PocketMessage{UUID: protectedID, Title: "rahasia", Content: "sst"},
+		models.PocketMessageRandomID{RandomID: "protected", PassphraseHash: passphraseHash},
+		models.PocketMessageRandomID{RandomID: "locked", PassphraseHash: passphraseHash, LockedUntil: &lockedUntil},
+	)
+	s.save(models.PocketMessage{UUID: trashedID, Title: "halo dunia", Content: "halo kamu"})
+	s.Require().NoError(s.repo.DeletePocketMessage(trashedID))
+
+	s.save(models.PocketMessage{UUID: memberID, UserUUID: vtuberID, Title: "vtuber", Content: "membership", Model: gorm.Model{CreatedAt: viewsFrom, UpdatedAt: viewsFrom}},
+		models.PocketMessageRandomID{RandomID: "superchat", Visit: 10},
+	)
+	s.save(models.PocketMessage{UUID: donationID, UserUUID: vtuberID, Title: "vtuber", Content: "donation", Model: gorm.Model{CreatedAt: viewsFrom.Add(time.Hour), UpdatedAt: viewsFrom.Add(time.Hour)}},
+		models.PocketMessageRandomID{RandomID: "akasupas", Visit: 1000},
+	)
+}
+
+// save stores pm with the given random ids.
+func (s *PocketMessageSuite) save(pm models.PocketMessage, rids ...models.PocketMessageRandomID) {
+	s.Require().NoError(s.repo.SaveNewPocketMessage(pm))
+	for _, rid := range rids {
+		rid.PocketMessageUUID = pm.UUID
+		s.Require().NoError(s.repo.SaveNewRandomID(rid))
+	}
 }
 
 func (s *PocketMessageSuite) TearDownSuite() {}
@@ -38,744 +129,1462 @@ func (s *PocketMessageSuite) TearDownSuite() {}
 func (s *PocketMessageSuite) TestNewPocketMessage() {
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		body        dto.NewPocketMessage
 		expectError error
 	}{
 		{
 			name: "new_pocket_message-normal",
-			body: models.PocketMessage{
+			body: dto.NewPocketMessage{
 				Title:   "yes",
 				Content: "no",
 			},
-			method:      http.MethodPost,
+			expectError: nil,
+		},
+		{
+			name: "new_pocket_message-markdown",
+			body: dto.NewPocketMessage{
+				Title:   "yes",
+				Content: "**no**",
+				Format:  models.FormatMarkdown,
+			},
 			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.NewPocketMessage(c)
+			err := s.service.NewPocketMessage(callerContext(uuid.Nil), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestNewPocketMessageErrorBind() {
+func (s *PocketMessageSuite) TestNewPocketMessageErrorDB() {
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		body        dto.NewPocketMessage
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "new_pocket_message-error_bind",
-			body: models.PocketMessage{
-				Title:   "abd",
-				Content: "no",
+			name: "new_pocket_message-error_db",
+			body: dto.NewPocketMessage{
+				Title:   "super",
+				Content: "asd",
 			},
-			method:      http.MethodPost,
-			expectError: echo.NewHTTPError(415, "Unsupported Media Type"),
+			dbErrors:    map[string]error{"SaveNewPocketMessageWithRandomID": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.NewPocketMessage(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.NewPocketMessage(callerContext(uuid.Nil), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestNewPocketMessageErrorTitleEmpty() {
+func (s *PocketMessageSuite) TestNewPocketMessageOptions() {
+	later := time.Now().Add(time.Hour)
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		body        dto.NewPocketMessage
 		expectError error
 	}{
 		{
-			name: "new_pocket_message-error_title_empty",
-			body: models.PocketMessage{
-				Title:   "",
-				Content: "no",
+			name: "new_pocket_message-expires_in",
+			body: dto.NewPocketMessage{
+				Title:     "yes",
+				Content:   "no",
+				ExpiresIn: "24h",
+				MaxVisit:  3,
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("error, title should not be empty"),
+			expectError: nil,
+		},
+		{
+			name: "new_pocket_message-error_both_expiries",
+			body: dto.NewPocketMessage{
+				Title:     "yes",
+				Content:   "no",
+				ExpiredAt: &later,
+				ExpiresIn: "24h",
+			},
+			expectError: apperr.Validation("error, set either expired_at or expires_in, not both"),
+		},
+		{
+			name: "new_pocket_message-encrypted",
+			body: dto.NewPocketMessage{
+				Title:     "yes",
+				Content:   `{"v":1,"alg":"A256GCM","nonce":"Ssn2hjGus0oVNMP-","ct":"bquSQGdkkYMOrV8f_QBedXpHTABAAQ"}`,
+				Encrypted: true,
+			},
+			expectError: nil,
+		},
+		{
+			name: "new_pocket_message-error_encrypted_plaintext",
+			body: dto.NewPocketMessage{
+				Title:     "yes",
+				Content:   "no",
+				Encrypted: true,
+			},
+			expectError: apperr.Wrap(apperr.KindValidation, "invalid_envelope", e2e.ErrInvalidEnvelope),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
+			err := s.service.NewPocketMessage(callerContext(uuid.Nil), v.body)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+func (s *PocketMessageSuite) TestNewPocketMessageRandomID() {
+	// A zero byte picks "a" and a one byte picks "b", so "aaaaaaaaaa",
+	// which is taken, comes before "bbbbbbbbbb".
+	zeros := bytes.Repeat([]byte{0}, 10*settings.RandomIDMaxAttempts)
+	ones := bytes.Repeat([]byte{1}, 10)
+	s.save(models.PocketMessage{UUID: uuid.New(), Title: "a", Content: "a"}, models.PocketMessageRandomID{RandomID: "aaaaaaaaaa"})
+
+	testCase := []struct {
+		name        string
+		body        dto.NewPocketMessage
+		rand        io.Reader
+		expectError error
+	}{
+		{
+			name:        "new_pocket_message-slug",
+			body:        dto.NewPocketMessage{Title: "yes", Content: "no", Slug: "my-Birthday"},
+			expectError: nil,
+		},
+		{
+			name:        "new_pocket_message-error_slug_taken",
+			body:        dto.NewPocketMessage{Title: "yes", Content: "no", Slug: "asdfghjkl"},
+			expectError: ErrSlugTaken,
+		},
+		{
+			name:        "new_pocket_message-collision_retried",
+			body:        dto.NewPocketMessage{Title: "yes", Content: "no"},
+			rand:        io.MultiReader(bytes.NewReader(zeros[:10]), bytes.NewReader(ones)),
+			expectError: nil,
+		},
+		{
+			name:        "new_pocket_message-error_collision_exhausted",
+			body:        dto.NewPocketMessage{Title: "yes", Content: "no"},
+			rand:        bytes.NewReader(zeros),
+			expectError: ErrRandomIDExhausted,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			ids := randomid.Default()
+			ids.Rand = v.rand
+			service := NewPocketMessageServices(s.db, authz.OwnerPolicy{}, ids, search.NewMemory(), settings)
 
-			err = s.service.NewPocketMessage(c)
+			err := service.NewPocketMessage(callerContext(uuid.Nil), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	for _, rid := range []string{"my-Birthday", "bbbbbbbbbb"} {
+		_, err := s.repo.GetPocketMessageByRandomID(rid)
+		s.NoError(err, rid)
+	}
 }
-func (s *PocketMessageSuite) TestNewPocketMessageErrorContentEmpty() {
+
+// GetPocketMessageByRandomID
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomID() {
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		randomID    string
+		expectBody  dto.PocketMessageWithRandomID
 		expectError error
 	}{
 		{
-			name: "new_pocket_message-error_content_empty",
-			body: models.PocketMessage{
-				Title:   "asd",
-				Content: "",
+			name:     "get_pocket_message_by_random_id-normal",
+			randomID: "asdfghjkl",
+			expectBody: dto.PocketMessageWithRandomID{
+				UUID:     messageID,
+				Title:    "halo bumi",
+				Content:  "halo\nkamu",
+				Format:   models.FormatMarkdown,
+				RandomID: "asdfghjkl",
+				Visit:    2,
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("error, content should not be empty"),
+			expectError: nil,
+		},
+		{
+			name:        "get_pocket_message_by_random_id-error_not_found",
+			randomID:    "superidol",
+			expectBody:  dto.PocketMessageWithRandomID{},
+			expectError: errNotFound,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
+			result, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
+			s.Equal(v.expectBody, result)
+			s.Equal(v.expectError, err)
+		})
+	}
 
-			err = s.service.NewPocketMessage(c)
+	result, err := s.repo.GetPocketMessageByRandomID("asdfghjkl")
+	s.NoError(err)
+	s.Equal(3, result.Visit)
+}
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorParamRandomIDEmpty() {
+	testCase := []struct {
+		name        string
+		randomID    string
+		expectError error
+	}{
+		{
+			name:        "get_pocket_message_by_random_id-error_param_random_id_empty",
+			randomID:    "",
+			expectError: apperr.Validation("error, random_id parameter can not be empty"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			_, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestNewPocketMessageErrorSavePocketMessage() {
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorRepo() {
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		randomID    string
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "new_pocket_message-error_save_pocket_message",
-			body: models.PocketMessage{
-				Title:   "super",
-				Content: "asd",
-			},
-			method:      http.MethodPost,
+			name:        "get_pocket_message_by_random_id-error_repo",
+			randomID:    "asdfghjkl",
+			dbErrors:    map[string]error{"GetPocketMessageByRandomID": errors.New("database error")},
 			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.NewPocketMessage(c)
+			s.db.Errors = v.dbErrors
+			_, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestNewPocketMessageErrorSaveRandomID() {
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorUpdateVisitCount() {
 	testCase := []struct {
 		name        string
-		body        models.PocketMessage
-		method      string
+		randomID    string
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "new_pocket_message-error_save_random_id",
-			body: models.PocketMessage{
-				Title:   "super",
-				Content: "asd",
-			},
-			method:      http.MethodPost,
+			name:        "get_pocket_message_by_random_id-error_update_visit_count",
+			randomID:    "asdfghjkl",
+			dbErrors:    map[string]error{"UpdateVisitCount": errors.New("database error")},
 			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-			c.Request().Header.Set("test", "true")
-
-			err = s.service.NewPocketMessage(c)
+			s.db.Errors = v.dbErrors
+			_, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
 			s.Equal(v.expectError, err)
 		})
 	}
 }
 
-// GetPocketMessageByRandomID
-func (s *PocketMessageSuite) TestGetPocketMessageByRandomID() {
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDBurnAfterRead() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		randomID    string
 		expectBody  dto.PocketMessageWithRandomID
 		expectError error
 	}{
 		{
-			name:       "get_pocket_message_by_random_id-normal",
-			paramName:  "random_id",
-			paramValue: "asdfghjk",
+			name:     "get_pocket_message_by_random_id-burn_after_read",
+			randomID: "secret",
 			expectBody: dto.PocketMessageWithRandomID{
-				UUID:  uuid.MustParse("00000000-0000-0000-0000-000000000001"),
-				Title: "selsya bahagia",
+				UUID:          burnID,
+				Title:         "one time",
+				Content:       "sekali baca",
+				RandomID:      "secret",
+				BurnAfterRead: true,
 			},
 			expectError: nil,
 		},
+		{
+			name:        "get_pocket_message_by_random_id-error_already_burned",
+			randomID:    "secret",
+			expectBody:  dto.PocketMessageWithRandomID{},
+			expectError: errNotFound,
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			result, err := s.service.GetPocketMessageByRandomID(c)
+			result, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
 			s.Equal(v.expectBody, result)
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	_, err := s.repo.GetPocketMessageByUUID(burnID)
+	s.Equal(errNotFound, err)
 }
-func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorParamRandomIDEmpty() {
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorExpired() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		randomID    string
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:        "get_pocket_message_by_random_id-error_param_random_id_empty",
-			paramName:   "random_id",
-			paramValue:  "",
-			expectError: errors.New("error, random_id parameter can not be empty"),
+			name:        "get_pocket_message_by_random_id-error_expired_at",
+			randomID:    "expired",
+			expectError: ErrPocketMessageExpired,
+		},
+		{
+			name:        "get_pocket_message_by_random_id-error_max_visit",
+			randomID:    "exhausted",
+			expectError: ErrPocketMessageExpired,
+		},
+		{
+			name:     "get_pocket_message_by_random_id-error_last_visit_raced",
+			randomID: "last-visit",
+			// Another reader took the last visit first.
+			dbErrors:    map[string]error{"UpdateVisitCount": errNotFound},
+			expectError: ErrPocketMessageExpired,
+		},
+		{
+			name:     "get_pocket_message_by_random_id-error_burn_raced",
+			randomID: "secret",
+			// Another reader burnt the message first.
+			dbErrors:    map[string]error{"BurnPocketMessage": errNotFound},
+			expectError: ErrPocketMessageExpired,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			_, err := s.service.GetPocketMessageByRandomID(c)
+			s.db.Errors = v.dbErrors
+			_, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{RandomID: v.randomID})
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorRepo() {
+func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDPassphrase() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		randomID    string
+		passphrase  string
+		expectTitle string
 		expectError error
 	}{
 		{
-			name:        "get_pocket_message_by_random_id-error_repo",
-			paramName:   "random_id",
-			paramValue:  "superidol",
-			expectError: errors.New("record not found"),
+			name:        "get_pocket_message_by_random_id-passphrase",
+			randomID:    "protected",
+			passphrase:  "open sesame",
+			expectTitle: "rahasia",
+			expectError: nil,
+		},
+		{
+			name:        "get_pocket_message_by_random_id-error_passphrase_required",
+			randomID:    "protected",
+			expectError: ErrPassphraseRequired,
+		},
+		{
+			name:        "get_pocket_message_by_random_id-error_passphrase_invalid",
+			randomID:    "protected",
+			passphrase:  "close sesame",
+			expectError: ErrPassphraseInvalid,
+		},
+		{
+			name:        "get_pocket_message_by_random_id-error_locked",
+			randomID:    "locked",
+			passphrase:  "open sesame",
+			expectError: ErrPocketMessageLocked,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			_, err := s.service.GetPocketMessageByRandomID(c)
+			result, err := s.service.GetPocketMessageByRandomID(context.Background(), dto.ReadPocketMessage{
+				RandomID:   v.randomID,
+				Passphrase: v.passphrase,
+			})
 			s.Equal(v.expectError, err)
+			s.Equal(v.expectTitle, result.Title)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestGetPocketMessageByRandomIDErrorUpdateVisitCount() {
+
+// UpdatePocketMessage
+func (s *PocketMessageSuite) TestUpdatePocketMessage() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		msgID       uuid.UUID
+		body        dto.UpdatePocketMessage
 		expectError error
 	}{
 		{
-			name:        "get_pocket_message_by_random_id-error_update_visit_count",
-			paramName:   "random_id",
-			paramValue:  "igantenk",
-			expectError: errors.New("record not found"),
+			name:  "update_pocket_message-normal",
+			msgID: messageID,
+			body: dto.UpdatePocketMessage{
+				Title:   "damn",
+				Content: "idol",
+			},
+			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			_, err := s.service.GetPocketMessageByRandomID(c)
+			req := v.body
+			req.UUID = v.msgID
+			err := s.service.UpdatePocketMessage(callerContext(uuid.Nil), req)
 			s.Equal(v.expectError, err)
 		})
 	}
-}
 
-// UpdatePocketMessage
-func (s *PocketMessageSuite) TestUpdatePocketMessage() {
+	pm, err := s.repo.GetPocketMessageByUUID(messageID)
+	s.NoError(err)
+	s.Equal("damn", pm.Title)
+}
+func (s *PocketMessageSuite) TestUpdatePocketMessageErrorRepo() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		msgID       uuid.UUID
+		body        dto.UpdatePocketMessage
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-normal",
-			paramName:  "uuid",
-			paramValue: uuid.Nil.String(),
-			body: models.PocketMessage{
-				Title:   "damn",
-				Content: "idol",
+			name:  "update_pocket_message-error_repo",
+			msgID: messageID,
+			body: dto.UpdatePocketMessage{
+				Title:   "super",
+				Content: "asd",
 			},
+			dbErrors:    map[string]error{"UpdatePocketMessage": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.db.Errors = v.dbErrors
+			req := v.body
+			req.UUID = v.msgID
+			err := s.service.UpdatePocketMessage(callerContext(uuid.Nil), req)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+func (s *PocketMessageSuite) TestUpdatePocketMessageErrorOwnership() {
+	testCase := []struct {
+		name        string
+		msgID       uuid.UUID
+		userID      uuid.UUID
+		expectError error
+	}{
+		{
+			name:        "update_pocket_message-error_not_owner",
+			msgID:       messageID,
+			userID:      strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "update_pocket_message-error_not_found",
+			msgID:       missingID,
+			userID:      uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
+		},
+		{
+			name:        "update_pocket_message-error_trashed",
+			msgID:       trashedID,
+			userID:      uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			req := dto.UpdatePocketMessage{UUID: v.msgID, Title: "damn", Content: "idol"}
+			err := s.service.UpdatePocketMessage(callerContext(v.userID), req)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+func (s *PocketMessageSuite) TestUpdatePocketMessageErrorAuth() {
+	req := dto.UpdatePocketMessage{UUID: messageID, Title: "damn", Content: "idol"}
+	err := s.service.UpdatePocketMessage(context.Background(), req)
+	s.Equal(authz.ErrUnauthenticated, err)
+}
+
+// DeletePocketMessage
+func (s *PocketMessageSuite) TestDeletePocketMessage() {
+	testCase := []struct {
+		name        string
+		msgID       uuid.UUID
+		expectError error
+	}{
+		{
+			name:        "delete_pocket_message-normal",
+			msgID:       messageID,
 			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePocketMessage(c)
+			err := s.service.DeletePocketMessage(callerContext(uuid.Nil), v.msgID)
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	_, err := s.repo.GetTrashedPocketMessage(messageID)
+	s.NoError(err)
 }
-func (s *PocketMessageSuite) TestUpdatePocketMessageErrorBinding() {
+func (s *PocketMessageSuite) TestDeletePocketMessageErrorDB() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		msgID       uuid.UUID
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-error_binding",
-			paramName:  "uuid",
-			paramValue: uuid.Nil.String(),
-			body: models.PocketMessage{
-				Title:   "damn",
-				Content: "idol",
-			},
-			expectError: echo.NewHTTPError(415, "Unsupported Media Type"),
+			name:        "delete_pocket_message-error_db",
+			msgID:       messageID,
+			dbErrors:    map[string]error{"DeletePocketMessage": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-
-			err := s.service.UpdatePocketMessage(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.DeletePocketMessage(callerContext(uuid.Nil), v.msgID)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestUpdatePocketMessageErrorTitleEmpty() {
+
+func (s *PocketMessageSuite) TestDeletePocketMessageErrorOwnership() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		msgID       uuid.UUID
+		userID      uuid.UUID
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-error_empty_title",
-			paramName:  "uuid",
-			paramValue: uuid.Nil.String(),
-			body: models.PocketMessage{
-				Title:   "",
-				Content: "idol",
-			},
-			expectError: errors.New("error, title should not be empty"),
+			name:        "delete_pocket_message-error_not_owner",
+			msgID:       messageID,
+			userID:      strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "delete_pocket_message-error_not_found",
+			msgID:       missingID,
+			userID:      uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePocketMessage(c)
+			err := s.service.DeletePocketMessage(callerContext(v.userID), v.msgID)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestUpdatePocketMessageErrorContentEmpty() {
+
+// GetUserPocketMessage
+func (s *PocketMessageSuite) TestGetUserPocketmessage() {
+	owned := []dto.OwnedMessage{s.owned(donationID, "akasupas", 1000), s.owned(memberID, "superchat", 10)}
+	first := owned[0].CursorOf(dto.SortCreated, true)
+	from := time.Date(2022, 11, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		req         dto.ListOwnedMessages
+		dbErrors    map[string]error
+		expectBody  dto.OwnedMessagePage
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-error_empty_content",
-			paramName:  "uuid",
-			paramValue: uuid.Nil.String(),
-			body: models.PocketMessage{
-				Title:   "asd",
-				Content: "",
+			name: "get_user_pocket_message-normal",
+			expectBody: dto.OwnedMessagePage{
+				Messages: owned,
+				Total:    2,
+			},
+		},
+		{
+			name: "get_user_pocket_message-first_page",
+			req:  dto.ListOwnedMessages{Limit: 1},
+			expectBody: dto.OwnedMessagePage{
+				Messages:   owned[:1],
+				NextCursor: encodeCursor(first),
+				Total:      2,
+			},
+		},
+		{
+			name: "get_user_pocket_message-next_page",
+			req:  dto.ListOwnedMessages{Limit: 1, Cursor: encodeCursor(first)},
+			expectBody: dto.OwnedMessagePage{
+				Messages: owned[1:],
+				Total:    2,
 			},
-			expectError: errors.New("error, content should not be empty"),
+		},
+		{
+			name:        "get_user_pocket_message-error_limit",
+			req:         dto.ListOwnedMessages{Limit: 101},
+			expectError: apperr.Validation("error, limit should be between 1 and 100"),
+		},
+		{
+			name:        "get_user_pocket_message-error_limit_negative",
+			req:         dto.ListOwnedMessages{Limit: -1},
+			expectError: apperr.Validation("error, limit should be between 1 and 100"),
+		},
+		{
+			name:        "get_user_pocket_message-error_sort",
+			req:         dto.ListOwnedMessages{Sort: "title"},
+			expectError: apperr.Validation("error, sort should be created, updated or visits"),
+		},
+		{
+			name:        "get_user_pocket_message-error_order",
+			req:         dto.ListOwnedMessages{Order: "random"},
+			expectError: apperr.Validation("error, order should be asc or desc"),
+		},
+		{
+			name:        "get_user_pocket_message-error_status",
+			req:         dto.ListOwnedMessages{Status: "deleted"},
+			expectError: apperr.Validation("error, status should be active, expired or revoked"),
+		},
+		{
+			name:        "get_user_pocket_message-error_created_range",
+			req:         dto.ListOwnedMessages{CreatedFrom: &from, CreatedTo: &to},
+			expectError: apperr.Validation("error, created_from should be before created_to"),
+		},
+		{
+			name:        "get_user_pocket_message-error_cursor",
+			req:         dto.ListOwnedMessages{Cursor: "!!"},
+			expectError: apperr.Validation("error, cursor invalid"),
+		},
+		{
+			name:        "get_user_pocket_message-error_cursor_other_sort",
+			req:         dto.ListOwnedMessages{Sort: dto.SortVisits, Cursor: encodeCursor(first)},
+			expectError: apperr.Validation("error, cursor invalid"),
+		},
+		{
+			name:        "get_user_pocket_message-error_db",
+			dbErrors:    map[string]error{"GetPocketMessageByUserUUID": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:        "get_user_pocket_message-error_count",
+			dbErrors:    map[string]error{"CountPocketMessageByUserUUID": errors.New("count error")},
+			expectError: errors.New("count error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePocketMessage(c)
+			s.db.Errors = v.dbErrors
+			result, err := s.service.GetUserPocketMessage(callerContext(vtuberID), v.req)
+			s.Equal(v.expectBody, result)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestUpdatePocketMessageErrorParsingUUID() {
+func (s *PocketMessageSuite) TestGetUserPocketmessageErrorUnauthenticated() {
+	result, err := s.service.GetUserPocketMessage(context.Background(), dto.ListOwnedMessages{})
+	s.Equal(dto.OwnedMessagePage{}, result)
+	s.Equal(authz.ErrUnauthenticated, err)
+}
+
+// owned is the listing entry of a seeded message with a single share link.
+func (s *PocketMessageSuite) owned(msgID uuid.UUID, randomID string, visit int) dto.OwnedMessage {
+	pm, err := s.repo.GetPocketMessageByUUID(msgID)
+	s.Require().NoError(err)
+	return dto.OwnedMessage{
+		ID:        pm.ID,
+		UUID:      pm.UUID,
+		RandomID:  randomID,
+		Title:     pm.Title,
+		Content:   pm.Content,
+		Visit:     visit,
+		Links:     1,
+		CreatedAt: pm.CreatedAt,
+		UpdatedAt: pm.UpdatedAt,
+	}
+}
+
+// ShareLinks
+func (s *PocketMessageSuite) TestCreateShareLink() {
+	later := time.Now().Add(time.Hour)
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		msgID       uuid.UUID
+		owner       uuid.UUID
+		body        dto.NewShareLink
+		expectLink  dto.ShareLink
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-error_parsing",
-			paramName:  "uuid",
-			paramValue: "00000000-000-00-0-00000",
-			body: models.PocketMessage{
-				Title:   "asd",
-				Content: "asd",
-			},
-			expectError: errors.New("uuid invalid"),
+			name:       "create_share_link-normal",
+			msgID:      messageID,
+			body:       dto.NewShareLink{Label: "family", MaxVisit: 3, Slug: "for-family"},
+			expectLink: dto.ShareLink{RandomID: "for-family", Label: "family", MaxVisit: 3},
+		},
+		{
+			name:       "create_share_link-passphrase",
+			msgID:      messageID,
+			body:       dto.NewShareLink{Slug: "secret-link", Passphrase: "open sesame"},
+			expectLink: dto.ShareLink{RandomID: "secret-link", Protected: true},
+		},
+		{
+			name:        "create_share_link-error_slug_taken",
+			msgID:       messageID,
+			body:        dto.NewShareLink{Slug: "asdfghjkl"},
+			expectError: ErrSlugTaken,
+		},
+		{
+			name:        "create_share_link-error_expires_in",
+			msgID:       messageID,
+			body:        dto.NewShareLink{ExpiresIn: "sehari"},
+			expectError: apperr.Validation("error, expires_in should be a positive duration"),
+		},
+		{
+			name:        "create_share_link-error_both_expiries",
+			msgID:       messageID,
+			body:        dto.NewShareLink{ExpiredAt: &later, ExpiresIn: "1h"},
+			expectError: apperr.Validation("error, set either expired_at or expires_in, not both"),
+		},
+		{
+			name:        "create_share_link-error_not_found",
+			msgID:       missingID,
+			expectError: ErrPocketMessageNotFound,
+		},
+		{
+			name:        "create_share_link-error_ownership",
+			msgID:       messageID,
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePocketMessage(c)
+			link, err := s.service.CreateShareLink(callerContext(v.owner), v.msgID, v.body)
 			s.Equal(v.expectError, err)
+			if v.expectError == nil {
+				s.False(link.CreatedAt.IsZero())
+				link.CreatedAt = v.expectLink.CreatedAt
+			}
+			s.Equal(v.expectLink, link)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestUpdatePocketMessageErrorRepo() {
+func (s *PocketMessageSuite) TestGetShareLinks() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		body        models.PocketMessage
+		msgID       uuid.UUID
+		owner       uuid.UUID
+		dbErrors    map[string]error
+		expectLinks []dto.ShareLink
 		expectError error
 	}{
 		{
-			name:       "update_pocket_message-error_repo",
-			paramName:  "uuid",
-			paramValue: uuid.Nil.String(),
-			body: models.PocketMessage{
-				Title:   "super",
-				Content: "asd",
+			name:  "get_share_links-normal",
+			msgID: messageID,
+			expectLinks: []dto.ShareLink{
+				{RandomID: "asdfghjkl", Label: "family", Visit: 2},
+				{RandomID: "qwertyuio", Label: "friends", MaxVisit: 5, Protected: true},
 			},
+		},
+		{
+			name:        "get_share_links-error_ownership",
+			msgID:       messageID,
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "get_share_links-error_db",
+			msgID:       messageID,
+			dbErrors:    map[string]error{"GetRandomIDsByPocketMessageUUID": errors.New("database error")},
 			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePocketMessage(c)
+			s.db.Errors = v.dbErrors
+			links, err := s.service.GetShareLinks(callerContext(v.owner), v.msgID)
 			s.Equal(v.expectError, err)
+			for i := range links {
+				s.False(links[i].CreatedAt.IsZero())
+				links[i].CreatedAt = time.Time{}
+			}
+			s.Equal(v.expectLinks, links)
 		})
 	}
 }
-
-// DeletePocketMessage
-func (s *PocketMessageSuite) TestDeletePocketMessage() {
+func (s *PocketMessageSuite) TestRevokeShareLink() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		msgID       uuid.UUID
+		randomID    string
+		owner       uuid.UUID
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:        "delete_pocket_message-normal",
-			paramName:   "uuid",
-			paramValue:  "00000000-0000-0000-0000-000000000001",
-			expectError: nil,
+			name:     "revoke_share_link-normal",
+			msgID:    messageID,
+			randomID: "asdfghjkl",
+		},
+		{
+			name:        "revoke_share_link-error_not_found",
+			msgID:       messageID,
+			randomID:    "asdfghjkl",
+			expectError: ErrShareLinkNotFound,
+		},
+		{
+			name:        "revoke_share_link-error_other_message",
+			msgID:       messageID,
+			randomID:    "secret",
+			expectError: ErrShareLinkNotFound,
+		},
+		{
+			name:        "revoke_share_link-error_ownership",
+			msgID:       messageID,
+			randomID:    "qwertyuio",
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "revoke_share_link-error_db",
+			msgID:       messageID,
+			randomID:    "qwertyuio",
+			dbErrors:    map[string]error{"DeleteRandomID": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.DeletePocketMessage(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.RevokeShareLink(callerContext(v.owner), v.msgID, v.randomID)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestDeletePocketMessageErrorParsing() {
+
+// GetPocketMessageStats
+func (s *PocketMessageSuite) TestGetPocketMessageStats() {
+	to := viewsFrom.Add(72 * time.Hour)
+
+	stats, err := s.service.GetPocketMessageStats(callerContext(uuid.Nil), dto.StatsRequest{
+		UUID:   messageID,
+		Bucket: "day",
+		From:   &viewsFrom,
+		To:     &to,
+	})
+	s.NoError(err)
+	s.Equal(2, stats.TotalVisits)
+	s.Equal(3, stats.Views)
+	s.Equal(2, stats.UniqueVisitors)
+	s.Equal([]dto.LinkStats{
+		{RandomID: "asdfghjkl", Label: "family", Visit: 2, Views: 2},
+		{RandomID: "qwertyuio", Label: "friends", Views: 1},
+	}, stats.Links)
+	s.Equal([]dto.StatsBucket{
+		{Start: viewsFrom, Views: 2},
+		{Start: viewsFrom.Add(24 * time.Hour), Views: 0},
+		{Start: viewsFrom.Add(48 * time.Hour), Views: 1},
+	}, stats.Series)
+}
+func (s *PocketMessageSuite) TestGetPocketMessageStatsError() {
+	from := time.Date(2022, 11, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	yearStart := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		req         dto.StatsRequest
+		owner       uuid.UUID
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:        "delete_pocket_message-error_oarsing",
-			paramName:   "uuid",
-			paramValue:  "00000000-0000-000000-000000000000",
-			expectError: errors.New("uuid invalid"),
+			name:        "get_pocket_message_stats-error_bucket",
+			req:         dto.StatsRequest{UUID: messageID, Bucket: "week"},
+			expectError: apperr.Validation("error, bucket should be hour or day"),
+		},
+		{
+			name:        "get_pocket_message_stats-error_range",
+			req:         dto.StatsRequest{UUID: messageID, From: &from, To: &to},
+			expectError: apperr.Validation("error, from should be before to"),
+		},
+		{
+			name:        "get_pocket_message_stats-error_too_many_buckets",
+			req:         dto.StatsRequest{UUID: messageID, Bucket: "hour", From: &yearStart, To: &yearEnd},
+			expectError: apperr.Validation("error, time range has too many buckets"),
+		},
+		{
+			name:        "get_pocket_message_stats-error_ownership",
+			req:         dto.StatsRequest{UUID: messageID},
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "get_pocket_message_stats-error_db",
+			req:         dto.StatsRequest{UUID: messageID},
+			dbErrors:    map[string]error{"GetMessageViews": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.DeletePocketMessage(c)
+			s.db.Errors = v.dbErrors
+			_, err := s.service.GetPocketMessageStats(callerContext(v.owner), v.req)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestDeletePocketMessageErrorDB() {
+
+// SearchPocketMessages
+func (s *PocketMessageSuite) TestSearchPocketMessages() {
+	owner := uuid.MustParse("00000000-0000-0000-0000-000000000201")
+	found := uuid.MustParse("00000000-0000-0000-0000-000000000202")
+	s.save(models.PocketMessage{UUID: found, UserUUID: owner, Title: "salam", Content: "halo kamu"})
+	s.NoError(s.index.Index(search.Document{ID: found, Owner: owner, Title: "salam", Content: "halo kamu"}))
+	// missingID was deleted without going through the service.
+	s.NoError(s.index.Index(search.Document{ID: missingID, Owner: owner, Title: "kamu", Content: "kamu"}))
+	s.NoError(s.index.Index(search.Document{ID: uuid.New(), Owner: uuid.New(), Title: "kamu", Content: "kamu"}))
+
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
+		req         dto.SearchPocketMessages
+		expectIDs   []uuid.UUID
 		expectError error
 	}{
 		{
-			name:        "delete_pocket_message-error_db",
-			paramName:   "uuid",
-			paramValue:  "00000000-0000-0000-0000-000000000000",
-			expectError: errors.New("record not found"),
+			name:      "search_pocket_messages-normal",
+			req:       dto.SearchPocketMessages{Query: "Kamu"},
+			expectIDs: []uuid.UUID{found},
+		},
+		{
+			name:      "search_pocket_messages-no_match",
+			req:       dto.SearchPocketMessages{Query: "dunia lain"},
+			expectIDs: []uuid.UUID{},
+		},
+		{
+			name:        "search_pocket_messages-error_query",
+			req:         dto.SearchPocketMessages{Query: "?!"},
+			expectError: apperr.Validation("error, q should have a word of at least 2 characters"),
+		},
+		{
+			name:        "search_pocket_messages-error_limit",
+			req:         dto.SearchPocketMessages{Query: "kamu", Limit: 51},
+			expectError: apperr.Validation("error, limit should be between 1 and 50"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.DeletePocketMessage(c)
+			result, err := s.service.SearchPocketMessages(callerContext(owner), v.req)
 			s.Equal(v.expectError, err)
+			if v.expectError != nil {
+				return
+			}
+			ids := []uuid.UUID{}
+			for _, r := range result {
+				ids = append(ids, r.UUID)
+			}
+			s.Equal(v.expectIDs, ids)
 		})
 	}
+
+	// The message behind the stale hit is gone, so it left the index.
+	hits, err := s.index.Search(owner, "kamu", 10)
+	s.NoError(err)
+	s.Equal([]search.Hit{{ID: found, Score: hits[0].Score}}, hits)
 }
+func (s *PocketMessageSuite) TestSearchPocketMessagesSnippet() {
+	owner := uuid.MustParse("00000000-0000-0000-0000-000000000301")
+	msgID := uuid.MustParse("00000000-0000-0000-0000-000000000302")
+	s.save(models.PocketMessage{UUID: msgID, UserUUID: owner, Title: "halo dunia", Content: "halo kamu"})
+	s.NoError(s.index.Index(search.Document{ID: msgID, Owner: owner, Title: "halo dunia", Content: "halo kamu"}))
 
-// GetUserPocketMessage
-func (s *PocketMessageSuite) TestGetUserPocketmessage() {
+	result, err := s.service.SearchPocketMessages(callerContext(owner), dto.SearchPocketMessages{Query: "kamu"})
+	s.NoError(err)
+	if s.Len(result, 1) {
+		s.Equal("halo dunia", result[0].Title)
+		s.Equal("halo <mark>kamu</mark>", result[0].Snippet)
+		s.Greater(result[0].Score, 0.0)
+	}
+}
+func (s *PocketMessageSuite) TestSearchPocketMessagesErrorUnauthenticated() {
+	result, err := s.service.SearchPocketMessages(context.Background(), dto.SearchPocketMessages{Query: "kamu"})
+	s.Nil(result)
+	s.Equal(authz.ErrUnauthenticated, err)
+}
+func (s *PocketMessageSuite) TestSearchIndexFollowsMessages() {
+	owner := uuid.Nil
+	ctx := callerContext(owner)
+	find := func(query string) []search.Hit {
+		hits, err := s.index.Search(owner, query, 10)
+		s.NoError(err)
+		return hits
+	}
+
+	err := s.service.NewPocketMessage(ctx, dto.NewPocketMessage{Title: "piknik", Content: "bawa tikar"})
+	s.NoError(err)
+	hits := find("tikar")
+	s.Require().Len(hits, 1)
+	msgID := hits[0].ID
+
+	err = s.service.UpdatePocketMessage(ctx, dto.UpdatePocketMessage{UUID: msgID, Title: "rapat", Content: "bawa laptop"})
+	s.NoError(err)
+	s.Len(find("laptop"), 1)
+	s.Len(find("tikar"), 0)
+
+	err = s.service.DeletePocketMessage(ctx, msgID)
+	s.NoError(err)
+	s.Len(find("laptop"), 0)
+}
+
+// RebuildSearchIndex
+func (s *PocketMessageSuite) TestRebuildSearchIndex() {
+	batch := SearchReindexBatch
+	SearchReindexBatch = 2
+	defer func() { SearchReindexBatch = batch }()
+
+	repo := repositories.NewMemory()
+	stored := []models.PocketMessage{
+		{UUID: uuid.New(), Title: "grocery list", Content: "milk and eggs"},
+		{UUID: uuid.New(), Title: "birthday", Content: "buy a cake"},
+		{UUID: uuid.New(), Title: "secret", Content: "ciphertext milk", Encrypted: true},
+	}
+	for _, pm := range stored {
+		s.Require().NoError(repo.SaveNewPocketMessage(pm))
+	}
+
+	index := search.NewMemory()
+	n, err := RebuildSearchIndex(repo, index)
+	s.NoError(err)
+	s.Equal(len(stored), n)
+
+	hits, err := index.Search(uuid.Nil, "milk", 10)
+	s.NoError(err)
+	// The end-to-end encrypted message is only searchable by its title.
+	s.Equal([]search.Hit{{ID: stored[0].UUID, Score: hits[0].Score}}, hits)
+	hits, err = index.Search(uuid.Nil, "secret", 10)
+	s.NoError(err)
+	s.Len(hits, 1)
+}
+
+// Revisions
+func (s *PocketMessageSuite) TestGetPocketMessageRevisions() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		expectBody  []dto.OwnedMessage
+		msgID       uuid.UUID
+		owner       uuid.UUID
+		dbErrors    map[string]error
+		expectBody  []dto.Revision
 		expectError error
 	}{
 		{
-			name:       "get_pocket_message_by_random_id-normal",
-			paramName:  "random_id",
-			paramValue: "asdfghjk",
-			expectBody: []dto.OwnedMessage{
-				{
-					RandomID: "akasupas",
-					Title:    "vtuber",
-					Content:  "donation",
-					Visit:    1000,
-				},
+			name:  "get_pocket_message_revisions-normal",
+			msgID: messageID,
+			owner: uuid.Nil,
+			expectBody: []dto.Revision{
+				{Number: 1, Title: "halo dunia", Content: "halo kamu", Format: models.FormatPlain},
+				{Number: 2, Title: "halo dunia", Content: "halo\nkamu", Format: models.FormatPlain},
+				{Number: 3, Title: "halo bumi", Content: "halo\nkamu", Format: models.FormatMarkdown},
 			},
-			expectError: nil,
+		},
+		{
+			name:        "get_pocket_message_revisions-error_not_owner",
+			msgID:       messageID,
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "get_pocket_message_revisions-error_not_found",
+			msgID:       missingID,
+			owner:       uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
+		},
+		{
+			name:        "get_pocket_message_revisions-error_db",
+			msgID:       messageID,
+			owner:       uuid.Nil,
+			dbErrors:    map[string]error{"GetPocketMessageRevisions": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.MustParse("00000000-0000-0000-0000-000000000001"), "super")
-			if err != nil {
-				s.Error(err, "error get token")
+			s.db.Errors = v.dbErrors
+			result, err := s.service.GetPocketMessageRevisions(callerContext(v.owner), v.msgID)
+			s.Equal(v.expectError, err)
+			for i := range result {
+				s.False(result[i].CreatedAt.IsZero())
+				result[i].CreatedAt = time.Time{}
 			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			result, err := s.service.GetUserPocketMessage(c)
 			s.Equal(v.expectBody, result)
-			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestGetUserPocketmessageErrorDecodeJWT() {
+func (s *PocketMessageSuite) TestDiffPocketMessageRevisions() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		expectBody  []dto.OwnedMessage
+		from, to    int
+		dbErrors    map[string]error
+		expectBody  dto.RevisionDiff
 		expectError error
 	}{
 		{
-			name:        "get_pocket_message_by_random_id-error_decode_jwt",
-			paramName:   "random_id",
-			paramValue:  "asdfghjk",
-			expectBody:  nil,
-			expectError: errors.New("authorization header not found"),
+			name: "diff_pocket_message_revisions-latest",
+			expectBody: dto.RevisionDiff{
+				From:    2,
+				To:      3,
+				Title:   []diff.Line{{Op: diff.OpDelete, Text: "halo dunia"}, {Op: diff.OpInsert, Text: "halo bumi"}},
+				Content: []diff.Line{{Op: diff.OpEqual, Text: "halo"}, {Op: diff.OpEqual, Text: "kamu"}},
+			},
+		},
+		{
+			name: "diff_pocket_message_revisions-range",
+			from: 1,
+			to:   2,
+			expectBody: dto.RevisionDiff{
+				From:    1,
+				To:      2,
+				Title:   []diff.Line{{Op: diff.OpEqual, Text: "halo dunia"}},
+				Content: []diff.Line{{Op: diff.OpDelete, Text: "halo kamu"}, {Op: diff.OpInsert, Text: "halo"}, {Op: diff.OpInsert, Text: "kamu"}},
+			},
+		},
+		{
+			name:        "diff_pocket_message_revisions-error_from",
+			from:        -1,
+			expectError: apperr.Validation("error, from should be a positive integer"),
+		},
+		{
+			name:        "diff_pocket_message_revisions-error_to",
+			to:          -1,
+			expectError: apperr.Validation("error, to should be a positive integer"),
+		},
+		{
+			name:        "diff_pocket_message_revisions-error_not_found",
+			from:        1,
+			to:          4,
+			expectError: ErrRevisionNotFound,
+		},
+		{
+			name:        "diff_pocket_message_revisions-error_db",
+			dbErrors:    map[string]error{"GetPocketMessageRevisions": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			result, err := s.service.GetUserPocketMessage(c)
-			s.Equal(v.expectBody, result)
+			s.db.Errors = v.dbErrors
+			result, err := s.service.DiffPocketMessageRevisions(callerContext(uuid.Nil), dto.RevisionDiffRequest{
+				UUID: messageID,
+				From: v.from,
+				To:   v.to,
+			})
 			s.Equal(v.expectError, err)
+			s.Equal(v.expectBody, result)
 		})
 	}
 }
-func (s *PocketMessageSuite) TestGetUserPocketmessageErrorDB() {
+
+// countedOwned counts the calls of CountPocketMessageByUserUUID.
+type countedOwned struct {
+	repositories.Database
+	counts *int
+}
+
+func (db countedOwned) CountPocketMessageByUserUUID(id uuid.UUID, q dto.OwnedMessageQuery) (int64, error) {
+	*db.counts++
+	return db.Database.CountPocketMessageByUserUUID(id, q)
+}
+
+func (s *PocketMessageSuite) TestGetUserPocketMessageTitleTotal() {
+	owner := uuid.New()
+	for _, title := range []string{"kue lapis", "kue bolu", "kue cubit", "roti"} {
+		s.save(models.PocketMessage{UUID: uuid.New(), Title: title, Content: "isi", UserUUID: owner})
+	}
+	counts := 0
+	service := NewPocketMessageServices(countedOwned{Database: s.repo, counts: &counts}, authz.OwnerPolicy{}, randomid.Default(), search.NewMemory(), settings)
+
+	req := dto.ListOwnedMessages{Limit: 2, Title: "kue"}
+	page, err := service.GetUserPocketMessage(callerContext(owner), req)
+	s.Require().NoError(err)
+	s.Len(page.Messages, 2)
+	s.Equal(int64(3), page.Total)
+
+	req.Cursor = page.NextCursor
+	page, err = service.GetUserPocketMessage(callerContext(owner), req)
+	s.Require().NoError(err)
+	s.Len(page.Messages, 1)
+	s.Equal(int64(3), page.Total)
+	s.Equal(1, counts)
+}
+
+// prunedRevisions returns revs as the revisions of every message, to test
+// messages saved before revisions were recorded and revision numbers that
+// do not start at 1 or have gaps.
+type prunedRevisions struct {
+	repositories.Database
+	revs []models.PocketMessageRevision
+}
+
+func (db prunedRevisions) GetPocketMessageRevisions(uuid.UUID) ([]models.PocketMessageRevision, error) {
+	return db.revs, nil
+}
+
+func (s *PocketMessageSuite) TestPocketMessageRevisionsNeverEdited() {
+	service := NewPocketMessageServices(prunedRevisions{Database: s.repo}, authz.OwnerPolicy{}, randomid.Default(), search.NewMemory(), settings)
+	pm, err := s.repo.GetPocketMessageByUUID(limitedID)
+	s.Require().NoError(err)
+
+	revs, err := service.GetPocketMessageRevisions(callerContext(uuid.Nil), limitedID)
+	s.NoError(err)
+	s.Equal([]dto.Revision{
+		{Number: 1, Title: "terbatas", Content: "sebentar", Format: models.FormatPlain, CreatedAt: pm.CreatedAt},
+	}, revs)
+
+	result, err := service.DiffPocketMessageRevisions(callerContext(uuid.Nil), dto.RevisionDiffRequest{UUID: limitedID})
+	s.NoError(err)
+	s.Equal(dto.RevisionDiff{
+		From:    1,
+		To:      1,
+		Title:   []diff.Line{{Op: diff.OpEqual, Text: "terbatas"}},
+		Content: []diff.Line{{Op: diff.OpEqual, Text: "sebentar"}},
+	}, result)
+}
+func (s *PocketMessageSuite) TestDiffPocketMessageRevisionsByNumber() {
+	service := NewPocketMessageServices(prunedRevisions{Database: s.repo, revs: []models.PocketMessageRevision{
+		{Number: 2, Title: "dua"},
+		{Number: 5, Title: "lima"},
+	}}, authz.OwnerPolicy{}, randomid.Default(), search.NewMemory(), settings)
+
+	result, err := service.DiffPocketMessageRevisions(callerContext(uuid.Nil), dto.RevisionDiffRequest{UUID: messageID})
+	s.NoError(err)
+	s.Equal(2, result.From)
+	s.Equal(5, result.To)
+	s.Equal([]diff.Line{{Op: diff.OpDelete, Text: "dua"}, {Op: diff.OpInsert, Text: "lima"}}, result.Title)
+
+	for _, req := range []dto.RevisionDiffRequest{{From: 1, To: 5}, {From: 2, To: 3}, {To: 1}} {
+		req.UUID = messageID
+		_, err = service.DiffPocketMessageRevisions(callerContext(uuid.Nil), req)
+		s.Equal(ErrRevisionNotFound, err, "%+v", req)
+	}
+}
+func (s *PocketMessageSuite) TestRestorePocketMessageRevision() {
 	testCase := []struct {
 		name        string
-		paramName   string
-		paramValue  string
-		expectBody  []dto.OwnedMessage
+		msgID       uuid.UUID
+		n           int
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name:        "get_pocket_message_by_random_id-error_db",
-			paramName:   "random_id",
-			paramValue:  "asdfghjk",
-			expectBody:  nil,
-			expectError: errors.New("record not found"),
+			name:  "restore_pocket_message_revision-normal",
+			msgID: messageID,
+			n:     1,
+		},
+		{
+			name:        "restore_pocket_message_revision-error_number",
+			msgID:       messageID,
+			n:           0,
+			expectError: apperr.Validation("error, revision number should be a positive integer"),
+		},
+		{
+			name:        "restore_pocket_message_revision-error_not_found",
+			msgID:       messageID,
+			n:           9,
+			expectError: ErrRevisionNotFound,
+		},
+		{
+			name:        "restore_pocket_message_revision-error_message_not_found",
+			msgID:       missingID,
+			n:           1,
+			expectError: ErrPocketMessageNotFound,
+		},
+		{
+			name:        "restore_pocket_message_revision-error_db",
+			msgID:       messageID,
+			n:           1,
+			dbErrors:    map[string]error{"GetPocketMessageRevision": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.SetParamNames(v.paramName)
-			c.SetParamValues(v.paramValue)
-			c.Request().Header.Set("Content-Type", "application/json")
-			token, err := middleware.GetToken(uuid.MustParse("00000000-0000-0000-0000-000000000000"), "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
+			s.db.Errors = v.dbErrors
+			err := s.service.RestorePocketMessageRevision(callerContext(uuid.Nil), v.msgID, v.n)
+			s.Equal(v.expectError, err)
+		})
+	}
 
-			result, err := s.service.GetUserPocketMessage(c)
+	// The restored revision is the newest one and searchable again.
+	rev, err := s.repo.GetPocketMessageRevision(messageID, 4)
+	s.NoError(err)
+	s.Equal("halo kamu", rev.Content)
+	hits, err := s.index.Search(uuid.Nil, "dunia", 10)
+	s.NoError(err)
+	ids := []uuid.UUID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	s.Contains(ids, messageID)
+}
+
+// Trash
+func (s *PocketMessageSuite) TestGetTrashedPocketMessages() {
+	trashed, err := s.repo.GetTrashedPocketMessage(trashedID)
+	s.Require().NoError(err)
+	purgeAt := trashed.DeletedAt.Time.Add(settings.TrashRetention)
+	testCase := []struct {
+		name        string
+		owner       uuid.UUID
+		dbErrors    map[string]error
+		expectBody  []dto.TrashedMessage
+		expectError error
+	}{
+		{
+			name:  "get_trashed_pocket_messages-normal",
+			owner: uuid.Nil,
+			expectBody: []dto.TrashedMessage{
+				{
+					UUID:      trashedID,
+					Title:     "halo dunia",
+					DeletedAt: trashed.DeletedAt.Time,
+					PurgeAt:   &purgeAt,
+				},
+			},
+		},
+		{
+			name:       "get_trashed_pocket_messages-empty",
+			owner:      strangerID,
+			expectBody: []dto.TrashedMessage{},
+		},
+		{
+			name:        "get_trashed_pocket_messages-error_db",
+			owner:       uuid.Nil,
+			dbErrors:    map[string]error{"GetTrashedPocketMessages": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.db.Errors = v.dbErrors
+			result, err := s.service.GetTrashedPocketMessages(callerContext(v.owner))
+			s.Equal(v.expectError, err)
 			s.Equal(v.expectBody, result)
+		})
+	}
+}
+func (s *PocketMessageSuite) TestTrashedMessageWithoutRetention() {
+	pm := models.PocketMessage{Model: gorm.Model{DeletedAt: gorm.DeletedAt{Time: viewsFrom, Valid: true}}}
+	s.Nil(trashedMessage(pm, 0).PurgeAt)
+}
+func (s *PocketMessageSuite) TestRestorePocketMessage() {
+	testCase := []struct {
+		name        string
+		msgID       uuid.UUID
+		owner       uuid.UUID
+		dbErrors    map[string]error
+		expectError error
+	}{
+		{
+			name:        "restore_pocket_message-error_not_owner",
+			msgID:       trashedID,
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "restore_pocket_message-error_db",
+			msgID:       trashedID,
+			owner:       uuid.Nil,
+			dbErrors:    map[string]error{"RestorePocketMessage": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:  "restore_pocket_message-normal",
+			msgID: trashedID,
+			owner: uuid.Nil,
+		},
+		{
+			name:        "restore_pocket_message-error_not_trashed",
+			msgID:       trashedID,
+			owner:       uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.db.Errors = v.dbErrors
+			err := s.service.RestorePocketMessage(callerContext(v.owner), v.msgID)
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	// The restored message is searchable again.
+	hits, err := s.index.Search(uuid.Nil, "kamu", 10)
+	s.NoError(err)
+	ids := []uuid.UUID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	s.Contains(ids, trashedID)
+}
+func (s *PocketMessageSuite) TestPurgePocketMessage() {
+	testCase := []struct {
+		name        string
+		msgID       uuid.UUID
+		owner       uuid.UUID
+		dbErrors    map[string]error
+		expectError error
+	}{
+		{
+			name:        "purge_pocket_message-error_not_owner",
+			msgID:       trashedID,
+			owner:       strangerID,
+			expectError: authz.ErrForbidden,
+		},
+		{
+			name:        "purge_pocket_message-error_db",
+			msgID:       trashedID,
+			owner:       uuid.Nil,
+			dbErrors:    map[string]error{"PurgePocketMessage": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:  "purge_pocket_message-normal",
+			msgID: trashedID,
+			owner: uuid.Nil,
+		},
+		{
+			name:        "purge_pocket_message-error_not_trashed",
+			msgID:       messageID,
+			owner:       uuid.Nil,
+			expectError: ErrPocketMessageNotFound,
+		},
+	}
+	for _, v := range testCase {
+		s.T().Run(v.name, func(t *testing.T) {
+			s.db.Errors = v.dbErrors
+			err := s.service.PurgePocketMessage(callerContext(v.owner), v.msgID)
+			s.Equal(v.expectError, err)
+		})
+	}
+}
+
+// settings are the message settings of the services under test.
+var settings = MessageSettings{
+	ViewIPSalt:             []byte("garam"),
+	TrashRetention:         30 * 24 * time.Hour,
+	PassphraseMaxAttempts:  5,
+	PassphraseLockDuration: 15 * time.Minute,
+	RandomIDMaxAttempts:    5,
+}
+
+// errNotFound is how the repository reports a missing row.
+var errNotFound = apperr.Wrap(apperr.KindNotFound, "not_found", gorm.ErrRecordNotFound)
+
+// callerContext carries the principal the Principal middleware would set.
+func callerContext(id uuid.UUID) context.Context {
+	return authz.NewContext(context.Background(), authz.Principal{UUID: id, Username: "super"})
 }
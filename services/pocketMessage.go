@@ -1,137 +1,294 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"pocket-message/dto"
-	"pocket-message/helper"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/e2e"
+	"pocket-message/pkg/randomid"
+	"pocket-message/pkg/search"
 	"pocket-message/repositories"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-func NewPocketMessageServices(db repositories.Database) PocketMessageServices {
-	return &pmServices{Database: db}
+var (
+	// ErrPocketMessageExpired is returned when a random id has outlived its expiry time or visit limit.
+	ErrPocketMessageExpired = apperr.New(apperr.KindGone, "pocket_message_expired", "error, pocket message has expired")
+	// ErrPassphraseRequired is returned when a protected random id is read without a passphrase.
+	ErrPassphraseRequired = apperr.New(apperr.KindUnauthorized, "passphrase_required", "error, passphrase is required")
+	// ErrPassphraseInvalid is returned when the given passphrase does not match.
+	ErrPassphraseInvalid = apperr.New(apperr.KindUnauthorized, "passphrase_invalid", "error, passphrase is invalid")
+	// ErrPocketMessageLocked is returned while a random id is locked after too many wrong passphrases.
+	ErrPocketMessageLocked = apperr.New(apperr.KindLocked, "pocket_message_locked", "error, pocket message is temporarily locked")
+	// ErrPocketMessageNotFound is returned when no pocket message has the given uuid.
+	ErrPocketMessageNotFound = apperr.New(apperr.KindNotFound, "pocket_message_not_found", "error, pocket message not found")
+	// ErrSlugTaken is returned when a custom slug is already used as a random id.
+	ErrSlugTaken = apperr.New(apperr.KindConflict, "slug_taken", "error, slug has been taken")
+	// ErrRandomIDExhausted is returned when every generated random id collided.
+	ErrRandomIDExhausted = errors.New("error, could not generate a unique random id")
+)
+
+// MessageSettings are the configured settings of pocket messages.
+type MessageSettings struct {
+	// ViewIPSalt keys the hash of reader IP addresses.
+	ViewIPSalt []byte
+	// TrashRetention is how long a deleted pocket message stays in the trash
+	// before RunTrashPurger deletes it permanently; zero keeps it until it is
+	// deleted by hand.
+	TrashRetention time.Duration
+	// PassphraseMaxAttempts is the number of wrong passphrases allowed before
+	// a random id gets locked for PassphraseLockDuration.
+	PassphraseMaxAttempts  int
+	PassphraseLockDuration time.Duration
+	// RandomIDMaxAttempts is how many random ids are tried before giving up
+	// on collisions.
+	RandomIDMaxAttempts int
+}
+
+func NewPocketMessageServices(db repositories.Database, policy authz.Policy, ids randomid.Generator, index search.SearchIndex, settings MessageSettings) PocketMessageServices {
+	return &pmServices{Database: db, Policy: policy, Generator: ids, SearchIndex: index, settings: settings}
 }
 
 type PocketMessageServices interface {
-	NewPocketMessage(echo.Context) error
-	GetPocketMessageByRandomID(echo.Context) (dto.PocketMessageWithRandomID, error)
-	UpdatePocketMessage(echo.Context) error
-	DeletePocketMessage(echo.Context) error
-	GetUserPocketMessage(echo.Context) ([]dto.OwnedMessage, error)
+	NewPocketMessage(ctx context.Context, req dto.NewPocketMessage) error
+	GetPocketMessageByRandomID(ctx context.Context, req dto.ReadPocketMessage) (dto.PocketMessageWithRandomID, error)
+	UpdatePocketMessage(ctx context.Context, req dto.UpdatePocketMessage) error
+	DeletePocketMessage(ctx context.Context, msgID uuid.UUID) error
+	GetUserPocketMessage(ctx context.Context, req dto.ListOwnedMessages) (dto.OwnedMessagePage, error)
+	CreateShareLink(ctx context.Context, msgID uuid.UUID, req dto.NewShareLink) (dto.ShareLink, error)
+	GetShareLinks(ctx context.Context, msgID uuid.UUID) ([]dto.ShareLink, error)
+	RevokeShareLink(ctx context.Context, msgID uuid.UUID, randomID string) error
+	GetPocketMessageStats(ctx context.Context, req dto.StatsRequest) (dto.MessageStats, error)
+	SearchPocketMessages(ctx context.Context, req dto.SearchPocketMessages) ([]dto.SearchResult, error)
+	GetPocketMessageRevisions(ctx context.Context, msgID uuid.UUID) ([]dto.Revision, error)
+	DiffPocketMessageRevisions(ctx context.Context, req dto.RevisionDiffRequest) (dto.RevisionDiff, error)
+	RestorePocketMessageRevision(ctx context.Context, msgID uuid.UUID, n int) error
+	GetTrashedPocketMessages(ctx context.Context) ([]dto.TrashedMessage, error)
+	RestorePocketMessage(ctx context.Context, msgID uuid.UUID) error
+	PurgePocketMessage(ctx context.Context, msgID uuid.UUID) error
 }
 
 type pmServices struct {
 	repositories.Database
+	authz.Policy
+	randomid.Generator
+	search.SearchIndex
+	settings MessageSettings
 }
 
-func (s *pmServices) NewPocketMessage(c echo.Context) error {
-
-	var pm models.PocketMessage
-	err := c.Bind(&pm)
+func (s *pmServices) NewPocketMessage(ctx context.Context, req dto.NewPocketMessage) error {
+	format := formatOrPlain(req.Format)
+	if req.Encrypted {
+		_, err := e2e.Parse(req.Content)
+		if err != nil {
+			return apperr.Wrap(apperr.KindValidation, "invalid_envelope", err)
+		}
+	}
+	rid, err := newShareLink(dto.NewShareLink{
+		Label:      req.Label,
+		ExpiredAt:  req.ExpiredAt,
+		ExpiresIn:  req.ExpiresIn,
+		MaxVisit:   req.MaxVisit,
+		Passphrase: req.Passphrase,
+		Slug:       req.Slug,
+	})
 	if err != nil {
 		return err
 	}
 
-	if pm.Title == "" {
-		return errors.New("error, title should not be empty")
-	}
-	if pm.Content == "" {
-		return errors.New("error, content should not be empty")
-	}
-
-	t, err := middleware.DecodeJWT(c)
+	p, err := authz.PrincipalFrom(ctx)
 	if err != nil {
 		return err
 	}
 
-	pm.UUID = uuid.New()
-	pm.UserUUID = t.UUID
-	err = s.Database.SaveNewPocketMessage(pm)
-	if err != nil {
-		return err
+	pm := models.PocketMessage{
+		UUID:          uuid.New(),
+		Title:         req.Title,
+		Content:       req.Content,
+		Format:        format,
+		Encrypted:     req.Encrypted,
+		UserUUID:      p.UUID,
+		BurnAfterRead: req.BurnAfterRead,
 	}
 
-	var rid models.PocketMessageRandomID
+	// The message is saved together with its random id, so a taken slug or
+	// a failed save does not leave one behind without the other.
 	rid.PocketMessageUUID = pm.UUID
-	rid.RandomID = helper.GenerateRandomString(8)
-	err = s.Database.SaveNewRandomID(rid)
+	err = s.claimRandomID(&rid, func(rid models.PocketMessageRandomID) error {
+		return s.Database.SaveNewPocketMessageWithRandomID(pm, rid)
+	})
 	if err != nil {
 		return err
 	}
+	s.indexMessage(pm)
 
 	return nil
 }
-func (s *pmServices) GetPocketMessageByRandomID(c echo.Context) (dto.PocketMessageWithRandomID, error) {
 
-	rid := c.Param("random_id")
-	if rid == "" {
-		return dto.PocketMessageWithRandomID{}, errors.New("error, random_id parameter can not be empty")
+func (s *pmServices) GetPocketMessageByRandomID(ctx context.Context, req dto.ReadPocketMessage) (dto.PocketMessageWithRandomID, error) {
+	if req.RandomID == "" {
+		return dto.PocketMessageWithRandomID{}, apperr.Validation("error, random_id parameter can not be empty")
 	}
 
-	result, err := s.Database.GetPocketMessageByRandomID(rid)
+	result, err := s.Database.GetPocketMessageByRandomID(req.RandomID)
 	if err != nil {
 		return dto.PocketMessageWithRandomID{}, err
 	}
 
+	if isExpired(result, time.Now()) {
+		return dto.PocketMessageWithRandomID{}, ErrPocketMessageExpired
+	}
+
+	if result.PassphraseHash != "" {
+		err = s.checkPassphrase(result, req.Passphrase)
+		if err != nil {
+			return dto.PocketMessageWithRandomID{}, err
+		}
+	}
+
+	if result.BurnAfterRead {
+		err = s.Database.BurnPocketMessage(result)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// A concurrent reader burnt the message first.
+			return dto.PocketMessageWithRandomID{}, ErrPocketMessageExpired
+		}
+		if err != nil {
+			return dto.PocketMessageWithRandomID{}, err
+		}
+		s.unindexMessage(result.UUID)
+		return result, nil
+	}
+
 	err = s.Database.UpdateVisitCount(result)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A concurrent reader used up the last visit.
+		return dto.PocketMessageWithRandomID{}, ErrPocketMessageExpired
+	}
 	if err != nil {
 		return dto.PocketMessageWithRandomID{}, err
 	}
+	s.recordView(result, req.Viewer)
 
 	return result, nil
 }
-func (s *pmServices) UpdatePocketMessage(c echo.Context) error {
-	var pm models.PocketMessage
-	err := c.Bind(&pm)
-	if err != nil {
-		return err
-	}
-
-	if pm.Title == "" {
-		return errors.New("error, title should not be empty")
+func (s *pmServices) UpdatePocketMessage(ctx context.Context, req dto.UpdatePocketMessage) error {
+	pm := models.PocketMessage{
+		UUID:      req.UUID,
+		Title:     req.Title,
+		Content:   req.Content,
+		Format:    formatOrPlain(req.Format),
+		Encrypted: req.Encrypted,
 	}
-	if pm.Content == "" {
-		return errors.New("error, content should not be empty")
+	if pm.Encrypted {
+		_, err := e2e.Parse(pm.Content)
+		if err != nil {
+			return apperr.Wrap(apperr.KindValidation, "invalid_envelope", err)
+		}
 	}
 
-	pm.UUID, err = uuid.Parse(c.Param("uuid"))
+	old, err := s.authorized(ctx, pm.UUID, authz.ActionUpdate)
 	if err != nil {
-		return errors.New("uuid invalid")
+		return err
 	}
 
 	err = s.Database.UpdatePocketMessage(pm)
 	if err != nil {
 		return err
 	}
+	pm.UserUUID = old.UserUUID
+	s.indexMessage(pm)
 
 	return nil
 }
-func (s *pmServices) DeletePocketMessage(c echo.Context) error {
-	uuid, err := uuid.Parse(c.Param("uuid"))
+func (s *pmServices) DeletePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	err := s.authorize(ctx, msgID, authz.ActionDelete)
 	if err != nil {
-		return errors.New("uuid invalid")
+		return err
 	}
-	err = s.Database.DeletePocketMessage(uuid)
+
+	err = s.Database.DeletePocketMessage(msgID)
 	if err != nil {
 		return err
 	}
+	s.unindexMessage(msgID)
 
 	return nil
 }
-func (s *pmServices) GetUserPocketMessage(c echo.Context) ([]dto.OwnedMessage, error) {
-	t, err := middleware.DecodeJWT(c)
+
+// authorize checks that the caller may perform action on the pocket message.
+func (s *pmServices) authorize(ctx context.Context, msgID uuid.UUID, action authz.Action) error {
+	_, err := s.authorized(ctx, msgID, action)
+	return err
+}
+
+// authorized is authorize returning the pocket message.
+func (s *pmServices) authorized(ctx context.Context, msgID uuid.UUID, action authz.Action) (models.PocketMessage, error) {
+	return s.authorizedFrom(ctx, s.Database.GetPocketMessageByUUID, msgID, action)
+}
+
+// authorizedFrom is authorized reading the pocket message with get, so
+// messages in the trash can be checked too.
+func (s *pmServices) authorizedFrom(ctx context.Context, get func(uuid.UUID) (models.PocketMessage, error), msgID uuid.UUID, action authz.Action) (models.PocketMessage, error) {
+	p, err := authz.PrincipalFrom(ctx)
 	if err != nil {
-		return nil, err
+		return models.PocketMessage{}, err
 	}
 
-	result, err := s.Database.GetPocketMessageByUserUUID(t.UUID)
+	pm, err := get(msgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.PocketMessage{}, ErrPocketMessageNotFound
+	}
 	if err != nil {
-		return nil, err
+		return models.PocketMessage{}, err
 	}
 
-	return result, nil
+	return pm, s.Policy.Authorize(p.UUID, action, pm)
+}
+
+func (s *pmServices) checkPassphrase(pm dto.PocketMessageWithRandomID, passphrase string) error {
+	now := time.Now()
+	if pm.LockedUntil != nil && now.Before(*pm.LockedUntil) {
+		return ErrPocketMessageLocked
+	}
+
+	if passphrase == "" {
+		return ErrPassphraseRequired
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(pm.PassphraseHash), []byte(passphrase))
+	if err != nil {
+		err = s.Database.RecordFailedPassphraseAttempt(pm.RandomID, s.settings.PassphraseMaxAttempts, now.Add(s.settings.PassphraseLockDuration))
+		if err != nil {
+			return err
+		}
+		return ErrPassphraseInvalid
+	}
+
+	if pm.FailedAttempts > 0 || pm.LockedUntil != nil {
+		return s.Database.ResetPassphraseAttempts(pm.RandomID)
+	}
+	return nil
+}
+
+// formatOrPlain is the format of a stored message or revision; rows saved
+// before formats existed are plain text.
+func formatOrPlain(format string) string {
+	if format == "" {
+		return models.FormatPlain
+	}
+	return format
+}
+
+func isExpired(pm dto.PocketMessageWithRandomID, now time.Time) bool {
+	if pm.ExpiredAt != nil && !now.Before(*pm.ExpiredAt) {
+		return true
+	}
+	return pm.MaxVisit > 0 && pm.Visit >= pm.MaxVisit
 }
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"log"
+	"pocket-message/repositories"
+	"time"
+)
+
+// RunExpiredMessageReaper purges expired pocket messages every interval until ctx is done.
+func RunExpiredMessageReaper(ctx context.Context, db repositories.Database, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := db.DeleteExpiredPocketMessages(now)
+			if err != nil {
+				log.Printf("reaper: failed to purge expired pocket messages: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("reaper: purged %d expired pocket messages", n)
+			}
+		}
+	}
+}
+
+// RunTrashPurger permanently deletes pocket messages that have been in the
+// trash for longer than retention, every interval until ctx is done.
+func RunTrashPurger(ctx context.Context, db repositories.Database, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := db.PurgeTrashedPocketMessages(now.Add(-retention))
+			if err != nil {
+				log.Printf("purger: failed to purge trashed pocket messages: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purger: purged %d trashed pocket messages", n)
+			}
+		}
+	}
+}
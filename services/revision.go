@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/diff"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRevisionNotFound is returned when the pocket message has no revision with the given number.
+var ErrRevisionNotFound = apperr.New(apperr.KindNotFound, "revision_not_found", "error, revision not found")
+
+// GetPocketMessageRevisions lists every saved version of a pocket message,
+// oldest first.
+func (s *pmServices) GetPocketMessageRevisions(ctx context.Context, msgID uuid.UUID) ([]dto.Revision, error) {
+	pm, err := s.authorized(ctx, msgID, authz.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	revs, err := s.revisions(pm)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.Revision, len(revs))
+	for i, rev := range revs {
+		result[i] = revision(rev)
+	}
+	return result, nil
+}
+
+// DiffPocketMessageRevisions compares the revisions numbered From and To.
+// They default to the latest revision and the one before it.
+func (s *pmServices) DiffPocketMessageRevisions(ctx context.Context, req dto.RevisionDiffRequest) (dto.RevisionDiff, error) {
+	if req.From < 0 {
+		return dto.RevisionDiff{}, apperr.Validation("error, from should be a positive integer")
+	}
+	if req.To < 0 {
+		return dto.RevisionDiff{}, apperr.Validation("error, to should be a positive integer")
+	}
+	from, to := req.From, req.To
+
+	pm, err := s.authorized(ctx, req.UUID, authz.ActionRead)
+	if err != nil {
+		return dto.RevisionDiff{}, err
+	}
+
+	revs, err := s.revisions(pm)
+	if err != nil {
+		return dto.RevisionDiff{}, err
+	}
+
+	if to == 0 {
+		to = revs[len(revs)-1].Number
+	}
+	b, i := findRevision(revs, to)
+	if i < 0 {
+		return dto.RevisionDiff{}, ErrRevisionNotFound
+	}
+	if from == 0 {
+		from = to
+		if i > 0 {
+			from = revs[i-1].Number
+		}
+	}
+	a, j := findRevision(revs, from)
+	if j < 0 {
+		return dto.RevisionDiff{}, ErrRevisionNotFound
+	}
+
+	return dto.RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(a.Title, b.Title),
+		Content: diff.Lines(a.Content, b.Content),
+	}, nil
+}
+
+// RestorePocketMessageRevision saves an earlier revision as the newest one,
+// so restoring never loses the versions in between.
+func (s *pmServices) RestorePocketMessageRevision(ctx context.Context, msgID uuid.UUID, n int) error {
+	if n < 1 {
+		return apperr.Validation("error, revision number should be a positive integer")
+	}
+
+	pm, err := s.authorized(ctx, msgID, authz.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
+	rev, err := s.Database.GetPocketMessageRevision(msgID, n)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRevisionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	restored := models.PocketMessage{
+		UUID:      msgID,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		Format:    formatOrPlain(rev.Format),
+		Encrypted: rev.Encrypted,
+		UserUUID:  pm.UserUUID,
+	}
+	err = s.Database.UpdatePocketMessage(restored)
+	if err != nil {
+		return err
+	}
+	s.indexMessage(restored)
+
+	return nil
+}
+
+// revisions returns the revisions of pm. Messages saved before revisions
+// were recorded and never edited since have their current content as the
+// only revision.
+func (s *pmServices) revisions(pm models.PocketMessage) ([]models.PocketMessageRevision, error) {
+	revs, err := s.Database.GetPocketMessageRevisions(pm.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		first := models.RevisionOf(pm, 1)
+		first.CreatedAt = pm.CreatedAt
+		revs = append(revs, first)
+	}
+	return revs, nil
+}
+
+// findRevision returns the revision numbered n and its index in revs, or
+// an index of -1 when there is none.
+func findRevision(revs []models.PocketMessageRevision, n int) (models.PocketMessageRevision, int) {
+	for i, rev := range revs {
+		if rev.Number == n {
+			return rev, i
+		}
+	}
+	return models.PocketMessageRevision{}, -1
+}
+
+func revision(rev models.PocketMessageRevision) dto.Revision {
+	return dto.Revision{
+		Number:    rev.Number,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		Format:    formatOrPlain(rev.Format),
+		Encrypted: rev.Encrypted,
+		CreatedAt: rev.CreatedAt,
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/search"
+	"pocket-message/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// SearchDefaultLimit is the number of results when no limit is given.
+	SearchDefaultLimit = 20
+	// SearchMaxLimit is the largest number of results a client can ask for.
+	SearchMaxLimit = 50
+	// SearchSnippetLength is the length of result snippets, in characters.
+	SearchSnippetLength = 160
+)
+
+// SearchPocketMessages finds the caller's pocket messages matching the
+// query, best match first. End-to-end encrypted contents can not
+// be read by the server, so only their titles are searched.
+func (s *pmServices) SearchPocketMessages(ctx context.Context, req dto.SearchPocketMessages) ([]dto.SearchResult, error) {
+	p, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := search.Terms(req.Query)
+	if len(terms) == 0 {
+		return nil, apperr.Validation(fmt.Sprintf("error, q should have a word of at least %d characters", search.MinTermLength))
+	}
+	limit := SearchDefaultLimit
+	if req.Limit != 0 {
+		limit = req.Limit
+		if limit < 1 || limit > SearchMaxLimit {
+			return nil, apperr.Validation(fmt.Sprintf("error, limit should be between 1 and %d", SearchMaxLimit))
+		}
+	}
+
+	hits, err := s.SearchIndex.Search(p.UUID, req.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]dto.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		pm, err := s.Database.GetPocketMessageByUUID(hit.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The reaper deletes expired messages without going through
+			// the service, so the in-memory index still has them.
+			s.unindexMessage(hit.ID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		title := search.Highlight(pm.Title, terms, len([]rune(pm.Title)))
+		if title == "" {
+			title = html.EscapeString(pm.Title)
+		}
+		snippet := ""
+		if !pm.Encrypted {
+			snippet = search.Highlight(pm.Content, terms, SearchSnippetLength)
+		}
+		results = append(results, dto.SearchResult{
+			UUID:      pm.UUID,
+			Title:     title,
+			Snippet:   snippet,
+			Score:     hit.Score,
+			CreatedAt: pm.CreatedAt,
+		})
+	}
+	return results, nil
+}
+
+// indexMessage makes pm searchable. Failing to index it does not fail the
+// request that saved it.
+func (s *pmServices) indexMessage(pm models.PocketMessage) {
+	err := s.SearchIndex.Index(searchDocument(pm))
+	if err != nil {
+		log.Printf("search: failed to index pocket message %s: %v", pm.UUID, err)
+	}
+}
+
+// searchDocument leaves out end-to-end encrypted contents, which are
+// ciphertext to the server.
+func searchDocument(pm models.PocketMessage) search.Document {
+	doc := search.Document{ID: pm.UUID, Owner: pm.UserUUID, Title: pm.Title}
+	if !pm.Encrypted {
+		doc.Content = pm.Content
+	}
+	return doc
+}
+
+func (s *pmServices) unindexMessage(msgID uuid.UUID) {
+	err := s.SearchIndex.Remove(msgID)
+	if err != nil {
+		log.Printf("search: failed to remove pocket message %s: %v", msgID, err)
+	}
+}
+
+// SearchReindexBatch is the number of pocket messages RebuildSearchIndex
+// reads at once.
+var SearchReindexBatch = 500
+
+// RebuildSearchIndex adds every pocket message to index and returns how
+// many there were. It fills a new or in-memory index with the messages
+// saved before it existed.
+func RebuildSearchIndex(db repositories.Database, index search.SearchIndex) (int, error) {
+	var n int
+	var after uint
+	for {
+		pms, err := db.GetPocketMessagesAfter(after, SearchReindexBatch)
+		if err != nil {
+			return n, err
+		}
+		for _, pm := range pms {
+			err = index.Index(searchDocument(pm))
+			if err != nil {
+				return n, err
+			}
+			n++
+			after = pm.ID
+		}
+		if len(pms) < SearchReindexBatch {
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/authz"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenSigner issues the access token of a session.
+type TokenSigner interface {
+	SignSessionToken(userUUID uuid.UUID, username string, sessionID uuid.UUID) (string, error)
+}
+
+func (s *userServices) RefreshToken(ctx context.Context, req dto.RefreshToken) (dto.Login, error) {
+	now := time.Now()
+	session, err := s.Database.GetSessionByRefreshTokenHash(hashToken(req.RefreshToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dto.Login{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	if session.RotatedAt != nil {
+		return dto.Login{}, s.revokeFamily(session, now)
+	}
+	if !session.Active(now) {
+		return dto.Login{}, ErrInvalidRefreshToken
+	}
+
+	user, err := s.Database.GetUserByUUID(session.UserUUID)
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	result, err := s.startSession(user, session.FamilyID, func(next models.Session) error {
+		return s.Database.RotateSession(session.UUID, next, now)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Another request rotated the same token first.
+		return dto.Login{}, s.revokeFamily(session, now)
+	}
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	return result, nil
+}
+func (s *userServices) Logout(ctx context.Context) error {
+	p, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.Database.RevokeSession(p.SessionID, time.Now())
+}
+func (s *userServices) LogoutAll(ctx context.Context) error {
+	p, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.Database.RevokeUserSessions(p.UUID, time.Now())
+}
+
+// startSession creates a session in the given family, hands it to save and
+// returns the access and refresh tokens for it.
+func (s *userServices) startSession(user models.User, familyID uuid.UUID, save func(models.Session) error) (dto.Login, error) {
+	refresh, err := newRandomToken()
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	session := models.Session{
+		UUID:             uuid.New(),
+		UserUUID:         user.UUID,
+		FamilyID:         familyID,
+		RefreshTokenHash: hashToken(refresh),
+		ExpiredAt:        time.Now().Add(s.settings.RefreshTokenTTL),
+	}
+	err = save(session)
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	token, err := s.TokenSigner.SignSessionToken(user.UUID, user.Username, session.UUID)
+	if err != nil {
+		return dto.Login{}, err
+	}
+
+	var result dto.Login
+	result.Username = user.Username
+	result.Token = token
+	result.RefreshToken = refresh
+
+	return result, nil
+}
+
+func (s *userServices) revokeFamily(session models.Session, now time.Time) error {
+	err := s.Database.RevokeSessionFamily(session.FamilyID, now)
+	if err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func newRandomToken() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrShareLinkNotFound is returned when the pocket message has no share link with the given random id.
+var ErrShareLinkNotFound = apperr.New(apperr.KindNotFound, "share_link_not_found", "error, share link not found")
+
+// CreateShareLink adds a share link to a pocket message. Every link has its
+// own visit counter, expiry and passphrase.
+func (s *pmServices) CreateShareLink(ctx context.Context, msgID uuid.UUID, req dto.NewShareLink) (dto.ShareLink, error) {
+	rid, err := newShareLink(req)
+	if err != nil {
+		return dto.ShareLink{}, err
+	}
+
+	err = s.authorize(ctx, msgID, authz.ActionUpdate)
+	if err != nil {
+		return dto.ShareLink{}, err
+	}
+
+	rid.PocketMessageUUID = msgID
+	rid.CreatedAt = time.Now()
+	err = s.saveRandomID(&rid)
+	if err != nil {
+		return dto.ShareLink{}, err
+	}
+
+	return shareLink(rid), nil
+}
+func (s *pmServices) GetShareLinks(ctx context.Context, msgID uuid.UUID) ([]dto.ShareLink, error) {
+	err := s.authorize(ctx, msgID, authz.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	rids, err := s.Database.GetRandomIDsByPocketMessageUUID(msgID)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]dto.ShareLink, len(rids))
+	for i, rid := range rids {
+		links[i] = shareLink(rid)
+	}
+	return links, nil
+}
+
+// RevokeShareLink deletes one share link; the message and its other links
+// stay readable.
+func (s *pmServices) RevokeShareLink(ctx context.Context, msgID uuid.UUID, randomID string) error {
+	if randomID == "" {
+		return apperr.Validation("error, random_id parameter can not be empty")
+	}
+
+	err := s.authorize(ctx, msgID, authz.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
+	err = s.Database.DeleteRandomID(msgID, randomID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrShareLinkNotFound
+	}
+	return err
+}
+
+// newShareLink builds a share link from its settings, which the request
+// validation already checked. The random id is the requested slug, if any.
+func newShareLink(req dto.NewShareLink) (models.PocketMessageRandomID, error) {
+	expiredAt := req.ExpiredAt
+	if req.ExpiresIn != "" {
+		if req.ExpiredAt != nil {
+			return models.PocketMessageRandomID{}, apperr.Validation("error, set either expired_at or expires_in, not both")
+		}
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			return models.PocketMessageRandomID{}, apperr.Validation("error, expires_in should be a positive duration")
+		}
+		at := time.Now().Add(d)
+		expiredAt = &at
+	}
+
+	rid := models.PocketMessageRandomID{
+		RandomID:  req.Slug,
+		Label:     req.Label,
+		MaxVisit:  req.MaxVisit,
+		ExpiredAt: expiredAt,
+	}
+	if req.Passphrase != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Passphrase), bcrypt.DefaultCost)
+		if err != nil {
+			return models.PocketMessageRandomID{}, err
+		}
+		rid.PassphraseHash = string(hash)
+	}
+	return rid, nil
+}
+
+// saveRandomID saves rid under the slug the owner picked, otherwise under a
+// generated id that is regenerated when it collides.
+func (s *pmServices) saveRandomID(rid *models.PocketMessageRandomID) error {
+	return s.claimRandomID(rid, s.Database.SaveNewRandomID)
+}
+
+// claimRandomID calls save with rid under the slug the owner picked,
+// otherwise under a generated id that is regenerated while save reports a
+// conflict.
+func (s *pmServices) claimRandomID(rid *models.PocketMessageRandomID, save func(models.PocketMessageRandomID) error) error {
+	if rid.RandomID != "" {
+		err := save(*rid)
+		if apperr.IsKind(err, apperr.KindConflict) {
+			return ErrSlugTaken
+		}
+		return err
+	}
+
+	for i := 0; i < s.settings.RandomIDMaxAttempts; i++ {
+		id, err := s.Generator.Generate()
+		if err != nil {
+			return err
+		}
+		rid.RandomID = id
+		err = save(*rid)
+		if !apperr.IsKind(err, apperr.KindConflict) {
+			return err
+		}
+	}
+	return ErrRandomIDExhausted
+}
+
+func shareLink(rid models.PocketMessageRandomID) dto.ShareLink {
+	return dto.ShareLink{
+		RandomID:  rid.RandomID,
+		Label:     rid.Label,
+		Visit:     rid.Visit,
+		MaxVisit:  rid.MaxVisit,
+		ExpiredAt: rid.ExpiredAt,
+		Protected: rid.PassphraseHash != "",
+		CreatedAt: rid.CreatedAt,
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"time"
+	"unicode/utf8"
+)
+
+// StatsMaxBuckets limits the length of the time series of a stats request.
+var StatsMaxBuckets = 1000
+
+var statsBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// GetPocketMessageStats counts the views of a pocket message between From
+// and To, per share link and per hour or day.
+func (s *pmServices) GetPocketMessageStats(ctx context.Context, req dto.StatsRequest) (dto.MessageStats, error) {
+	stats, step, err := statsRange(req.Bucket, req.From, req.To, time.Now())
+	if err != nil {
+		return dto.MessageStats{}, err
+	}
+
+	err = s.authorize(ctx, req.UUID, authz.ActionRead)
+	if err != nil {
+		return dto.MessageStats{}, err
+	}
+
+	rids, err := s.Database.GetRandomIDsByPocketMessageUUID(req.UUID)
+	if err != nil {
+		return dto.MessageStats{}, err
+	}
+	views, err := s.Database.GetMessageViews(req.UUID, stats.From, stats.To)
+	if err != nil {
+		return dto.MessageStats{}, err
+	}
+
+	perLink := make(map[string]int)
+	visitors := make(map[string]bool)
+	for _, view := range views {
+		perLink[view.RandomID]++
+		visitors[view.IPHash] = true
+		i := int(view.ViewedAt.Sub(stats.Series[0].Start) / step)
+		if i >= 0 && i < len(stats.Series) {
+			stats.Series[i].Views++
+		}
+	}
+
+	stats.Views = len(views)
+	stats.UniqueVisitors = len(visitors)
+	stats.Links = make([]dto.LinkStats, len(rids))
+	for i, rid := range rids {
+		stats.TotalVisits += rid.Visit
+		stats.Links[i] = dto.LinkStats{
+			RandomID: rid.RandomID,
+			Label:    rid.Label,
+			Visit:    rid.Visit,
+			Views:    perLink[rid.RandomID],
+		}
+	}
+	return stats, nil
+}
+
+// statsRange validates the stats query and returns stats with its range and
+// empty series filled in. The range defaults to the last 30 days by day or
+// the last 24 hours by hour.
+func statsRange(bucket string, from, to *time.Time, now time.Time) (dto.MessageStats, time.Duration, error) {
+	if bucket == "" {
+		bucket = "day"
+	}
+	step, ok := statsBuckets[bucket]
+	if !ok {
+		return dto.MessageStats{}, 0, apperr.Validation("error, bucket should be hour or day")
+	}
+
+	stats := dto.MessageStats{Bucket: bucket, To: now.UTC()}
+	if to != nil {
+		stats.To = *to
+	}
+	if from != nil {
+		stats.From = *from
+	} else if bucket == "hour" {
+		stats.From = stats.To.Add(-24 * time.Hour)
+	} else {
+		stats.From = stats.To.AddDate(0, 0, -30)
+	}
+	if !stats.From.Before(stats.To) {
+		return dto.MessageStats{}, 0, apperr.Validation("error, from should be before to")
+	}
+
+	// Buckets start on whole hours or days in UTC.
+	start := stats.From.UTC().Truncate(step)
+	n := int((stats.To.Sub(start) + step - 1) / step)
+	if n > StatsMaxBuckets {
+		return dto.MessageStats{}, 0, apperr.Validation("error, time range has too many buckets")
+	}
+	stats.Series = make([]dto.StatsBucket, n)
+	for i := range stats.Series {
+		stats.Series[i].Start = start.Add(time.Duration(i) * step)
+	}
+	return stats, step, nil
+}
+
+// recordView stores a successful read. Failing to store it does not fail
+// the read.
+func (s *pmServices) recordView(pm dto.PocketMessageWithRandomID, viewer dto.Viewer) {
+	err := s.Database.SaveMessageView(models.MessageView{
+		PocketMessageUUID: pm.UUID,
+		RandomID:          pm.RandomID,
+		ViewedAt:          time.Now(),
+		IPHash:            s.hashIP(viewer.IP),
+		UserAgent:         truncate(viewer.UserAgent, 255),
+		Referrer:          truncate(viewer.Referrer, 255),
+	})
+	if err != nil {
+		log.Printf("stats: failed to record a view of %s: %v", pm.RandomID, err)
+	}
+}
+
+func (s *pmServices) hashIP(ip string) string {
+	mac := hmac.New(sha256.New, s.settings.ViewIPSalt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// truncate shortens s to n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
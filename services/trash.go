@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"pocket-message/dto"
+	"pocket-message/models"
+	"pocket-message/pkg/authz"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetTrashedPocketMessages lists the caller's deleted pocket messages, most
+// recently deleted first.
+func (s *pmServices) GetTrashedPocketMessages(ctx context.Context) ([]dto.TrashedMessage, error) {
+	p, err := authz.PrincipalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pms, err := s.Database.GetTrashedPocketMessages(p.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.TrashedMessage, len(pms))
+	for i, pm := range pms {
+		result[i] = trashedMessage(pm, s.settings.TrashRetention)
+	}
+	return result, nil
+}
+
+// RestorePocketMessage takes a pocket message out of the trash, bringing
+// back its share links.
+func (s *pmServices) RestorePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	pm, err := s.authorizedFrom(ctx, s.Database.GetTrashedPocketMessage, msgID, authz.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
+	err = s.Database.RestorePocketMessage(msgID)
+	if err != nil {
+		return err
+	}
+	s.indexMessage(pm)
+
+	return nil
+}
+
+// PurgePocketMessage deletes a pocket message in the trash permanently.
+func (s *pmServices) PurgePocketMessage(ctx context.Context, msgID uuid.UUID) error {
+	_, err := s.authorizedFrom(ctx, s.Database.GetTrashedPocketMessage, msgID, authz.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.Database.PurgePocketMessage(msgID)
+}
+
+// trashedMessage shows pm with the time it is purged after retention.
+func trashedMessage(pm models.PocketMessage, retention time.Duration) dto.TrashedMessage {
+	result := dto.TrashedMessage{
+		UUID:      pm.UUID,
+		Title:     pm.Title,
+		Encrypted: pm.Encrypted,
+		DeletedAt: pm.DeletedAt.Time,
+	}
+	if retention > 0 {
+		purgeAt := pm.DeletedAt.Time.Add(retention)
+		result.PurgeAt = &purgeAt
+	}
+	return result
+}
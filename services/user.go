@@ -1,122 +1,221 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"pocket-message/dto"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/notifier"
+	"pocket-message/pkg/password"
+	"pocket-message/pkg/username"
 	"pocket-message/repositories"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
-func NewUserServices(db repositories.Database) UserServices {
-	return &userServices{Database: db}
+// UserSettings are the configured lifetimes of the tokens users get.
+type UserSettings struct {
+	// RefreshTokenTTL is how long a refresh token can be used. Every refresh
+	// starts a new window.
+	RefreshTokenTTL time.Duration
+	// PasswordResetTokenTTL is how long a password reset token can be used.
+	PasswordResetTokenTTL time.Duration
+}
+
+func NewUserServices(db repositories.Database, hasher password.Hasher, n notifier.Notifier, policy username.Policy, signer TokenSigner, settings UserSettings) UserServices {
+	return &userServices{Database: db, Hasher: hasher, Notifier: n, Policy: policy, TokenSigner: signer, settings: settings}
 }
 
 type UserServices interface {
-	SignUp(echo.Context) error
-	Login(echo.Context) (dto.Login, error)
-	UpdateUsername(echo.Context) error
-	UpdatePassword(echo.Context) error
+	SignUp(ctx context.Context, req dto.SignUp) error
+	Login(ctx context.Context, req dto.Credentials) (dto.Login, error)
+	UpdateUsername(ctx context.Context, req dto.UpdateUsername) error
+	RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error
+	ResetPassword(ctx context.Context, req dto.PasswordResetConfirm) error
+	ChangePassword(ctx context.Context, req dto.ChangePassword) error
+	RefreshToken(ctx context.Context, req dto.RefreshToken) (dto.Login, error)
+	Logout(ctx context.Context) error
+	LogoutAll(ctx context.Context) error
 }
 
 type userServices struct {
 	repositories.Database
+	password.Hasher
+	notifier.Notifier
+	username.Policy
+	TokenSigner
+	settings UserSettings
 }
 
-func (s *userServices) SignUp(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
+var (
+	ErrInvalidCredentials  = apperr.New(apperr.KindUnauthorized, "invalid_credentials", "error, username or password is wrong")
+	ErrInvalidResetToken   = apperr.New(apperr.KindUnauthorized, "invalid_reset_token", "error, reset token is invalid or expired")
+	ErrInvalidRefreshToken = apperr.New(apperr.KindUnauthorized, "invalid_refresh_token", "error, refresh token is invalid or expired")
+	ErrRefreshTokenReused  = apperr.New(apperr.KindUnauthorized, "refresh_token_reused", "error, refresh token was already used, all sessions of this login are revoked")
+	ErrUsernameTaken       = apperr.New(apperr.KindConflict, "username_taken", "error, username has been taken")
+)
+
+func (s *userServices) SignUp(ctx context.Context, req dto.SignUp) error {
+	u := models.User{Username: req.Username, Password: req.Password}
+	var err error
+	u.Username, err = s.Policy.Check(u.Username)
 	if err != nil {
 		return err
 	}
 
-	if u.Username == "" {
-		return errors.New("username should not be empty")
-	}
-	if u.Password == "" {
-		return errors.New("password should not be empty")
-	}
-
 	u.UUID = uuid.New()
-	err = s.Database.SaveNewUser(u)
+	u.Password, err = s.Hasher.Hash(u.Password)
 	if err != nil {
 		return err
 	}
+	err = s.Database.SaveNewUser(u)
+	if err != nil {
+		return usernameError(err)
+	}
 
 	return nil
 }
-func (s *userServices) Login(c echo.Context) (dto.Login, error) {
-	var u models.User
-	err := c.Bind(&u)
+func (s *userServices) Login(ctx context.Context, req dto.Credentials) (dto.Login, error) {
+	user, err := s.Database.GetUserByUsername(username.Normalize(req.Username))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dto.Login{}, ErrInvalidCredentials
+	}
 	if err != nil {
 		return dto.Login{}, err
 	}
-	if u.Username == "" {
-		return dto.Login{}, errors.New("username should not be empty")
-	}
-	if u.Password == "" {
-		return dto.Login{}, errors.New("password should not be empty")
-	}
 
-	user, err := s.Database.Login(u)
+	ok, err := s.Hasher.Verify(user.Password, req.Password)
 	if err != nil {
 		return dto.Login{}, err
 	}
+	if !ok {
+		return dto.Login{}, ErrInvalidCredentials
+	}
 
-	token, err := middleware.GetToken(user.UUID, user.Username)
+	// Upgrade plain text rows and hashes made under an older policy.
+	if s.Hasher.NeedsRehash(user.Password) {
+		user.Password, err = s.Hasher.Hash(req.Password)
+		if err != nil {
+			return dto.Login{}, err
+		}
+		err = s.Database.UpdatePassword(user)
+		if err != nil {
+			return dto.Login{}, err
+		}
+	}
+
+	return s.startSession(user, uuid.New(), func(session models.Session) error {
+		return s.Database.SaveSession(session)
+	})
+}
+func (s *userServices) UpdateUsername(ctx context.Context, req dto.UpdateUsername) error {
+	p, err := authz.PrincipalFrom(ctx)
 	if err != nil {
-		return dto.Login{}, err
+		return err
+	}
+	u := models.User{UUID: p.UUID}
+	u.Username, err = s.Policy.Check(req.Username)
+	if err != nil {
+		return err
 	}
 
-	var result dto.Login
-	result.Username = user.Username
-	result.Token = token
+	err = s.Database.UpdateUsername(u)
+	if err != nil {
+		return usernameError(err)
+	}
 
-	return result, nil
+	return nil
 }
-func (s *userServices) UpdateUsername(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
+func (s *userServices) RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error {
+	user, err := s.Database.GetUserByUsername(username.Normalize(req.Username))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Answer the same way for unknown usernames so accounts can not be enumerated.
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	if u.Username == "" {
-		return errors.New("error, username should not be empty")
+
+	token, err := newRandomToken()
+	if err != nil {
+		return err
 	}
-	t, err := middleware.DecodeJWT(c)
+
+	err = s.Database.SavePasswordResetToken(models.PasswordResetToken{
+		TokenHash: hashToken(token),
+		UserUUID:  user.UUID,
+		ExpiredAt: time.Now().Add(s.settings.PasswordResetTokenTTL),
+	})
 	if err != nil {
 		return err
 	}
-	u.UUID = t.UUID
 
-	err = s.Database.UpdateUsername(u)
+	return s.Notifier.Notify(user.Username, "password reset",
+		fmt.Sprintf("use token %s to reset your password, it expires in %s", token, s.settings.PasswordResetTokenTTL))
+}
+func (s *userServices) ResetPassword(ctx context.Context, req dto.PasswordResetConfirm) error {
+	token, err := s.Database.UsePasswordResetToken(hashToken(req.Token), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	hash, err := s.Hasher.Hash(req.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.Database.UpdatePassword(models.User{UUID: token.UserUUID, Password: hash})
+	if err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must not stay logged in.
+	return s.Database.RevokeUserSessions(token.UserUUID, time.Now())
 }
-func (s *userServices) UpdatePassword(c echo.Context) error {
-	var u models.User
-	err := c.Bind(&u)
+func (s *userServices) ChangePassword(ctx context.Context, req dto.ChangePassword) error {
+	p, err := authz.PrincipalFrom(ctx)
 	if err != nil {
 		return err
 	}
 
-	if u.Username == "" {
-		return errors.New("username should not be empty")
+	user, err := s.Database.GetUserByUUID(p.UUID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.Hasher.Verify(user.Password, req.CurrentPassword)
+	if err != nil {
+		return err
 	}
-	if u.Password == "" {
-		return errors.New("password should not be empty")
+	if !ok {
+		return ErrInvalidCredentials
 	}
 
-	err = s.Database.UpdatePassword(u)
+	user.Password, err = s.Hasher.Hash(req.NewPassword)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = s.Database.UpdatePassword(user)
+	if err != nil {
+		return err
+	}
+	// Whoever knew the old password is logged out everywhere but here.
+	return s.Database.RevokeOtherUserSessions(p.UUID, p.SessionID, time.Now())
+}
+
+// usernameError reports a unique index violation on the username as taken.
+func usernameError(err error) error {
+	if apperr.IsKind(err, apperr.KindConflict) {
+		return ErrUsernameTaken
+	}
+	return err
 }
@@ -1,34 +1,85 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"net/http"
-	"net/http/httptest"
 	"pocket-message/dto"
-	"pocket-message/middleware"
 	"pocket-message/models"
+	"pocket-message/pkg/apperr"
+	"pocket-message/pkg/authz"
+	"pocket-message/pkg/password"
+	"pocket-message/pkg/username"
+	"pocket-message/repositories"
 	m "pocket-message/services/mock"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type UserSuite struct {
 	suite.Suite
-	service UserServices
+	repo     repositories.Database
+	db       *m.MockDB
+	service  UserServices
+	notifier *m.MockNotifier
 }
 
+var userSettings = UserSettings{
+	RefreshTokenTTL:       30 * 24 * time.Hour,
+	PasswordResetTokenTTL: 30 * time.Minute,
+}
+
+// The users seeded for every test. Their password is "12345678", stored
+// in plain text for legacy and hashed for the others.
+var (
+	udinID    = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	suneoID   = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	legacyID  = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	missingID = uuid.MustParse("00000000-0000-0000-0000-000000000404")
+)
+
 func TestSuiteUser(t *testing.T) {
 	suite.Run(t, new(UserSuite))
 }
-func (s *UserSuite) SetupSuite() {
-	service := NewUserServices(&m.MockGorm{})
-	s.service = service
+func (s *UserSuite) SetupSuite() {}
+func (s *UserSuite) SetupTest() {
+	s.repo = repositories.NewMemory()
+	s.db = &m.MockDB{Database: s.repo}
+	s.notifier = &m.MockNotifier{}
+	hasher := password.BcryptHasher{Cost: bcrypt.MinCost}
+	s.service = NewUserServices(s.db, hasher, s.notifier, username.DefaultPolicy(), m.MockTokenSigner{}, userSettings)
+
+	hash, err := hasher.Hash("12345678")
+	s.Require().NoError(err)
+	for _, u := range []models.User{
+		{UUID: udinID, Username: "udin", Password: hash},
+		{UUID: suneoID, Username: "suneo", Password: hash},
+		{UUID: legacyID, Username: "legacy", Password: "12345678"},
+		{UUID: uuid.New(), Username: "doraemon", Password: hash},
+	} {
+		s.Require().NoError(s.repo.SaveNewUser(u))
+	}
+
+	now := time.Now()
+	for token, session := range map[string]models.Session{
+		"active-refresh":  {UserUUID: udinID, ExpiredAt: now.Add(time.Hour)},
+		"raced-refresh":   {UserUUID: udinID, ExpiredAt: now.Add(time.Hour)},
+		"rotated-refresh": {UserUUID: udinID, ExpiredAt: now.Add(time.Hour), RotatedAt: &now, RevokedAt: &now},
+		"expired-refresh": {UserUUID: udinID, ExpiredAt: now.Add(-time.Hour)},
+		"orphan-refresh":  {UserUUID: missingID, ExpiredAt: now.Add(time.Hour)},
+	} {
+		session.UUID, session.FamilyID = uuid.New(), uuid.New()
+		session.RefreshTokenHash = hashToken(token)
+		s.Require().NoError(s.repo.SaveSession(session))
+	}
+	s.Require().NoError(s.repo.SavePasswordResetToken(models.PasswordResetToken{
+		TokenHash: hashToken("valid-token"),
+		UserUUID:  udinID,
+		ExpiredAt: now.Add(time.Hour),
+	}))
 }
 func (s *UserSuite) TearDownSuite() {}
 
@@ -36,110 +87,59 @@ func (s *UserSuite) TearDownSuite() {}
 func (s *UserSuite) TestSignup() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.SignUp
 		expectError error
 	}{
 		{
 			name: "signup-normal",
-			body: models.User{
+			body: dto.SignUp{
 				Username: "superman",
 				Password: "12345678",
 			},
-			method:      http.MethodPost,
 			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.SignUp(c)
+			err := s.service.SignUp(context.Background(), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestSignupErrorUsernameEmpty() {
+func (s *UserSuite) TestSignupErrorUsernamePolicy() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.SignUp
 		expectError error
 	}{
 		{
-			name: "signup-error_username_empty",
-			body: models.User{
-				Username: "",
-				Password: "12345678",
+			name: "signup-error_username_too_short",
+			body: dto.SignUp{
+				Username: "ab",
+				Password: "asd",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("username should not be empty"),
+			expectError: apperr.Validation("error, username should be 3 to 32 characters long"),
 		},
-	}
-	for _, v := range testCase {
-		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.SignUp(c)
-			s.Equal(v.expectError, err)
-		})
-	}
-}
-func (s *UserSuite) TestSignupErrorPasswordEmpty() {
-	testCase := []struct {
-		name        string
-		body        models.User
-		method      string
-		expectError error
-	}{
 		{
-			name: "signup-error_password_empty",
-			body: models.User{
-				Username: "asd",
-				Password: "",
+			name: "signup-error_username_charset",
+			body: dto.SignUp{
+				Username: "super man",
+				Password: "asd",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("password should not be empty"),
+			expectError: apperr.Validation("error, username contains characters that are not allowed"),
+		},
+		{
+			name: "signup-error_username_reserved",
+			body: dto.SignUp{
+				Username: "ROOT",
+				Password: "asd",
+			},
+			expectError: apperr.Validation("error, username is reserved"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.SignUp(c)
+			err := s.service.SignUp(context.Background(), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
@@ -147,36 +147,32 @@ func (s *UserSuite) TestSignupErrorPasswordEmpty() {
 func (s *UserSuite) TestSignupErrorDB() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.SignUp
+		dbErrors    map[string]error
 		expectError error
 	}{
+		{
+			name: "signup-error_username_taken",
+			body: dto.SignUp{
+				Username: "Doraemon",
+				Password: "asd",
+			},
+			expectError: ErrUsernameTaken,
+		},
 		{
 			name: "signup-error_db",
-			body: models.User{
-				Username: "admin",
+			body: dto.SignUp{
+				Username: "dekisugi",
 				Password: "asd",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("username has been taken"),
+			dbErrors:    map[string]error{"SaveNewUser": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "super")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.SignUp(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.SignUp(context.Background(), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
@@ -186,169 +182,97 @@ func (s *UserSuite) TestSignupErrorDB() {
 func (s *UserSuite) TestLogin() {
 	testCase := []struct {
 		name        string
-		body        models.User
+		body        dto.Credentials
 		expectBody  dto.Login
-		method      string
 		expectError error
 	}{
 		{
 			name: "login-normal",
-			body: models.User{
+			body: dto.Credentials{
 				Username: "udin",
 				Password: "12345678",
 			},
 			expectBody: dto.Login{
 				Username: "udin",
 			},
-			method:      http.MethodPost,
 			expectError: nil,
 		},
-	}
-	for _, v := range testCase {
-		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			result, err := s.service.Login(c)
-			s.Equal(v.expectBody.Username, result.Username)
-			s.Equal(v.expectError, err)
-		})
-	}
-}
-func (s *UserSuite) TestLoginErrorBinding() {
-	testCase := []struct {
-		name        string
-		body        models.User
-		expectBody  dto.Login
-		method      string
-		expectError error
-	}{
 		{
-			name: "login-error_binding",
-			body: models.User{
-				Username: "superman",
+			name: "login-legacy_plain_text_password",
+			body: dto.Credentials{
+				Username: "legacy",
 				Password: "12345678",
 			},
 			expectBody: dto.Login{
-				Username: "",
+				Username: "legacy",
 			},
-			method:      http.MethodPost,
-			expectError: echo.NewHTTPError(415, "Unsupported Media Type"),
+			expectError: nil,
 		},
-	}
-	for _, v := range testCase {
-		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-
-			result, err := s.service.Login(c)
-			s.Equal(v.expectBody.Username, result.Username)
-			s.Equal(v.expectError, err)
-		})
-	}
-}
-func (s *UserSuite) TestLoginErrorUsernameEmpty() {
-	testCase := []struct {
-		name        string
-		body        models.User
-		expectBody  dto.Login
-		method      string
-		expectError error
-	}{
 		{
-			name: "login-error_username_empty",
-			body: models.User{
-				Username: "",
-				Password: "12345678",
-			},
-			expectBody: dto.Login{
-				Username: "",
+			name: "login-error_wrong_password",
+			body: dto.Credentials{
+				Username: "udin",
+				Password: "87654321",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("username should not be empty"),
+			expectError: ErrInvalidCredentials,
 		},
-	}
-	for _, v := range testCase {
-		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			result, err := s.service.Login(c)
-			s.Equal(v.expectBody.Username, result.Username)
-			s.Equal(v.expectError, err)
-		})
-	}
-}
-func (s *UserSuite) TestLoginErrorPasswordEmpty() {
-	testCase := []struct {
-		name        string
-		body        models.User
-		expectBody  dto.Login
-		method      string
-		expectError error
-	}{
 		{
-			name: "login-error_password_empty",
-			body: models.User{
-				Username: "asd",
-				Password: "",
-			},
-			expectBody: dto.Login{
-				Username: "",
+			name: "login-error_unknown_username",
+			body: dto.Credentials{
+				Username: "nobita",
+				Password: "12345678",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("password should not be empty"),
+			expectError: ErrInvalidCredentials,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			result, err := s.service.Login(c)
+			result, err := s.service.Login(context.Background(), v.body)
 			s.Equal(v.expectBody.Username, result.Username)
+			s.Equal(v.expectError == nil, result.RefreshToken != "")
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	// The plain text password was upgraded on login.
+	legacy, err := s.repo.GetUserByUUID(legacyID)
+	s.NoError(err)
+	s.NotEqual("12345678", legacy.Password)
 }
 func (s *UserSuite) TestLoginErrorPasswordDB() {
 	testCase := []struct {
 		name        string
-		body        models.User
+		body        dto.Credentials
+		dbErrors    map[string]error
 		expectBody  dto.Login
-		method      string
 		expectError error
 	}{
 		{
 			name: "login-error_password_db",
-			body: models.User{
+			body: dto.Credentials{
 				Username: "suneo",
 				Password: "asde",
 			},
+			dbErrors: map[string]error{"GetUserByUsername": errors.New("database error")},
 			expectBody: dto.Login{
 				Username: "",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("record not found"),
+			expectError: errors.New("database error"),
+		},
+		{
+			name: "login-error_rehash_db",
+			body: dto.Credentials{
+				Username: "legacy",
+				Password: "12345678",
+			},
+			dbErrors:    map[string]error{"UpdatePassword": errors.New("database error")},
+			expectError: errors.New("database error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-			result, err := s.service.Login(c)
+			s.db.Errors = v.dbErrors
+			result, err := s.service.Login(context.Background(), v.body)
 			s.Equal(v.expectBody.Username, result.Username)
 			s.Equal(v.expectError, err)
 		})
@@ -359,328 +283,367 @@ func (s *UserSuite) TestLoginErrorPasswordDB() {
 func (s *UserSuite) TestUpdateUsername() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.UpdateUsername
 		expectError error
 	}{
 		{
 			name: "update_username-normal",
-			body: models.User{
+			body: dto.UpdateUsername{
 				Username: "udin",
-				Password: "12345678",
 			},
-			method:      http.MethodPost,
 			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "udin")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.UpdateUsername(c)
+			err := s.service.UpdateUsername(callerContext(udinID), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestUpdateUsernameErrorBinding() {
+func (s *UserSuite) TestUpdateUsernameErrorAuth() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.UpdateUsername
 		expectError error
 	}{
 		{
-			name: "update_username-error_binding",
-			body: models.User{
-				Username: "udin",
-				Password: "12345678",
+			name: "update_username-error_auth",
+			body: dto.UpdateUsername{
+				Username: "aseasd",
 			},
-			method:      http.MethodPost,
-			expectError: echo.NewHTTPError(415, "Unsupported Media Type"),
+			expectError: authz.ErrUnauthenticated,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-
-			token, err := middleware.GetToken(uuid.Nil, "udin")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.UpdateUsername(c)
+			err := s.service.UpdateUsername(context.Background(), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestUpdateUsernameErrorUsernameEmpty() {
+func (s *UserSuite) TestUpdateUsernameErrorDB() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.UpdateUsername
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "update_username-error_username_empty",
-			body: models.User{
-				Username: "",
-				Password: "12345678",
+			name: "update_username-error_username_taken",
+			body: dto.UpdateUsername{
+				Username: " SUNEO ",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("error, username should not be empty"),
+			expectError: ErrUsernameTaken,
 		},
-	}
-	for _, v := range testCase {
-		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "udin")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
-
-			err = s.service.UpdateUsername(c)
-			s.Equal(v.expectError, err)
-		})
-	}
-}
-func (s *UserSuite) TestUpdateUsernameErrorAuth() {
-	testCase := []struct {
-		name        string
-		body        models.User
-		method      string
-		expectError error
-	}{
 		{
-			name: "update_username-error_auth",
-			body: models.User{
-				Username: "aseasd",
-				Password: "12345678",
+			name: "update_username-error_db",
+			body: dto.UpdateUsername{
+				Username: "dekisugi",
 			},
-			method:      http.MethodPost,
-			expectError: errors.New("authorization header not found"),
+			dbErrors:    map[string]error{"UpdateUsername": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name: "update_username-error_reserved",
+			body: dto.UpdateUsername{
+				Username: "Admin",
+			},
+			expectError: apperr.Validation("error, username is reserved"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdateUsername(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.UpdateUsername(callerContext(udinID), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestUpdateUsernameErrorDB() {
+
+// RequestPasswordReset
+func (s *UserSuite) TestRequestPasswordReset() {
 	testCase := []struct {
-		name        string
-		body        models.User
-		method      string
-		expectError error
+		name            string
+		body            dto.PasswordResetRequest
+		dbErrors        map[string]error
+		notifierError   error
+		expectRecipient string
+		expectError     error
 	}{
 		{
-			name: "update_username-error_db",
-			body: models.User{
-				Username: "suneo",
-				Password: "12345678",
-			},
-			method:      http.MethodPost,
-			expectError: errors.New("username has taken"),
+			name:            "request_password_reset-normal",
+			body:            dto.PasswordResetRequest{Username: "udin"},
+			expectRecipient: "udin",
+			expectError:     nil,
+		},
+		{
+			name:        "request_password_reset-unknown_username",
+			body:        dto.PasswordResetRequest{Username: "nobita"},
+			expectError: nil,
+		},
+		{
+			name:        "request_password_reset-error_db",
+			body:        dto.PasswordResetRequest{Username: "udin"},
+			dbErrors:    map[string]error{"SavePasswordResetToken": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:          "request_password_reset-error_notifier",
+			body:          dto.PasswordResetRequest{Username: "udin"},
+			notifierError: errors.New("notifier error"),
+			expectError:   errors.New("notifier error"),
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			token, err := middleware.GetToken(uuid.Nil, "udin")
-			if err != nil {
-				s.Error(err, "error get token")
-			}
-			bearer := fmt.Sprintf("Bearer %s", token)
-			c.Request().Header.Set("Authorization", bearer)
+			s.db.Errors = v.dbErrors
+			s.notifier.Recipient = ""
+			s.notifier.Err = v.notifierError
 
-			err = s.service.UpdateUsername(c)
+			err := s.service.RequestPasswordReset(context.Background(), v.body)
 			s.Equal(v.expectError, err)
+			s.Equal(v.expectRecipient, s.notifier.Recipient)
 		})
 	}
 }
-
-// UpdatePassword
-func (s *UserSuite) TestUpdatePassword() {
+func (s *UserSuite) TestResetPassword() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.PasswordResetConfirm
 		expectError error
 	}{
 		{
-			name: "update_password-normal",
-			body: models.User{
-				Username: "udin",
-				Password: "12345678",
+			name: "reset_password-normal",
+			body: dto.PasswordResetConfirm{
+				Token:    "valid-token",
+				Password: "87654321",
 			},
-			method:      http.MethodPost,
 			expectError: nil,
 		},
+		{
+			name: "reset_password-error_used_token",
+			body: dto.PasswordResetConfirm{
+				Token:    "valid-token",
+				Password: "87654321",
+			},
+			expectError: ErrInvalidResetToken,
+		},
+		{
+			name: "reset_password-error_invalid_token",
+			body: dto.PasswordResetConfirm{
+				Token:    "made-up",
+				Password: "87654321",
+			},
+			expectError: ErrInvalidResetToken,
+		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePassword(c)
+			err := s.service.ResetPassword(context.Background(), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestUpdatePasswordErrorBinding() {
+
+// ChangePassword
+func (s *UserSuite) TestChangePassword() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.ChangePassword
+		userID      uuid.UUID
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "update_password-error_binding",
-			body: models.User{
-				Username: "udin",
-				Password: "12345678",
+			name: "change_password-error_wrong_current_password",
+			body: dto.ChangePassword{
+				CurrentPassword: "11111111",
+				NewPassword:     "87654321",
 			},
-			method:      http.MethodPost,
-			expectError: echo.NewHTTPError(415, "Unsupported Media Type"),
+			userID:      udinID,
+			expectError: ErrInvalidCredentials,
+		},
+		{
+			name: "change_password-error_user_not_found",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     "87654321",
+			},
+			userID:      missingID,
+			expectError: errNotFound,
+		},
+		{
+			name: "change_password-error_db",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     "87654321",
+			},
+			userID:      udinID,
+			dbErrors:    map[string]error{"UpdatePassword": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name: "change_password-normal",
+			body: dto.ChangePassword{
+				CurrentPassword: "12345678",
+				NewPassword:     "87654321",
+			},
+			userID:      udinID,
+			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-
-			err := s.service.UpdatePassword(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.ChangePassword(callerContext(v.userID), v.body)
 			s.Equal(v.expectError, err)
 		})
 	}
 }
-func (s *UserSuite) TestUpdatePasswordErrorUsernameEmpty() {
+func (s *UserSuite) TestChangePasswordErrorAuth() {
+	err := s.service.ChangePassword(context.Background(), dto.ChangePassword{CurrentPassword: "12345678", NewPassword: "87654321"})
+	s.Equal(authz.ErrUnauthenticated, err)
+}
+func (s *UserSuite) TestChangePasswordRevokesOtherSessions() {
+	current, err := s.repo.GetSessionByRefreshTokenHash(hashToken("active-refresh"))
+	s.Require().NoError(err)
+	other, err := s.repo.GetSessionByRefreshTokenHash(hashToken("raced-refresh"))
+	s.Require().NoError(err)
+
+	ctx := authz.NewContext(context.Background(), authz.Principal{UUID: udinID, Username: "udin", SessionID: current.UUID})
+	s.NoError(s.service.ChangePassword(ctx, dto.ChangePassword{CurrentPassword: "12345678", NewPassword: "87654321"}))
+
+	session, err := s.repo.GetSessionByUUID(current.UUID)
+	s.NoError(err)
+	s.True(session.Active(time.Now()))
+	session, err = s.repo.GetSessionByUUID(other.UUID)
+	s.NoError(err)
+	s.False(session.Active(time.Now()))
+}
+
+// RefreshToken
+func (s *UserSuite) TestRefreshToken() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		body        dto.RefreshToken
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "update_password-error_username_empty",
-			body: models.User{
-				Username: "",
-				Password: "12345678",
-			},
-			method:      http.MethodPost,
-			expectError: errors.New("username should not be empty"),
+			name:        "refresh_token-normal",
+			body:        dto.RefreshToken{RefreshToken: "active-refresh"},
+			expectError: nil,
+		},
+		{
+			name:        "refresh_token-error_unknown",
+			body:        dto.RefreshToken{RefreshToken: "made-up"},
+			expectError: ErrInvalidRefreshToken,
+		},
+		{
+			name:        "refresh_token-error_expired",
+			body:        dto.RefreshToken{RefreshToken: "expired-refresh"},
+			expectError: ErrInvalidRefreshToken,
+		},
+		{
+			name:        "refresh_token-error_reused",
+			body:        dto.RefreshToken{RefreshToken: "rotated-refresh"},
+			expectError: ErrRefreshTokenReused,
+		},
+		{
+			name: "refresh_token-error_concurrent_reuse",
+			body: dto.RefreshToken{RefreshToken: "raced-refresh"},
+			// Another request rotated the session between the read and the rotation.
+			dbErrors:    map[string]error{"RotateSession": errNotFound},
+			expectError: ErrRefreshTokenReused,
+		},
+		{
+			name:        "refresh_token-error_user_not_found",
+			body:        dto.RefreshToken{RefreshToken: "orphan-refresh"},
+			expectError: errNotFound,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePassword(c)
+			s.db.Errors = v.dbErrors
+			result, err := s.service.RefreshToken(context.Background(), v.body)
 			s.Equal(v.expectError, err)
+			if v.expectError == nil {
+				s.Equal("udin", result.Username)
+				s.NotEmpty(result.Token)
+				s.NotEqual("active-refresh", result.RefreshToken)
+				s.NotEmpty(result.RefreshToken)
+			}
 		})
 	}
+
+	// The rotated token can not be used again.
+	_, err := s.service.RefreshToken(context.Background(), dto.RefreshToken{RefreshToken: "active-refresh"})
+	s.Equal(ErrRefreshTokenReused, err)
 }
-func (s *UserSuite) TestUpdatePasswordErrorPasswordEmpty() {
+
+// Logout
+func (s *UserSuite) TestLogout() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "update_password-error_password_empty",
-			body: models.User{
-				Username: "asds",
-				Password: "",
-			},
-			method:      http.MethodPost,
-			expectError: errors.New("password should not be empty"),
+			name:        "logout-error_db",
+			dbErrors:    map[string]error{"RevokeSession": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:        "logout-normal",
+			expectError: nil,
 		},
 	}
+	session, err := s.repo.GetSessionByRefreshTokenHash(hashToken("active-refresh"))
+	s.Require().NoError(err)
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePassword(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.Logout(authz.NewContext(context.Background(), authz.Principal{UUID: udinID, Username: "udin", SessionID: session.UUID}))
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	session, err = s.repo.GetSessionByUUID(session.UUID)
+	s.NoError(err)
+	s.False(session.Active(time.Now()))
 }
-func (s *UserSuite) TestUpdatePasswordErrorDB() {
+func (s *UserSuite) TestLogoutAll() {
 	testCase := []struct {
 		name        string
-		body        models.User
-		method      string
+		dbErrors    map[string]error
 		expectError error
 	}{
 		{
-			name: "update_password-error_db",
-			body: models.User{
-				Username: "asds",
-				Password: "adwawea",
-			},
-			method:      http.MethodPost,
-			expectError: errors.New("password too short"),
+			name:        "logout_all-error_db",
+			dbErrors:    map[string]error{"RevokeUserSessions": errors.New("database error")},
+			expectError: errors.New("database error"),
+		},
+		{
+			name:        "logout_all-normal",
+			expectError: nil,
 		},
 	}
 	for _, v := range testCase {
 		s.T().Run(v.name, func(t *testing.T) {
-			res, _ := json.Marshal(v.body)
-			r := httptest.NewRequest(v.method, "/", bytes.NewBuffer(res))
-			w := httptest.NewRecorder()
-			c := echo.New().NewContext(r, w)
-			c.Request().Header.Set("Content-Type", "application/json")
-
-			err := s.service.UpdatePassword(c)
+			s.db.Errors = v.dbErrors
+			err := s.service.LogoutAll(authz.NewContext(context.Background(), authz.Principal{UUID: udinID, Username: "udin", SessionID: uuid.New()}))
 			s.Equal(v.expectError, err)
 		})
 	}
+
+	_, err := s.service.RefreshToken(context.Background(), dto.RefreshToken{RefreshToken: "raced-refresh"})
+	s.Equal(ErrInvalidRefreshToken, err)
+}
+func (s *UserSuite) TestLogoutErrorAuth() {
+	err := s.service.Logout(context.Background())
+	s.Equal(authz.ErrUnauthenticated, err)
+
+	err = s.service.LogoutAll(context.Background())
+	s.Equal(authz.ErrUnauthenticated, err)
 }